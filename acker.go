@@ -0,0 +1,73 @@
+package eda
+
+import (
+	"context"
+	"sync"
+)
+
+type contextKey int
+
+const (
+	ackerContextKey contextKey = iota
+	correlationContextKey
+)
+
+// Acker gives a handler explicit control over acknowledging an event.
+// See SubscriptionOptions.ManualACK.
+type Acker interface {
+	// Ack acknowledges the event has been handled.
+	Ack() error
+
+	// Nack signals the event was not handled and should be redelivered.
+	Nack() error
+}
+
+// AckerFromContext returns the Acker for the event being handled. This is
+// only set for subscriptions with ManualACK enabled.
+func AckerFromContext(ctx context.Context) (Acker, bool) {
+	a, ok := ctx.Value(ackerContextKey).(Acker)
+	return a, ok
+}
+
+// msgAcker is an Acker for a received message.
+type msgAcker struct {
+	mux    sync.Mutex
+	ack    func() error
+	called bool
+	nack   bool
+}
+
+func (a *msgAcker) Ack() error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	a.called = true
+	return a.ack()
+}
+
+// Nack does not acknowledge the message so the server will redeliver it
+// once the ack wait has elapsed.
+func (a *msgAcker) Nack() error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	a.called = true
+	a.nack = true
+	return nil
+}
+
+// done returns true if Ack or Nack was called.
+func (a *msgAcker) done() bool {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	return a.called
+}
+
+// nacked returns true if Nack was called.
+func (a *msgAcker) nacked() bool {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	return a.nack
+}
@@ -0,0 +1,119 @@
+package eda
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/nats-io/nuid"
+)
+
+// Expected number of aggregates and false positive rate of the index
+// bloom filter.
+const (
+	aggregateIndexSize   = 100000
+	aggregateIndexFPRate = 0.01
+)
+
+// AggregateIndex maintains an index of aggregate IDs to the stream
+// containing their events. A bloom filter is checked before the index so
+// lookups of unknown aggregates do not touch the index.
+type AggregateIndex struct {
+	conn    Conn
+	streams []string
+
+	mux   sync.RWMutex
+	bloom *bloomFilter
+	index map[string]string
+	subs  []Subscription
+}
+
+func (x *AggregateIndex) handle(ctx context.Context, evt *Event) error {
+	if evt.Aggregate == "" {
+		return nil
+	}
+
+	x.mux.Lock()
+	x.bloom.Add(evt.Aggregate)
+	x.index[evt.Aggregate] = evt.Stream
+	x.mux.Unlock()
+
+	return nil
+}
+
+// StreamFor returns the stream containing events for the aggregate. If
+// events for the aggregate are on multiple streams, the stream of the
+// most recently indexed event is returned.
+func (x *AggregateIndex) StreamFor(id string) (string, bool) {
+	x.mux.RLock()
+	defer x.mux.RUnlock()
+
+	if !x.bloom.Test(id) {
+		return "", false
+	}
+
+	stream, ok := x.index[id]
+	return stream, ok
+}
+
+// Start subscribes to the streams from the beginning to build the index.
+// The index is stopped when the context is done.
+func (x *AggregateIndex) Start(ctx context.Context) error {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	if x.subs != nil {
+		return errors.New("aggregate index already started")
+	}
+
+	for _, stream := range x.streams {
+		sub, err := x.conn.Subscribe(stream, x.handle, &SubscriptionOptions{
+			Name:     nuid.Next(),
+			Backfill: true,
+		})
+		if err != nil {
+			for _, sub := range x.subs {
+				sub.Unsubscribe()
+			}
+			x.subs = nil
+			return err
+		}
+
+		x.subs = append(x.subs, sub)
+	}
+
+	go func() {
+		<-ctx.Done()
+		x.Stop()
+	}()
+
+	return nil
+}
+
+// Stop closes the subscriptions. The index is retained.
+func (x *AggregateIndex) Stop() error {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	var err error
+	for _, sub := range x.subs {
+		if e := sub.Unsubscribe(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	x.subs = nil
+
+	return err
+}
+
+// NewAggregateIndex returns an index of the aggregates with events on
+// the streams.
+func NewAggregateIndex(conn Conn, streams []string) *AggregateIndex {
+	return &AggregateIndex{
+		conn:    conn,
+		streams: streams,
+		bloom:   newBloomFilter(aggregateIndexSize, aggregateIndexFPRate),
+		index:   make(map[string]string),
+	}
+}
@@ -0,0 +1,42 @@
+package eda
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAggregateIndex(t *testing.T) {
+	x := NewAggregateIndex(nil, nil)
+
+	ctx := context.Background()
+
+	x.handle(ctx, &Event{Stream: "orders", Aggregate: "1"})
+	x.handle(ctx, &Event{Stream: "payments", Aggregate: "2"})
+	x.handle(ctx, &Event{Stream: "orders"})
+
+	if s, ok := x.StreamFor("1"); !ok || s != "orders" {
+		t.Errorf("expected orders, got %s", s)
+	}
+
+	if s, ok := x.StreamFor("2"); !ok || s != "payments" {
+		t.Errorf("expected payments, got %s", s)
+	}
+
+	if _, ok := x.StreamFor("3"); ok {
+		t.Error("expected unknown aggregate")
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	f := newBloomFilter(100, 0.01)
+
+	f.Add("foo")
+
+	if !f.Test("foo") {
+		t.Error("expected foo in filter")
+	}
+
+	if f.Test("bar") {
+		t.Error("expected bar not in filter")
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Package aggregate supports event-sourced aggregates whose state is rebuilt
+by applying the events of the aggregate in a stream.
+
+An aggregate embeds BaseAggregate for version tracking and applies events
+to its state:
+
+	type Account struct {
+		aggregate.BaseAggregate
+		Balance int
+	}
+
+	func (a *Account) Apply(evt *eda.Event) error {
+		switch evt.Type {
+		case "deposited":
+			var amount int
+			if err := evt.Data.Decode(&amount); err != nil {
+				return err
+			}
+			a.Balance += amount
+		}
+		return nil
+	}
+
+	func (a *Account) Deposit(amount int) error {
+		return aggregate.Raise(a, &eda.Event{
+			Type: "deposited",
+			Data: eda.JSON(amount),
+		})
+	}
+
+A Repository loads aggregates from a stream and publishes their pending
+events:
+
+	repo := aggregate.NewRepository(conn, "accounts", func(id string) *Account {
+		a := &Account{}
+		a.SetID(id)
+		return a
+	})
+*/
+package aggregate
+
+import (
+	"github.com/chop-dbhi/eda"
+)
+
+// VersionMetaKey is the event meta key of the aggregate version the event
+// was applied to.
+const VersionMetaKey = "aggregate.version"
+
+// Aggregate is an event-sourced aggregate.
+type Aggregate interface {
+	// ID returns the ID of the aggregate which is set as the aggregate of
+	// its events.
+	ID() string
+
+	// Version returns the number of events applied to the aggregate,
+	// including pending events.
+	Version() int
+
+	// Apply applies the event to the state of the aggregate.
+	Apply(evt *eda.Event) error
+
+	// PendingEvents returns the events raised since the aggregate was
+	// loaded or saved.
+	PendingEvents() []*eda.Event
+
+	// ClearPending clears the pending events once they are saved.
+	ClearPending()
+
+	// Record adds an event raised and applied by Raise to the pending
+	// events.
+	Record(evt *eda.Event)
+}
+
+// BaseAggregate implements the ID, version, and pending event tracking of
+// an aggregate. It is embedded in aggregate types, which implement Apply.
+type BaseAggregate struct {
+	id      string
+	version int
+	pending []*eda.Event
+}
+
+// ID returns the ID of the aggregate.
+func (a *BaseAggregate) ID() string {
+	return a.id
+}
+
+// SetID sets the ID of the aggregate. This is called by the function that
+// creates aggregates for the repository.
+func (a *BaseAggregate) SetID(id string) {
+	a.id = id
+}
+
+// Version returns the number of events applied to the aggregate.
+func (a *BaseAggregate) Version() int {
+	return a.version
+}
+
+// PendingEvents returns the events recorded since the aggregate was loaded
+// or saved.
+func (a *BaseAggregate) PendingEvents() []*eda.Event {
+	return a.pending
+}
+
+// ClearPending clears the pending events.
+func (a *BaseAggregate) ClearPending() {
+	a.pending = nil
+}
+
+// Record adds the event, which has been applied to the aggregate, to the
+// pending events and increments the version.
+func (a *BaseAggregate) Record(evt *eda.Event) {
+	evt.Aggregate = a.id
+	a.pending = append(a.pending, evt)
+	a.version++
+}
+
+// replayed increments the version for an event loaded from the stream.
+func (a *BaseAggregate) replayed() {
+	a.version++
+}
+
+// restored sets the version of an aggregate restored from a snapshot.
+func (a *BaseAggregate) restored(version int) {
+	a.version = version
+}
+
+// replayer is implemented by aggregates that embed BaseAggregate.
+type replayer interface {
+	replayed()
+}
+
+// restorer is implemented by aggregates that embed BaseAggregate.
+type restorer interface {
+	restored(version int)
+}
+
+// Raise applies a new event to the aggregate and records it as pending.
+// The data is encoded first so Apply can decode it in the same way as the
+// data of loaded events.
+func Raise(a Aggregate, evt *eda.Event) error {
+	evt.Aggregate = a.ID()
+
+	if evt.Data != nil {
+		b, err := eda.MarshalEvent(&eda.Event{Data: evt.Data})
+		if err != nil {
+			return err
+		}
+
+		e, err := eda.UnmarshalEvent(b)
+		if err != nil {
+			return err
+		}
+
+		evt.Data = e.Data
+	}
+
+	if err := a.Apply(evt); err != nil {
+		return err
+	}
+
+	a.Record(evt)
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package aggregate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/chop-dbhi/eda"
+	"github.com/chop-dbhi/eda/edatest"
+)
+
+// condConn is a MemConn that publishes conditionally. The before function
+// is called once before publishing to simulate a concurrent save.
+type condConn struct {
+	edatest.MemConn
+
+	before func()
+}
+
+func (c *condConn) PublishAfter(stream string, evts []*eda.Event, seq uint64) ([]string, error) {
+	if c.before != nil {
+		c.before()
+		c.before = nil
+	}
+
+	return eda.PublishEach(func(stream string, evt *eda.Event) (string, error) {
+		if uint64(len(c.Events)) != seq {
+			return "", eda.ErrSeqConflict
+		}
+		seq++
+		return c.Publish(stream, evt)
+	}, stream, evts)
+}
+
+type account struct {
+	BaseAggregate
+
+	Balance int
+}
+
+func (a *account) Apply(evt *eda.Event) error {
+	switch evt.Type {
+	case "deposited":
+		var amount int
+		if err := evt.Data.Decode(&amount); err != nil {
+			return err
+		}
+		a.Balance += amount
+	}
+	return nil
+}
+
+func (a *account) deposit(amount int) error {
+	return Raise(a, &eda.Event{
+		Type: "deposited",
+		Data: eda.JSON(amount),
+	})
+}
+
+func newAccount(id string) *account {
+	a := &account{}
+	a.SetID(id)
+	return a
+}
+
+func TestRepository(t *testing.T) {
+	conn := &edatest.MemConn{}
+	repo := NewRepository(conn, "accounts", newAccount)
+	ctx := context.Background()
+
+	a, err := repo.Load(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Version() != 0 {
+		t.Fatalf("expected version 0, got %d", a.Version())
+	}
+
+	a.deposit(10)
+	a.deposit(5)
+
+	if err := repo.Save(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a.PendingEvents()) != 0 {
+		t.Error("expected pending events to be cleared")
+	}
+
+	if v := conn.Events[1].Meta[VersionMetaKey]; v != "1" {
+		t.Errorf("expected version 1 in meta, got %q", v)
+	}
+
+	// Events of other aggregates are ignored.
+	other := newAccount("2")
+	other.deposit(100)
+	if err := repo.Save(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err = repo.Load(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Version() != 2 || a.Balance != 15 {
+		t.Fatalf("expected version 2 with Balance 15, got %d with %d", a.Version(), a.Balance)
+	}
+
+	// Save a concurrent change.
+	b, _ := repo.Load(ctx, "1")
+	b.deposit(1)
+	if err := repo.Save(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+
+	a.deposit(1)
+
+	err = repo.Save(ctx, a)
+
+	var cerr *ErrConcurrencyConflict
+	if !errors.As(err, &cerr) {
+		t.Fatalf("expected concurrency conflict, got %v", err)
+	}
+
+	if cerr.Expected != 2 || cerr.Actual != 3 {
+		t.Errorf("unexpected conflict: %v", cerr)
+	}
+}
+
+func TestRepositoryConditional(t *testing.T) {
+	conn := &condConn{}
+	repo := NewRepository(conn, "accounts", newAccount)
+	ctx := context.Background()
+
+	a, _ := repo.Load(ctx, "1")
+	a.deposit(10)
+
+	// An event of another aggregate is published after the stream is read,
+	// so the version is checked again.
+	conn.before = func() {
+		conn.Publish("accounts", &eda.Event{Type: "deposited", Aggregate: "2"})
+	}
+
+	if err := repo.Save(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conn.Events) != 2 || conn.Events[1].Aggregate != "1" {
+		t.Fatalf("unexpected events: %v", conn.Events)
+	}
+
+	// An event of the aggregate is published after the stream is read.
+	a.deposit(5)
+
+	conn.before = func() {
+		conn.Publish("accounts", &eda.Event{Type: "deposited", Aggregate: "1"})
+	}
+
+	err := repo.Save(ctx, a)
+
+	var cerr *ErrConcurrencyConflict
+	if !errors.As(err, &cerr) {
+		t.Fatalf("expected concurrency conflict, got %v", err)
+	}
+
+	if cerr.Expected != 1 || cerr.Actual != 2 {
+		t.Errorf("unexpected conflict: %v", cerr)
+	}
+}
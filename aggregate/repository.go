@@ -0,0 +1,294 @@
+package aggregate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chop-dbhi/eda"
+)
+
+// ErrConcurrencyConflict is returned by Repository.Save if events were
+// saved for the aggregate since it was loaded.
+type ErrConcurrencyConflict struct {
+	ID       string
+	Expected int
+	Actual   int
+}
+
+func (e *ErrConcurrencyConflict) Error() string {
+	return fmt.Sprintf("aggregate %s: expected version %d, got %d", e.ID, e.Expected, e.Actual)
+}
+
+// ErrSnapshotsNotSupported is returned by Repository.Load and Save if
+// snapshots are configured and the connection cannot read a stream from a
+// sequence, such as the Redis, Kinesis, Pulsar, and Pub/Sub backends.
+var ErrSnapshotsNotSupported = errors.New("snapshots require reading a stream from a sequence")
+
+// Repository loads and saves aggregates of type T whose events are
+// published on a stream.
+//
+// Load and Save read the stream after the latest snapshot and skip the
+// events of other aggregates, so their cost grows with the size of the
+// stream rather than the aggregate. Use snapshots or a repository per
+// aggregate stream, such as named by an eda.StreamNamer, for large streams.
+type Repository[T Aggregate] struct {
+	// Snapshots stores snapshots of the aggregates. If it is set along
+	// with Snapshotter, Load restores the latest snapshot and only replays
+	// the events after it. Snapshots rely on the event sequence so they
+	// are not supported for streams partitioned across sequences, such as
+	// Kafka topics with multiple partitions, and ErrSnapshotsNotSupported
+	// is returned for backends without sequences.
+	Snapshots   SnapshotStore
+	Snapshotter Snapshotter
+
+	// SnapshotEvery is the number of events replayed by Load after which a
+	// new snapshot is saved. If zero, snapshots are not saved.
+	SnapshotEvery int
+
+	conn   eda.Conn
+	stream string
+	new    func(id string) T
+
+	// Result of checking the connection supports snapshots.
+	seqOnce sync.Once
+	seqErr  error
+}
+
+// replay applies the events of the aggregate in the stream after the
+// snapshot to it and returns the number of events, the sequence of the
+// last event of the aggregate, and the sequence of the last event in the
+// stream. If apply is false, the events are only counted.
+func (r *Repository[T]) replay(ctx context.Context, a T, snap *Snapshot, apply bool) (int, uint64, uint64, error) {
+	var (
+		opts *eda.ReadOptions
+		seq  uint64
+	)
+
+	if snap != nil {
+		seq = snap.Seq
+		opts = &eda.ReadOptions{
+			From: eda.AtSeq(snap.Seq + 1),
+		}
+	}
+
+	last := seq
+
+	it, err := r.conn.Read(r.stream, opts)
+	if err != nil {
+		return 0, seq, last, err
+	}
+	defer it.Close()
+
+	rp, _ := Aggregate(a).(replayer)
+
+	var n int
+
+	for {
+		evt, err := it.Next(ctx)
+		if err == io.EOF {
+			return n, seq, last, nil
+		}
+		if err != nil {
+			return n, seq, last, err
+		}
+
+		last = evt.Seq
+
+		if evt.Aggregate != a.ID() {
+			continue
+		}
+
+		n++
+		seq = evt.Seq
+
+		if !apply {
+			continue
+		}
+
+		if err := a.Apply(evt); err != nil {
+			return n, seq, last, fmt.Errorf("apply event %s: %w", evt.ID, err)
+		}
+
+		if rp != nil {
+			rp.replayed()
+		}
+	}
+}
+
+// snapshots returns true if the repository is configured with snapshots.
+func (r *Repository[T]) snapshots() bool {
+	return r.Snapshots != nil && r.Snapshotter != nil
+}
+
+// checkSeq returns ErrSnapshotsNotSupported if the connection cannot read
+// the stream from a sequence, before a snapshot is saved that could not be
+// read after. The connection is only checked once.
+func (r *Repository[T]) checkSeq() error {
+	r.seqOnce.Do(func() {
+		it, err := r.conn.Read(r.stream, &eda.ReadOptions{From: eda.AtSeq(1)})
+		if errors.Is(err, eda.ErrSeqNotSupported) || errors.Is(err, eda.ErrReadNotSupported) {
+			r.seqErr = ErrSnapshotsNotSupported
+			return
+		}
+
+		if err == nil {
+			it.Close()
+		}
+	})
+
+	return r.seqErr
+}
+
+// Load returns the aggregate with the events in the stream applied. An
+// aggregate without events is returned at version zero. If snapshots are
+// enabled, the latest snapshot is restored first and a new snapshot is
+// saved once SnapshotEvery events have been replayed.
+func (r *Repository[T]) Load(ctx context.Context, id string) (T, error) {
+	var zero T
+
+	a := r.new(id)
+
+	var snap *Snapshot
+
+	if r.snapshots() {
+		if err := r.checkSeq(); err != nil {
+			return zero, err
+		}
+
+		var err error
+		snap, err = r.Snapshots.GetLatest(ctx, id)
+		if err != nil {
+			return zero, err
+		}
+
+		if snap != nil {
+			if err := r.Snapshotter.RestoreSnapshot(a, snap); err != nil {
+				return zero, fmt.Errorf("restore snapshot: %w", err)
+			}
+
+			if rs, ok := Aggregate(a).(restorer); ok {
+				rs.restored(snap.Version)
+			}
+		}
+	}
+
+	n, seq, _, err := r.replay(ctx, a, snap, true)
+	if err != nil {
+		return zero, err
+	}
+
+	if r.snapshots() && r.SnapshotEvery > 0 && n >= r.SnapshotEvery {
+		s, err := r.Snapshotter.TakeSnapshot(a)
+		if err != nil {
+			return zero, fmt.Errorf("take snapshot: %w", err)
+		}
+
+		s.AggregateID = id
+		s.Version = a.Version()
+		s.Seq = seq
+		s.Time = time.Now()
+
+		if err := r.Snapshots.Save(ctx, s); err != nil {
+			return zero, fmt.Errorf("save snapshot: %w", err)
+		}
+	}
+
+	return a, nil
+}
+
+// Save publishes the pending events of the aggregate. The version the
+// aggregate was loaded at is compared to the number of events of the
+// aggregate in the stream after the latest snapshot, and an
+// *ErrConcurrencyConflict is returned if they differ. Each event has the
+// version it was applied to set in its meta with the VersionMetaKey key.
+//
+// If the connection is an eda.ConditionalPublisher, such as JetStream, the
+// events are only published if no events were published to the stream
+// since it was read, and the check is repeated otherwise. For other
+// connections the check and publish are not atomic, so Save is best-effort
+// and concurrent saves of an aggregate may both succeed.
+func (r *Repository[T]) Save(ctx context.Context, a T) error {
+	pending := a.PendingEvents()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	expected := a.Version() - len(pending)
+
+	var snap *Snapshot
+
+	if r.snapshots() {
+		if err := r.checkSeq(); err != nil {
+			return err
+		}
+
+		var err error
+		snap, err = r.Snapshots.GetLatest(ctx, a.ID())
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, evt := range pending {
+		if evt.Meta == nil {
+			evt.Meta = make(map[string]string)
+		}
+		evt.Meta[VersionMetaKey] = strconv.Itoa(expected + i)
+	}
+
+	cp, conditional := r.conn.(eda.ConditionalPublisher)
+
+	for {
+		actual, _, last, err := r.replay(ctx, a, snap, false)
+		if err != nil {
+			return err
+		}
+
+		if snap != nil {
+			actual += snap.Version
+		}
+
+		if actual != expected {
+			return &ErrConcurrencyConflict{
+				ID:       a.ID(),
+				Expected: expected,
+				Actual:   actual,
+			}
+		}
+
+		if !conditional {
+			_, err = r.conn.PublishBatch(r.stream, pending)
+		} else {
+			var ids []string
+			ids, err = cp.PublishAfter(r.stream, pending, last)
+
+			// Events were published since the stream was read, which may be
+			// of other aggregates, so the version is checked again.
+			if len(ids) == 0 && errors.Is(err, eda.ErrSeqConflict) {
+				continue
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		a.ClearPending()
+
+		return nil
+	}
+}
+
+// NewRepository returns a repository of the aggregates in the stream. The
+// new function returns an aggregate with the ID and no events applied.
+func NewRepository[T Aggregate](conn eda.Conn, stream string, new func(id string) T) *Repository[T] {
+	return &Repository[T]{
+		conn:   conn,
+		stream: stream,
+		new:    new,
+	}
+}
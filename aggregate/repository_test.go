@@ -0,0 +1,79 @@
+package aggregate
+
+import (
+	"context"
+	"flag"
+	"testing"
+
+	"github.com/chop-dbhi/eda"
+	"github.com/nats-io/nuid"
+)
+
+var (
+	addr    string
+	cluster string
+	monitor string
+	jsAddr  string
+)
+
+func init() {
+	flag.StringVar(&addr, "addr", "nats://localhost:4222", "NATS address")
+	flag.StringVar(&cluster, "cluster", "test-cluster", "NATS cluster name.")
+	flag.StringVar(&monitor, "monitor", "http://localhost:8222", "NATS monitoring address.")
+	flag.StringVar(&jsAddr, "jetstream-addr", "nats://localhost:4223", "NATS JetStream address.")
+}
+
+// testRepositoryNewStream saves and loads an aggregate on a stream that
+// does not exist yet.
+func testRepositoryNewStream(t *testing.T, conn eda.Conn) {
+	repo := NewRepository(conn, "accounts-"+nuid.Next(), newAccount)
+	ctx := context.Background()
+
+	a, err := repo.Load(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.deposit(10)
+
+	if err := repo.Save(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+
+	a.deposit(5)
+
+	if err := repo.Save(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err = repo.Load(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Version() != 2 || a.Balance != 15 {
+		t.Fatalf("expected version 2 with Balance 15, got %d with %d", a.Version(), a.Balance)
+	}
+}
+
+func TestRepositoryStan(t *testing.T) {
+	conn, err := eda.Connect(addr, cluster, "aggregate-test", eda.WithMonitorAddr(monitor))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	testRepositoryNewStream(t, conn)
+}
+
+func TestRepositoryJetStream(t *testing.T) {
+	conn, err := eda.Connect(jsAddr, "", "aggregate-test", eda.WithJetStream())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	testRepositoryNewStream(t, conn)
+}
@@ -0,0 +1,161 @@
+package aggregate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Snapshot is the state of an aggregate at a version.
+type Snapshot struct {
+	AggregateID string `json:"aggregate_id"`
+
+	// Version of the aggregate.
+	Version int `json:"version"`
+
+	// Seq is the stream sequence of the last event applied to the aggregate.
+	Seq uint64 `json:"seq"`
+
+	// Time the snapshot was taken.
+	Time time.Time `json:"time"`
+
+	// Data is the encoded state of the aggregate.
+	Data []byte `json:"data"`
+}
+
+// Snapshotter encodes and decodes the state of aggregates. The repository
+// sets the ID, version, sequence, and time of snapshots.
+type Snapshotter interface {
+	// TakeSnapshot returns a snapshot with the encoded state of the
+	// aggregate.
+	TakeSnapshot(a Aggregate) (*Snapshot, error)
+
+	// RestoreSnapshot decodes the state in the snapshot into the aggregate.
+	RestoreSnapshot(a Aggregate, s *Snapshot) error
+}
+
+// JSONSnapshotter encodes the state of aggregates as JSON, so only the
+// exported fields of the aggregate are restored.
+type JSONSnapshotter struct{}
+
+func (JSONSnapshotter) TakeSnapshot(a Aggregate) (*Snapshot, error) {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{Data: b}, nil
+}
+
+func (JSONSnapshotter) RestoreSnapshot(a Aggregate, s *Snapshot) error {
+	return json.Unmarshal(s.Data, a)
+}
+
+// SnapshotStore stores the latest snapshot of each aggregate.
+type SnapshotStore interface {
+	// GetLatest returns the latest snapshot of the aggregate or nil if the
+	// aggregate has none.
+	GetLatest(ctx context.Context, id string) (*Snapshot, error)
+
+	// Save saves the snapshot, replacing the previous snapshot of the
+	// aggregate.
+	Save(ctx context.Context, s *Snapshot) error
+}
+
+// MemSnapshotStore stores snapshots in memory.
+type MemSnapshotStore struct {
+	mux       sync.RWMutex
+	snapshots map[string]*Snapshot
+}
+
+func (s *MemSnapshotStore) GetLatest(ctx context.Context, id string) (*Snapshot, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return s.snapshots[id], nil
+}
+
+func (s *MemSnapshotStore) Save(ctx context.Context, snap *Snapshot) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.snapshots[snap.AggregateID] = snap
+
+	return nil
+}
+
+// NewMemSnapshotStore returns an empty in-memory snapshot store.
+func NewMemSnapshotStore() *MemSnapshotStore {
+	return &MemSnapshotStore{
+		snapshots: make(map[string]*Snapshot),
+	}
+}
+
+// FileSnapshotStore stores snapshots as JSON files in a directory, one
+// per aggregate.
+type FileSnapshotStore struct {
+	dir string
+}
+
+func (s *FileSnapshotStore) path(id string) string {
+	return filepath.Join(s.dir, url.PathEscape(id)+".json")
+}
+
+func (s *FileSnapshotStore) GetLatest(ctx context.Context, id string) (*Snapshot, error) {
+	b, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return nil, err
+	}
+
+	return &snap, nil
+}
+
+// Save writes the snapshot to a temporary file which replaces the
+// previous snapshot, so a failed write does not corrupt it.
+func (s *FileSnapshotStore) Save(ctx context.Context, snap *Snapshot) error {
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(s.dir, ".snapshot-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path(snap.AggregateID))
+}
+
+// NewFileSnapshotStore returns a snapshot store in the directory, which
+// is created if it does not exist.
+func NewFileSnapshotStore(dir string) (*FileSnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileSnapshotStore{dir: dir}, nil
+}
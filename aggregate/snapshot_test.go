@@ -0,0 +1,147 @@
+package aggregate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/chop-dbhi/eda"
+	"github.com/chop-dbhi/eda/edatest"
+)
+
+func testSnapshotStore(t *testing.T, store SnapshotStore) {
+	ctx := context.Background()
+
+	snap, err := store.GetLatest(ctx, "1")
+	if err != nil || snap != nil {
+		t.Fatalf("expected no snapshot, got %v: %v", snap, err)
+	}
+
+	for v := 1; v <= 2; v++ {
+		if err := store.Save(ctx, &Snapshot{AggregateID: "1", Version: v, Data: []byte("{}")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snap, err = store.GetLatest(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if snap.Version != 2 || string(snap.Data) != "{}" {
+		t.Errorf("unexpected snapshot: %+v", snap)
+	}
+}
+
+func TestMemSnapshotStore(t *testing.T) {
+	testSnapshotStore(t, NewMemSnapshotStore())
+}
+
+func TestFileSnapshotStore(t *testing.T) {
+	store, err := NewFileSnapshotStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testSnapshotStore(t, store)
+}
+
+func TestRepositorySnapshots(t *testing.T) {
+	conn := &edatest.MemConn{}
+	store := NewMemSnapshotStore()
+
+	repo := NewRepository(conn, "accounts", newAccount)
+	repo.Snapshots = store
+	repo.Snapshotter = JSONSnapshotter{}
+	repo.SnapshotEvery = 2
+
+	ctx := context.Background()
+
+	a := newAccount("1")
+	a.deposit(1)
+	a.deposit(2)
+	a.deposit(3)
+	if err := repo.Save(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Load(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, _ := store.GetLatest(ctx, "1")
+	if snap == nil || snap.Version != 3 || snap.Seq != 3 {
+		t.Fatalf("unexpected snapshot: %+v", snap)
+	}
+
+	// Change the stored events to check they are not replayed.
+	for _, evt := range conn.Events {
+		evt.Aggregate = "replayed"
+	}
+
+	a, err := repo.Load(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Version() != 3 || a.Balance != 6 {
+		t.Fatalf("expected version 3 with balance 6, got %d with %d", a.Version(), a.Balance)
+	}
+
+	a.deposit(4)
+	if err := repo.Save(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err = repo.Load(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Version() != 4 || a.Balance != 10 {
+		t.Fatalf("expected version 4 with balance 10, got %d with %d", a.Version(), a.Balance)
+	}
+}
+
+// seqlessConn is a MemConn that cannot read from a sequence.
+type seqlessConn struct {
+	edatest.MemConn
+}
+
+func (c *seqlessConn) Read(stream string, opts *eda.ReadOptions) (eda.EventIterator, error) {
+	if opts != nil && opts.From.Seq > 0 {
+		return nil, fmt.Errorf("mem: %w", eda.ErrSeqNotSupported)
+	}
+
+	return c.MemConn.Read(stream, opts)
+}
+
+func TestRepositorySnapshotsNotSupported(t *testing.T) {
+	store := NewMemSnapshotStore()
+
+	repo := NewRepository(&seqlessConn{}, "accounts", newAccount)
+	repo.Snapshots = store
+	repo.Snapshotter = JSONSnapshotter{}
+	repo.SnapshotEvery = 1
+
+	ctx := context.Background()
+
+	a := newAccount("1")
+	a.deposit(1)
+
+	if err := repo.Save(ctx, a); !errors.Is(err, ErrSnapshotsNotSupported) {
+		t.Fatalf("expected ErrSnapshotsNotSupported, got %v", err)
+	}
+
+	if _, err := repo.Load(ctx, "1"); !errors.Is(err, ErrSnapshotsNotSupported) {
+		t.Fatalf("expected ErrSnapshotsNotSupported, got %v", err)
+	}
+
+	// Repositories without snapshots are not affected.
+	repo.Snapshots = nil
+
+	if err := repo.Save(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,122 @@
+package eda
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Alert is sent to a rule's notifier when an event matches the rule.
+type Alert struct {
+	Event   *Event
+	Message string
+	Time    time.Time
+}
+
+// AlertRule defines the events an alert is fired for.
+type AlertRule struct {
+	// Match returns true if the event should fire an alert.
+	Match func(*Event) bool
+
+	// Message is the alert message.
+	Message string
+
+	// Cooldown is the duration the rule is suppressed after firing.
+	Cooldown time.Duration
+
+	// Notify is called with the fired alert.
+	Notify func(alert Alert)
+}
+
+// AlertMonitor subscribes to a stream and fires alerts for events matching
+// a set of rules.
+type AlertMonitor struct {
+	conn   Conn
+	stream string
+	rules  []AlertRule
+
+	mux   sync.Mutex
+	fired []time.Time
+	sub   Subscription
+}
+
+// Start subscribes to the stream. The monitor is stopped when the context
+// is done.
+func (m *AlertMonitor) Start(ctx context.Context) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if m.sub != nil {
+		return errors.New("alert monitor already started")
+	}
+
+	sub, err := m.conn.Subscribe(m.stream, m.handle, nil)
+	if err != nil {
+		return err
+	}
+
+	m.sub = sub
+
+	go func() {
+		<-ctx.Done()
+		m.Stop()
+	}()
+
+	return nil
+}
+
+// Stop closes the subscription.
+func (m *AlertMonitor) Stop() error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if m.sub == nil {
+		return nil
+	}
+
+	err := m.sub.Close()
+	m.sub = nil
+
+	return err
+}
+
+func (m *AlertMonitor) handle(ctx context.Context, evt *Event) error {
+	now := time.Now()
+
+	for i, r := range m.rules {
+		if !r.Match(evt) {
+			continue
+		}
+
+		m.mux.Lock()
+		suppressed := !m.fired[i].IsZero() && now.Sub(m.fired[i]) < r.Cooldown
+		if !suppressed {
+			m.fired[i] = now
+		}
+		m.mux.Unlock()
+
+		if suppressed {
+			continue
+		}
+
+		r.Notify(Alert{
+			Event:   evt,
+			Message: r.Message,
+			Time:    now,
+		})
+	}
+
+	return nil
+}
+
+// NewAlertMonitor returns a monitor that fires alerts for events on the
+// stream matching the rules.
+func NewAlertMonitor(conn Conn, stream string, rules []AlertRule) *AlertMonitor {
+	return &AlertMonitor{
+		conn:   conn,
+		stream: stream,
+		rules:  rules,
+		fired:  make([]time.Time, len(rules)),
+	}
+}
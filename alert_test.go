@@ -0,0 +1,38 @@
+package eda
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAlertMonitorCooldown(t *testing.T) {
+	var alerts []Alert
+
+	m := NewAlertMonitor(nil, stream, []AlertRule{
+		{
+			Match: func(evt *Event) bool {
+				return evt.Is("failed")
+			},
+			Message:  "something failed",
+			Cooldown: time.Hour,
+			Notify: func(a Alert) {
+				alerts = append(alerts, a)
+			},
+		},
+	})
+
+	ctx := context.Background()
+
+	m.handle(ctx, &Event{Type: "succeeded"})
+	m.handle(ctx, &Event{Type: "failed"})
+	m.handle(ctx, &Event{Type: "failed"})
+
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %d", len(alerts))
+	}
+
+	if alerts[0].Message != "something failed" {
+		t.Fatalf("unexpected message: %s", alerts[0].Message)
+	}
+}
@@ -0,0 +1,836 @@
+/*
+Package kafka provides an eda.Conn backed by Apache Kafka. Streams map to
+topics and events are encoded with the same envelope as the NATS backends.
+
+	conn, err := kafka.Connect([]string{"localhost:9092"}, kafka.WithClientID("orders"))
+*/
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/chop-dbhi/eda"
+	"github.com/nats-io/nuid"
+)
+
+// defaultTimeout is the handler timeout and the time to wait before
+// retrying a failed event if the subscription does not set one.
+const defaultTimeout = 30 * time.Second
+
+// ConnectOptions are options for Connect.
+type ConnectOptions struct {
+	// ClientID identifies the connection. It is set on published events and
+	// is the consumer group of durable subscriptions without a name.
+	ClientID string
+
+	// Logger for internal logging. Defaults to discarding logs.
+	Logger *slog.Logger
+
+	// Config is the base Sarama config. Defaults to sarama.NewConfig.
+	Config *sarama.Config
+}
+
+type ConnectOption func(o *ConnectOptions)
+
+// WithClientID sets the client ID of the connection.
+func WithClientID(id string) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.ClientID = id
+	}
+}
+
+// WithLogger sets the logger for internal logging.
+func WithLogger(l *slog.Logger) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.Logger = l
+	}
+}
+
+// WithConfig sets the base Sarama config.
+func WithConfig(cfg *sarama.Config) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.Config = cfg
+	}
+}
+
+type conn struct {
+	logger  *slog.Logger
+	client  string
+	brokers []string
+	config  *sarama.Config
+
+	kafka    sarama.Client
+	producer sarama.SyncProducer
+}
+
+// Publish sends the event to the topic. The event type is used as the
+// message key so events of the same type are ordered within a partition.
+func (c *conn) Publish(stream string, evt *eda.Event) (string, error) {
+	if evt == nil {
+		evt = &eda.Event{}
+	}
+
+	if evt.Time.IsZero() {
+		evt.Time = time.Now()
+	}
+
+	id := nuid.Next()
+
+	// Copy to set the ID and client without modifying the caller's event.
+	e := *evt
+	e.ID = id
+	e.Client = c.client
+	e.Stream = ""
+
+	b, err := eda.MarshalEvent(&e)
+	if err != nil {
+		return "", err
+	}
+
+	_, _, err = c.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: stream,
+		Key:   sarama.StringEncoder(evt.Type),
+		Value: sarama.ByteEncoder(b),
+	})
+	if err != nil {
+		return id, err
+	}
+
+	return id, nil
+}
+
+// PublishBatch sends the events one at a time, stopping at the first
+// failure. Kafka transactions are not used.
+func (c *conn) PublishBatch(stream string, evts []*eda.Event) ([]string, error) {
+	return eda.PublishEach(c.Publish, stream, evts)
+}
+
+// Request is not supported since Kafka has no reply subjects. It returns
+// eda.ErrRequestNotSupported.
+func (c *conn) Request(ctx context.Context, stream string, evt *eda.Event) (*eda.Reply, error) {
+	return nil, eda.ErrRequestNotSupported
+}
+
+// decode returns the event of the message.
+func decode(msg *sarama.ConsumerMessage) (*eda.Event, error) {
+	evt, err := eda.UnmarshalEvent(msg.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	evt.Stream = msg.Topic
+	evt.Seq = uint64(msg.Offset)
+
+	if evt.AckTime.IsZero() {
+		evt.AckTime = msg.Timestamp
+	}
+
+	return evt, nil
+}
+
+// handler handles messages of a subscription.
+type handler struct {
+	conn   *conn
+	handle eda.Handler
+	opts   *eda.SubscriptionOptions
+
+	// Held while handling if the subscription is serial.
+	serial *sync.Mutex
+
+	pause *pauser
+
+	// Number of expired events skipped without being handled.
+	expired *atomic.Uint64
+}
+
+// pauser blocks processing messages while a subscription is paused.
+type pauser struct {
+	mux     sync.Mutex
+	paused  bool
+	resumed chan struct{}
+}
+
+func (p *pauser) pause() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if !p.paused {
+		p.paused = true
+		p.resumed = make(chan struct{})
+	}
+}
+
+func (p *pauser) resume() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.paused {
+		p.paused = false
+		close(p.resumed)
+	}
+}
+
+func (p *pauser) isPaused() bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	return p.paused
+}
+
+// wait blocks while paused. It returns false if the done channel is
+// closed first.
+func (p *pauser) wait(done <-chan struct{}) bool {
+	p.mux.Lock()
+	if !p.paused {
+		p.mux.Unlock()
+		return true
+	}
+	resumed := p.resumed
+	p.mux.Unlock()
+
+	select {
+	case <-resumed:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// wait returns the time to wait before retrying the event after the
+// failed attempt.
+func (h *handler) wait(attempt int) time.Duration {
+	if n := len(h.opts.RetryBackoff); n > 0 {
+		if attempt > n {
+			attempt = n
+		}
+		return h.opts.RetryBackoff[attempt-1]
+	}
+
+	return h.opts.Timeout
+}
+
+// process handles the message, retrying until it succeeds, it is published
+// to the dead letter stream, or the done channel is closed since Kafka does
+// not redeliver messages. It returns true if the message was handled.
+func (h *handler) process(msg *sarama.ConsumerMessage, done <-chan struct{}) bool {
+	if !h.pause.wait(done) {
+		return false
+	}
+
+	evt, err := decode(msg)
+	if err != nil {
+		h.conn.logger.Error("envelope decode failed",
+			slog.String("stream", msg.Topic),
+			slog.Any("error", err),
+		)
+		return true
+	}
+
+	if evt.IsExpired() {
+		h.expired.Add(1)
+		return true
+	}
+
+	if !h.opts.AcceptsType(evt.Type) {
+		return true
+	}
+
+	if h.serial != nil {
+		h.serial.Lock()
+		defer h.serial.Unlock()
+	}
+
+	attrs := []any{
+		slog.String("stream", evt.Stream),
+		slog.String("event_id", evt.ID),
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := h.call(evt)
+		if err == nil {
+			return true
+		}
+
+		h.conn.logger.Error("handler error", append(attrs, slog.Any("error", err))...)
+
+		if h.deadLetter(evt, err, attempt, attrs) {
+			return true
+		}
+
+		select {
+		case <-done:
+			return false
+		case <-time.After(h.wait(attempt)):
+		}
+	}
+}
+
+// deadLetter publishes the event to the dead letter stream once it has
+// failed on every attempt. It returns true if the event was published, in
+// which case it is marked as handled.
+func (h *handler) deadLetter(evt *eda.Event, err error, attempt int, attrs []any) bool {
+	if h.opts.DeadLetterStream == "" || attempt <= len(h.opts.RetryBackoff) {
+		return false
+	}
+
+	if _, err := h.conn.Publish(h.opts.DeadLetterStream, eda.DeadLetterEvent(evt, err, attempt)); err != nil {
+		h.conn.logger.Error("dead letter publish failed", append(attrs, slog.Any("error", err))...)
+		return false
+	}
+
+	return true
+}
+
+// call calls the handler, recovering a panic as an error.
+func (h *handler) call(evt *eda.Event) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.opts.Timeout)
+	defer cancel()
+
+	if id := evt.CorrelationID(); id != "" {
+		ctx = eda.WithCorrelationID(ctx, id)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("recovered handler panic")
+		}
+	}()
+
+	return h.handle(ctx, evt)
+}
+
+// Setup and Cleanup implement sarama.ConsumerGroupHandler.
+func (h *handler) Setup(sarama.ConsumerGroupSession) error   { return nil }
+func (h *handler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+// ConsumeClaim handles the messages of a partition, marking each one
+// once it is handled so the offset is committed for the group.
+func (h *handler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		if !h.process(msg, sess.Context().Done()) {
+			return nil
+		}
+
+		sess.MarkMessage(msg, "")
+	}
+
+	return nil
+}
+
+// subscription stops consuming when closed.
+type subscription struct {
+	cancel func()
+	done   chan struct{}
+	closer io.Closer
+
+	// Deletes the consumer group offsets on unsubscribe. Nil if the
+	// subscription is not durable.
+	remove func() error
+
+	pause   *pauser
+	expired *atomic.Uint64
+}
+
+func (s *subscription) Close() error {
+	s.cancel()
+	<-s.done
+	return s.closer.Close()
+}
+
+// Unsubscribe closes the subscription and deletes the offsets of a
+// durable subscription.
+func (s *subscription) Unsubscribe() error {
+	if err := s.Close(); err != nil {
+		return err
+	}
+
+	if s.remove != nil {
+		return s.remove()
+	}
+
+	return nil
+}
+
+// Pause stops consuming messages once the messages being handled return.
+// Messages are not buffered, so the PauseBuffer option does not apply.
+func (s *subscription) Pause() error {
+	select {
+	case <-s.done:
+		return eda.ErrSubscriptionClosed
+	default:
+	}
+
+	s.pause.pause()
+	return nil
+}
+
+func (s *subscription) Resume() error {
+	select {
+	case <-s.done:
+		return eda.ErrSubscriptionClosed
+	default:
+	}
+
+	s.pause.resume()
+	return nil
+}
+
+func (s *subscription) IsPaused() bool {
+	return s.pause.isPaused()
+}
+
+// Expired returns the number of expired events skipped without being
+// handled.
+func (s *subscription) Expired() uint64 {
+	return s.expired.Load()
+}
+
+// GetLatest is not supported by this backend.
+func (s *subscription) GetLatest(key string) (*eda.Event, bool) {
+	return nil, false
+}
+
+// checkOptions returns an error for subscription options that are not
+// supported by this backend.
+func checkOptions(opts *eda.SubscriptionOptions) error {
+	switch {
+	case opts.ManualACK:
+		return &eda.ErrUnsupportedOption{Option: "ManualACK"}
+	case opts.LastValueCache:
+		return &eda.ErrUnsupportedOption{Option: "LastValueCache"}
+	case opts.DeduplicateWindow > 0:
+		return &eda.ErrUnsupportedOption{Option: "DeduplicateWindow"}
+	}
+
+	return nil
+}
+
+// Subscribe consumes the topic. Durable subscriptions use a consumer group
+// named by opts.Name or the client ID which commits offsets as events are
+// handled. Otherwise all partitions are consumed without committing
+// offsets, starting at the StartSeq offset or StartTime if set. Since
+// Kafka does not redeliver messages, a failed event is retried after the
+// backoff or timeout until it succeeds or is published to the
+// DeadLetterStream. The ManualACK, LastValueCache, and DeduplicateWindow
+// options are not supported.
+func (c *conn) Subscribe(stream string, handle eda.Handler, opts *eda.SubscriptionOptions) (eda.Subscription, error) {
+	if opts == nil {
+		opts = &eda.SubscriptionOptions{}
+	} else {
+		opts = &(*opts)
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.Durable && (opts.StartSeq > 0 || opts.StartTime != nil) {
+		return nil, errors.New("kafka: StartSeq and StartTime are not supported for durable subscriptions")
+	}
+
+	if err := checkOptions(opts); err != nil {
+		return nil, err
+	}
+
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	h := &handler{
+		conn:    c,
+		handle:  handle,
+		opts:    opts,
+		pause:   &pauser{},
+		expired: new(atomic.Uint64),
+	}
+
+	if opts.Serial {
+		h.serial = &sync.Mutex{}
+	}
+
+	offset := sarama.OffsetNewest
+	if opts.Backfill {
+		offset = sarama.OffsetOldest
+	}
+
+	if opts.Durable {
+		return c.subscribeGroup(stream, h, offset)
+	}
+
+	return c.subscribePartitions(stream, h, offset)
+}
+
+func (c *conn) subscribeGroup(stream string, h *handler, offset int64) (eda.Subscription, error) {
+	group := h.opts.Name
+	if group == "" {
+		group = c.client
+	}
+
+	if h.opts.Reset {
+		if err := c.deleteGroup(group); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := *c.config
+	cfg.Consumer.Offsets.Initial = offset
+
+	cg, err := sarama.NewConsumerGroup(c.brokers, group, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sub := &subscription{
+		cancel: cancel,
+		done:   make(chan struct{}),
+		closer: cg,
+		remove: func() error {
+			return c.deleteGroup(group)
+		},
+		pause:   h.pause,
+		expired: h.expired,
+	}
+
+	go func() {
+		defer close(sub.done)
+
+		// Consume returns when the group rebalances.
+		for ctx.Err() == nil {
+			if err := cg.Consume(ctx, []string{stream}, h); err != nil {
+				c.logger.Error("consumer group error",
+					slog.String("stream", stream),
+					slog.String("group", group),
+					slog.Any("error", err),
+				)
+				return
+			}
+		}
+	}()
+
+	return sub, nil
+}
+
+func (c *conn) subscribePartitions(stream string, h *handler, offset int64) (eda.Subscription, error) {
+	consumer, err := sarama.NewConsumerFromClient(c.kafka)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions, err := consumer.Partitions(stream)
+	if err != nil {
+		consumer.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sub := &subscription{
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		closer:  consumer,
+		pause:   h.pause,
+		expired: h.expired,
+	}
+
+	var wg sync.WaitGroup
+
+	for _, p := range partitions {
+		start := offset
+
+		// Sequences are offsets within each partition.
+		switch {
+		case h.opts.StartSeq > 0:
+			start = int64(h.opts.StartSeq)
+		case h.opts.StartTime != nil:
+			// Returns the newest offset if no events are after the time.
+			start, err = c.kafka.GetOffset(stream, p, h.opts.StartTime.UnixMilli())
+		}
+
+		var pc sarama.PartitionConsumer
+		if err == nil {
+			pc, err = consumer.ConsumePartition(stream, p, start)
+		}
+		if err != nil {
+			cancel()
+			wg.Wait()
+			consumer.Close()
+			return nil, err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer pc.Close()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case msg := <-pc.Messages():
+					if !h.process(msg, ctx.Done()) {
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(sub.done)
+	}()
+
+	return sub, nil
+}
+
+// Read reads each partition of the topic in turn from the oldest offset
+// or the offset of the From option to the newest offset at the time of the
+// read. Sequences in the options are offsets within each partition, so
+// events are ordered within a partition but not across partitions.
+func (c *conn) Read(stream string, opts *eda.ReadOptions) (eda.EventIterator, error) {
+	if opts == nil {
+		opts = &eda.ReadOptions{}
+	}
+
+	// The topic is not created until the first event is published.
+	partitions, err := c.kafka.Partitions(stream)
+	if errors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+		return eda.NewEventIterator(func(context.Context) (*eda.Event, error) {
+			return nil, io.EOF
+		}, nil, opts), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(c.kafka)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		pc   sarama.PartitionConsumer
+		last int64
+	)
+
+	// open consumes the next partition with events in the range. It
+	// returns false once all partitions have been read.
+	open := func() (bool, error) {
+		for len(partitions) > 0 {
+			p := partitions[0]
+			partitions = partitions[1:]
+
+			oldest, err := c.kafka.GetOffset(stream, p, sarama.OffsetOldest)
+			if err != nil {
+				return false, err
+			}
+
+			newest, err := c.kafka.GetOffset(stream, p, sarama.OffsetNewest)
+			if err != nil {
+				return false, err
+			}
+
+			start := oldest
+			if opts.From.Seq > 0 {
+				start = int64(opts.From.Seq)
+			} else if !opts.From.Time.IsZero() {
+				// Returns the newest offset if no events are after the time.
+				start, err = c.kafka.GetOffset(stream, p, opts.From.Time.UnixMilli())
+				if err != nil {
+					return false, err
+				}
+			}
+
+			if start < oldest {
+				start = oldest
+			}
+
+			last = newest - 1
+			if opts.To.Seq > 0 && int64(opts.To.Seq) < last {
+				last = int64(opts.To.Seq)
+			}
+
+			if start > last {
+				continue
+			}
+
+			pc, err = consumer.ConsumePartition(stream, p, start)
+			if err != nil {
+				return false, err
+			}
+
+			return true, nil
+		}
+
+		return false, nil
+	}
+
+	next := func(ctx context.Context) (*eda.Event, error) {
+		for {
+			if pc == nil {
+				ok, err := open()
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					return nil, io.EOF
+				}
+			}
+
+			select {
+			case msg := <-pc.Messages():
+				if msg.Offset >= last {
+					pc.Close()
+					pc = nil
+				}
+
+				if msg.Offset > last {
+					continue
+				}
+
+				return decode(msg)
+
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	close := func() error {
+		if pc != nil {
+			pc.Close()
+		}
+		return consumer.Close()
+	}
+
+	return eda.NewEventIterator(next, close, opts), nil
+}
+
+// deleteGroup deletes the consumer group and its offsets.
+func (c *conn) deleteGroup(group string) error {
+	admin, err := sarama.NewClusterAdminFromClient(c.kafka)
+	if err != nil {
+		return err
+	}
+
+	// The admin is not closed since that closes the shared client.
+	err = admin.DeleteConsumerGroup(group)
+	if errors.Is(err, sarama.ErrGroupIDNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// StreamStats returns the message count of the topic as the difference of
+// the oldest and newest offsets of each partition. Sequences are offsets
+// of the first partition. Times are not provided.
+func (c *conn) StreamStats(ctx context.Context, stream string) (*eda.StreamStats, error) {
+	partitions, err := c.kafka.Partitions(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &eda.StreamStats{}
+
+	for i, p := range partitions {
+		oldest, err := c.kafka.GetOffset(stream, p, sarama.OffsetOldest)
+		if err != nil {
+			return nil, err
+		}
+
+		newest, err := c.kafka.GetOffset(stream, p, sarama.OffsetNewest)
+		if err != nil {
+			return nil, err
+		}
+
+		stats.MsgCount += newest - oldest
+
+		if i == 0 && newest > oldest {
+			stats.FirstSeq = uint64(oldest)
+			stats.LastSeq = uint64(newest - 1)
+		}
+	}
+
+	return stats, nil
+}
+
+func (c *conn) MultiStreamStats(ctx context.Context, streams []string) (map[string]*eda.StreamStats, error) {
+	m := make(map[string]*eda.StreamStats, len(streams))
+
+	for _, stream := range streams {
+		stats, err := c.StreamStats(ctx, stream)
+		if err != nil {
+			return nil, err
+		}
+
+		m[stream] = stats
+	}
+
+	return m, nil
+}
+
+// Close closes the producer and client.
+func (c *conn) Close() error {
+	if err := c.producer.Close(); err != nil {
+		c.logger.Error("producer close error", slog.Any("error", err))
+	}
+
+	return c.kafka.Close()
+}
+
+// Connect establishes a connection to the Kafka brokers.
+func Connect(brokers []string, opts ...ConnectOption) (eda.Conn, error) {
+	o := &ConnectOptions{}
+
+	for _, f := range opts {
+		f(o)
+	}
+
+	if o.Logger == nil {
+		o.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	if o.ClientID == "" {
+		o.ClientID = nuid.Next()
+	}
+
+	cfg := o.Config
+	if cfg == nil {
+		cfg = sarama.NewConfig()
+	}
+
+	cfg.ClientID = o.ClientID
+
+	// Required by the sync producer.
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.Partitioner = sarama.NewHashPartitioner
+
+	client, err := sarama.NewClient(brokers, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &conn{
+		logger:   o.Logger.With(slog.String("client", o.ClientID)),
+		client:   o.ClientID,
+		brokers:  brokers,
+		config:   cfg,
+		kafka:    client,
+		producer: producer,
+	}, nil
+}
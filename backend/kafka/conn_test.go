@@ -0,0 +1,283 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/chop-dbhi/eda"
+)
+
+func TestPublish(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+
+	var msg *sarama.ProducerMessage
+
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(m *sarama.ProducerMessage) error {
+		msg = m
+		return nil
+	})
+
+	c := &conn{
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		client:   "test-client",
+		producer: producer,
+	}
+
+	id, err := c.Publish("orders", &eda.Event{
+		Type: "order-placed",
+		Data: eda.String("foo"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Topic != "orders" {
+		t.Errorf("expected orders topic, got %s", msg.Topic)
+	}
+
+	key, _ := msg.Key.Encode()
+	if string(key) != "order-placed" {
+		t.Errorf("expected event type as key, got %s", key)
+	}
+
+	b, _ := msg.Value.Encode()
+	ts := time.Now()
+
+	evt, err := decode(&sarama.ConsumerMessage{
+		Topic:     "orders",
+		Value:     b,
+		Timestamp: ts,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if evt.ID != id || evt.Client != "test-client" || evt.Stream != "orders" || !evt.AckTime.Equal(ts) {
+		t.Errorf("unexpected event: %+v", evt)
+	}
+
+	var v string
+	if err := evt.Data.Decode(&v); err != nil || v != "foo" {
+		t.Errorf("unexpected data %q: %v", v, err)
+	}
+}
+
+func TestHandlerRetry(t *testing.T) {
+	var calls int
+
+	h := &handler{
+		conn: &conn{
+			logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		},
+		handle: func(ctx context.Context, evt *eda.Event) error {
+			calls++
+			if calls < 3 {
+				return errors.New("failed")
+			}
+			return nil
+		},
+		opts: &eda.SubscriptionOptions{
+			Timeout:      time.Second,
+			RetryBackoff: []time.Duration{time.Millisecond},
+		},
+		pause:   &pauser{},
+		expired: new(atomic.Uint64),
+	}
+
+	b, _ := eda.MarshalEvent(&eda.Event{ID: "1"})
+
+	if !h.process(&sarama.ConsumerMessage{Value: b}, nil) {
+		t.Fatal("expected message to be handled")
+	}
+
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestHandlerDeadLetter(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+
+	var msg *sarama.ProducerMessage
+
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(m *sarama.ProducerMessage) error {
+		msg = m
+		return nil
+	})
+
+	var calls int
+
+	h := &handler{
+		conn: &conn{
+			logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+			producer: producer,
+		},
+		handle: func(ctx context.Context, evt *eda.Event) error {
+			calls++
+			return errors.New("failed")
+		},
+		opts: &eda.SubscriptionOptions{
+			Timeout:          time.Second,
+			RetryBackoff:     []time.Duration{time.Millisecond},
+			DeadLetterStream: "orders-dlq",
+		},
+		pause:   &pauser{},
+		expired: new(atomic.Uint64),
+	}
+
+	b, _ := eda.MarshalEvent(&eda.Event{ID: "1", Stream: "orders"})
+
+	if !h.process(&sarama.ConsumerMessage{Topic: "orders", Value: b}, nil) {
+		t.Fatal("expected message to be dead lettered")
+	}
+
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+
+	if msg.Topic != "orders-dlq" {
+		t.Fatalf("expected dead letter topic, got %s", msg.Topic)
+	}
+
+	v, _ := msg.Value.Encode()
+	evt, _ := eda.UnmarshalEvent(v)
+
+	if evt.Meta["dlq.original_id"] != "1" || evt.Meta["dlq.original_stream"] != "orders" || evt.Meta["dlq.attempt_count"] != "2" {
+		t.Errorf("unexpected dead letter meta: %v", evt.Meta)
+	}
+}
+
+func TestHandlerPause(t *testing.T) {
+	handled := make(chan struct{}, 1)
+
+	h := &handler{
+		handle: func(ctx context.Context, evt *eda.Event) error {
+			handled <- struct{}{}
+			return nil
+		},
+		opts:    &eda.SubscriptionOptions{Timeout: time.Second},
+		pause:   &pauser{},
+		expired: new(atomic.Uint64),
+	}
+
+	b, _ := eda.MarshalEvent(&eda.Event{ID: "1"})
+	msg := &sarama.ConsumerMessage{Value: b}
+
+	h.pause.pause()
+
+	// Closing the done channel while paused stops processing.
+	done := make(chan struct{})
+	close(done)
+
+	if h.process(msg, done) {
+		t.Fatal("expected message to not be handled")
+	}
+
+	result := make(chan bool)
+	go func() {
+		result <- h.process(msg, nil)
+	}()
+
+	select {
+	case <-handled:
+		t.Fatal("message handled while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	h.pause.resume()
+
+	if !<-result {
+		t.Fatal("expected message to be handled")
+	}
+
+	<-handled
+}
+
+func TestSubscribeUnsupported(t *testing.T) {
+	c := &conn{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	handle := func(ctx context.Context, evt *eda.Event) error {
+		return nil
+	}
+
+	for _, opts := range []*eda.SubscriptionOptions{
+		{ManualACK: true},
+		{LastValueCache: true},
+		{DeduplicateWindow: time.Minute},
+	} {
+		_, err := c.Subscribe("orders", handle, opts)
+
+		var uerr *eda.ErrUnsupportedOption
+		if !errors.As(err, &uerr) {
+			t.Errorf("expected ErrUnsupportedOption, got %v", err)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	fetch := sarama.NewMockFetchResponse(t, 1)
+
+	for i, typ := range []string{"a", "b", "c"} {
+		b, err := eda.MarshalEvent(&eda.Event{ID: typ, Type: typ})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fetch.SetMessage("orders", 0, int64(i), sarama.ByteEncoder(b))
+	}
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("orders", 0, broker.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetOldest, 0).
+			SetOffset("orders", 0, sarama.OffsetNewest, 3),
+		"FetchRequest": fetch,
+	})
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, sarama.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	c := &conn{
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		kafka:  client,
+	}
+
+	it, err := c.Read("orders", &eda.ReadOptions{From: eda.AtSeq(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var types string
+	for {
+		evt, err := it.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		types += evt.Type
+	}
+
+	if types != "bc" {
+		t.Errorf("expected events bc, got %s", types)
+	}
+}
@@ -0,0 +1,158 @@
+package kinesis
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// CheckpointStore persists the sequence number of the last record handled
+// in each shard by durable subscriptions. Keys have the form
+// consumer/stream/shard.
+type CheckpointStore interface {
+	// Get returns the sequence number for the key or an empty string if
+	// none is set.
+	Get(ctx context.Context, key string) (string, error)
+
+	// Set sets the sequence number for the key.
+	Set(ctx context.Context, key string, seq string) error
+
+	// Delete deletes the sequence numbers of the keys with the prefix.
+	Delete(ctx context.Context, prefix string) error
+}
+
+// MemCheckpointStore stores sequence numbers in memory.
+type MemCheckpointStore struct {
+	mux  sync.RWMutex
+	seqs map[string]string
+}
+
+func (s *MemCheckpointStore) Get(ctx context.Context, key string) (string, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return s.seqs[key], nil
+}
+
+func (s *MemCheckpointStore) Set(ctx context.Context, key string, seq string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.seqs[key] = seq
+
+	return nil
+}
+
+func (s *MemCheckpointStore) Delete(ctx context.Context, prefix string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for k := range s.seqs {
+		if strings.HasPrefix(k, prefix) {
+			delete(s.seqs, k)
+		}
+	}
+
+	return nil
+}
+
+// NewMemCheckpointStore returns an empty in-memory checkpoint store.
+func NewMemCheckpointStore() *MemCheckpointStore {
+	return &MemCheckpointStore{
+		seqs: make(map[string]string),
+	}
+}
+
+// dynamoDBAPI is the subset of the DynamoDB client used by the store.
+type dynamoDBAPI interface {
+	GetItem(ctx context.Context, in *dynamodb.GetItemInput, opts ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, in *dynamodb.PutItemInput, opts ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, in *dynamodb.DeleteItemInput, opts ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Scan(ctx context.Context, in *dynamodb.ScanInput, opts ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// DynamoDBCheckpointStore stores sequence numbers in a DynamoDB table with
+// the string partition key "key". Sequence numbers are stored in the "seq"
+// attribute.
+type DynamoDBCheckpointStore struct {
+	client dynamoDBAPI
+	table  string
+}
+
+func (s *DynamoDBCheckpointStore) Get(ctx context.Context, key string) (string, error) {
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            map[string]types.AttributeValue{"key": &types.AttributeValueMemberS{Value: key}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if seq, ok := out.Item["seq"].(*types.AttributeValueMemberS); ok {
+		return seq.Value, nil
+	}
+
+	return "", nil
+}
+
+func (s *DynamoDBCheckpointStore) Set(ctx context.Context, key string, seq string) error {
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item: map[string]types.AttributeValue{
+			"key": &types.AttributeValueMemberS{Value: key},
+			"seq": &types.AttributeValueMemberS{Value: seq},
+		},
+	})
+
+	return err
+}
+
+// Delete scans the table for the keys with the prefix and deletes them.
+func (s *DynamoDBCheckpointStore) Delete(ctx context.Context, prefix string) error {
+	in := &dynamodb.ScanInput{
+		TableName:                aws.String(s.table),
+		FilterExpression:         aws.String("begins_with(#k, :prefix)"),
+		ExpressionAttributeNames: map[string]string{"#k": "key"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":prefix": &types.AttributeValueMemberS{Value: prefix},
+		},
+		ProjectionExpression: aws.String("#k"),
+	}
+
+	for {
+		out, err := s.client.Scan(ctx, in)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range out.Items {
+			_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+				TableName: aws.String(s.table),
+				Key:       map[string]types.AttributeValue{"key": item["key"]},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+
+		in.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// NewDynamoDBCheckpointStore returns a checkpoint store backed by the
+// DynamoDB table.
+func NewDynamoDBCheckpointStore(client *dynamodb.Client, table string) *DynamoDBCheckpointStore {
+	return &DynamoDBCheckpointStore{
+		client: client,
+		table:  table,
+	}
+}
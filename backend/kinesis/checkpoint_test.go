@@ -0,0 +1,91 @@
+package kinesis
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// fakeDynamoDB is a table keyed by the "key" attribute.
+type fakeDynamoDB struct {
+	items map[string]map[string]types.AttributeValue
+}
+
+func itemKey(item map[string]types.AttributeValue) string {
+	return item["key"].(*types.AttributeValueMemberS).Value
+}
+
+func (f *fakeDynamoDB) GetItem(ctx context.Context, in *dynamodb.GetItemInput, opts ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: f.items[itemKey(in.Key)]}, nil
+}
+
+func (f *fakeDynamoDB) PutItem(ctx context.Context, in *dynamodb.PutItemInput, opts ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.items[itemKey(in.Item)] = in.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeDynamoDB) DeleteItem(ctx context.Context, in *dynamodb.DeleteItemInput, opts ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	delete(f.items, itemKey(in.Key))
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (f *fakeDynamoDB) Scan(ctx context.Context, in *dynamodb.ScanInput, opts ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	prefix := in.ExpressionAttributeValues[":prefix"].(*types.AttributeValueMemberS).Value
+
+	out := &dynamodb.ScanOutput{}
+	for k, item := range f.items {
+		if strings.HasPrefix(k, prefix) {
+			out.Items = append(out.Items, item)
+		}
+	}
+
+	return out, nil
+}
+
+func testCheckpointStore(t *testing.T, s CheckpointStore) {
+	ctx := context.Background()
+
+	if seq, err := s.Get(ctx, "c/orders/shard-0"); err != nil || seq != "" {
+		t.Fatalf("expected no checkpoint, got %q: %v", seq, err)
+	}
+
+	for _, key := range []string{"c/orders/shard-0", "c/orders/shard-1", "d/orders/shard-0"} {
+		if err := s.Set(ctx, key, "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Set(ctx, "c/orders/shard-0", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if seq, _ := s.Get(ctx, "c/orders/shard-0"); seq != "2" {
+		t.Errorf("expected checkpoint 2, got %q", seq)
+	}
+
+	if err := s.Delete(ctx, "c/orders/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if seq, _ := s.Get(ctx, "c/orders/shard-1"); seq != "" {
+		t.Errorf("expected checkpoint to be deleted, got %q", seq)
+	}
+
+	if seq, _ := s.Get(ctx, "d/orders/shard-0"); seq != "1" {
+		t.Errorf("expected other consumer's checkpoint to be kept, got %q", seq)
+	}
+}
+
+func TestMemCheckpointStore(t *testing.T) {
+	testCheckpointStore(t, NewMemCheckpointStore())
+}
+
+func TestDynamoDBCheckpointStore(t *testing.T) {
+	testCheckpointStore(t, &DynamoDBCheckpointStore{
+		client: &fakeDynamoDB{items: make(map[string]map[string]types.AttributeValue)},
+		table:  "checkpoints",
+	})
+}
@@ -0,0 +1,1012 @@
+/*
+Package kinesis provides an eda.Conn backed by Amazon Kinesis Data Streams.
+Streams map to Kinesis streams in the account and region of the stream ARN
+passed to Connect, and events are encoded with the same envelope as the
+NATS backends.
+
+	conn, err := kinesis.Connect("arn:aws:kinesis:us-east-1:123456789012:stream/orders")
+*/
+package kinesis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	"github.com/chop-dbhi/eda"
+	"github.com/nats-io/nuid"
+)
+
+const (
+	// defaultTimeout is the handler timeout and the time to wait before
+	// retrying a failed event if the subscription does not set one.
+	defaultTimeout = 30 * time.Second
+
+	// readLimit is the number of records read from a shard at a time.
+	readLimit = 100
+
+	// pollInterval is the time to wait between checks of the status of a
+	// consumer and before retrying a failed call.
+	pollInterval = time.Second
+)
+
+var (
+	// errSeqNotSupported is returned for options with sequences, since
+	// Kinesis sequence numbers do not fit in an event sequence.
+	errSeqNotSupported = fmt.Errorf("kinesis: %w", eda.ErrSeqNotSupported)
+
+	// errStatsNotSupported is returned by StreamStats since Kinesis does
+	// not provide record counts for streams.
+	errStatsNotSupported = errors.New("kinesis: stream stats are not supported")
+)
+
+// kinesisAPI is the subset of the Kinesis client used by the connection.
+type kinesisAPI interface {
+	PutRecord(ctx context.Context, in *kinesis.PutRecordInput, opts ...func(*kinesis.Options)) (*kinesis.PutRecordOutput, error)
+	ListShards(ctx context.Context, in *kinesis.ListShardsInput, opts ...func(*kinesis.Options)) (*kinesis.ListShardsOutput, error)
+	GetShardIterator(ctx context.Context, in *kinesis.GetShardIteratorInput, opts ...func(*kinesis.Options)) (*kinesis.GetShardIteratorOutput, error)
+	GetRecords(ctx context.Context, in *kinesis.GetRecordsInput, opts ...func(*kinesis.Options)) (*kinesis.GetRecordsOutput, error)
+	RegisterStreamConsumer(ctx context.Context, in *kinesis.RegisterStreamConsumerInput, opts ...func(*kinesis.Options)) (*kinesis.RegisterStreamConsumerOutput, error)
+	DescribeStreamConsumer(ctx context.Context, in *kinesis.DescribeStreamConsumerInput, opts ...func(*kinesis.Options)) (*kinesis.DescribeStreamConsumerOutput, error)
+	DeregisterStreamConsumer(ctx context.Context, in *kinesis.DeregisterStreamConsumerInput, opts ...func(*kinesis.Options)) (*kinesis.DeregisterStreamConsumerOutput, error)
+	SubscribeToShard(ctx context.Context, in *kinesis.SubscribeToShardInput, opts ...func(*kinesis.Options)) (*kinesis.SubscribeToShardOutput, error)
+}
+
+// ConnectOptions are options for Connect.
+type ConnectOptions struct {
+	// ClientID identifies the connection. It is set on published events and
+	// is the consumer name of durable subscriptions without a name.
+	ClientID string
+
+	// Logger for internal logging. Defaults to discarding logs.
+	Logger *slog.Logger
+
+	// Config is the AWS config. Defaults to the default config with the
+	// region of the stream ARN.
+	Config *aws.Config
+
+	// Checkpoints stores the position of durable subscriptions in each
+	// shard. Defaults to an in-memory store.
+	Checkpoints CheckpointStore
+}
+
+type ConnectOption func(o *ConnectOptions)
+
+// WithClientID sets the client ID of the connection.
+func WithClientID(id string) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.ClientID = id
+	}
+}
+
+// WithLogger sets the logger for internal logging.
+func WithLogger(l *slog.Logger) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.Logger = l
+	}
+}
+
+// WithConfig sets the AWS config.
+func WithConfig(cfg aws.Config) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.Config = &cfg
+	}
+}
+
+// WithCheckpointStore sets the checkpoint store of durable subscriptions.
+func WithCheckpointStore(s CheckpointStore) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.Checkpoints = s
+	}
+}
+
+type conn struct {
+	logger      *slog.Logger
+	client      string
+	arn         arn.ARN
+	kinesis     kinesisAPI
+	checkpoints CheckpointStore
+}
+
+// streamARN returns the ARN of the Kinesis stream of the stream.
+func (c *conn) streamARN(stream string) string {
+	a := c.arn
+	a.Resource = "stream/" + stream
+	return a.String()
+}
+
+// Publish puts the event as a record in the stream. The event aggregate is
+// used as the partition key so events of an aggregate are ordered within a
+// shard. Events without an aggregate use the event ID.
+func (c *conn) Publish(stream string, evt *eda.Event) (string, error) {
+	if evt == nil {
+		evt = &eda.Event{}
+	}
+
+	if evt.Time.IsZero() {
+		evt.Time = time.Now()
+	}
+
+	id := nuid.Next()
+
+	// Copy to set the ID and client without modifying the caller's event.
+	e := *evt
+	e.ID = id
+	e.Client = c.client
+	e.Stream = ""
+
+	b, err := eda.MarshalEvent(&e)
+	if err != nil {
+		return "", err
+	}
+
+	key := evt.Aggregate
+	if key == "" {
+		key = id
+	}
+
+	_, err = c.kinesis.PutRecord(context.Background(), &kinesis.PutRecordInput{
+		StreamARN:    aws.String(c.streamARN(stream)),
+		Data:         b,
+		PartitionKey: aws.String(key),
+	})
+	if err != nil {
+		return id, err
+	}
+
+	return id, nil
+}
+
+// PublishBatch puts the events one at a time, stopping at the first
+// failure.
+func (c *conn) PublishBatch(stream string, evts []*eda.Event) ([]string, error) {
+	return eda.PublishEach(c.Publish, stream, evts)
+}
+
+// Request is not supported by this backend. It returns
+// eda.ErrRequestNotSupported.
+func (c *conn) Request(ctx context.Context, stream string, evt *eda.Event) (*eda.Reply, error) {
+	return nil, eda.ErrRequestNotSupported
+}
+
+// decode returns the event of the record.
+func decode(stream string, r types.Record) (*eda.Event, error) {
+	evt, err := eda.UnmarshalEvent(r.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	evt.Stream = stream
+
+	if evt.AckTime.IsZero() && r.ApproximateArrivalTimestamp != nil {
+		evt.AckTime = *r.ApproximateArrivalTimestamp
+	}
+
+	return evt, nil
+}
+
+// shards returns the IDs of the shards of the stream.
+func (c *conn) shards(ctx context.Context, streamARN string) ([]string, error) {
+	var (
+		ids []string
+		in  = &kinesis.ListShardsInput{StreamARN: aws.String(streamARN)}
+	)
+
+	for {
+		out, err := c.kinesis.ListShards(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range out.Shards {
+			ids = append(ids, aws.ToString(s.ShardId))
+		}
+
+		if out.NextToken == nil {
+			return ids, nil
+		}
+
+		in = &kinesis.ListShardsInput{NextToken: out.NextToken}
+	}
+}
+
+// handler handles the records of a subscription.
+type handler struct {
+	conn   *conn
+	handle eda.Handler
+	opts   *eda.SubscriptionOptions
+	pause  *pauser
+
+	// Held while handling if the subscription is serial.
+	serial *sync.Mutex
+
+	// Number of expired events skipped without being handled.
+	expired *atomic.Uint64
+}
+
+// wait returns the time to wait before retrying the event after the
+// failed attempt.
+func (h *handler) wait(attempt int) time.Duration {
+	if n := len(h.opts.RetryBackoff); n > 0 {
+		if attempt > n {
+			attempt = n
+		}
+		return h.opts.RetryBackoff[attempt-1]
+	}
+
+	return h.opts.Timeout
+}
+
+// process handles the record, retrying until it succeeds, it is published
+// to the dead letter stream, or the done channel is closed since Kinesis
+// does not redeliver records. It returns true if the record was handled.
+func (h *handler) process(stream string, r types.Record, done <-chan struct{}) bool {
+	if !h.pause.wait(done) {
+		return false
+	}
+
+	evt, err := decode(stream, r)
+	if err != nil {
+		h.conn.logger.Error("envelope decode failed",
+			slog.String("stream", stream),
+			slog.Any("error", err),
+		)
+		return true
+	}
+
+	if evt.IsExpired() {
+		h.expired.Add(1)
+		return true
+	}
+
+	if !h.opts.AcceptsType(evt.Type) {
+		return true
+	}
+
+	if h.serial != nil {
+		h.serial.Lock()
+		defer h.serial.Unlock()
+	}
+
+	attrs := []any{
+		slog.String("stream", evt.Stream),
+		slog.String("event_id", evt.ID),
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := h.call(evt)
+		if err == nil {
+			return true
+		}
+
+		h.conn.logger.Error("handler error", append(attrs, slog.Any("error", err))...)
+
+		if h.deadLetter(evt, err, attempt, attrs) {
+			return true
+		}
+
+		select {
+		case <-done:
+			return false
+		case <-time.After(h.wait(attempt)):
+		}
+	}
+}
+
+// deadLetter publishes the event to the dead letter stream once it has
+// failed on every attempt. It returns true if the event was published, in
+// which case it is marked as handled.
+func (h *handler) deadLetter(evt *eda.Event, err error, attempt int, attrs []any) bool {
+	if h.opts.DeadLetterStream == "" || attempt <= len(h.opts.RetryBackoff) {
+		return false
+	}
+
+	if _, err := h.conn.Publish(h.opts.DeadLetterStream, eda.DeadLetterEvent(evt, err, attempt)); err != nil {
+		h.conn.logger.Error("dead letter publish failed", append(attrs, slog.Any("error", err))...)
+		return false
+	}
+
+	return true
+}
+
+// call calls the handler, recovering a panic as an error.
+func (h *handler) call(evt *eda.Event) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.opts.Timeout)
+	defer cancel()
+
+	if id := evt.CorrelationID(); id != "" {
+		ctx = eda.WithCorrelationID(ctx, id)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("recovered handler panic")
+		}
+	}()
+
+	return h.handle(ctx, evt)
+}
+
+// pauser blocks processing records while a subscription is paused.
+type pauser struct {
+	mux     sync.Mutex
+	paused  bool
+	resumed chan struct{}
+}
+
+func (p *pauser) pause() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if !p.paused {
+		p.paused = true
+		p.resumed = make(chan struct{})
+	}
+}
+
+func (p *pauser) resume() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.paused {
+		p.paused = false
+		close(p.resumed)
+	}
+}
+
+func (p *pauser) isPaused() bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	return p.paused
+}
+
+// wait blocks while paused. It returns false if the done channel is
+// closed first.
+func (p *pauser) wait(done <-chan struct{}) bool {
+	p.mux.Lock()
+	if !p.paused {
+		p.mux.Unlock()
+		return true
+	}
+	resumed := p.resumed
+	p.mux.Unlock()
+
+	select {
+	case <-resumed:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// checkpointPrefix returns the prefix of the checkpoint keys of the
+// consumer of the stream.
+func checkpointPrefix(consumer, stream string) string {
+	return consumer + "/" + stream + "/"
+}
+
+// shardConsumer reads the shards of a stream with an enhanced fan-out
+// consumer.
+type shardConsumer struct {
+	conn        *conn
+	handler     *handler
+	stream      string
+	consumer    string
+	consumerARN string
+
+	// Checkpoints of the consumer. Nil if the subscription is not durable.
+	checkpoints CheckpointStore
+
+	wg         sync.WaitGroup
+	startedMux sync.Mutex
+	started    map[string]bool
+}
+
+// start reads the shard in a goroutine if it is not already being read.
+// Child shards are read from the start, since the records of the parent
+// shard have already been read.
+func (sc *shardConsumer) start(ctx context.Context, shard string, child bool) {
+	sc.startedMux.Lock()
+	defer sc.startedMux.Unlock()
+
+	if sc.started[shard] {
+		return
+	}
+
+	sc.started[shard] = true
+	sc.wg.Add(1)
+
+	go func() {
+		defer sc.wg.Done()
+		sc.consume(ctx, shard, child)
+	}()
+}
+
+// position returns the starting position of the shard.
+func (sc *shardConsumer) position(ctx context.Context, shard string, child bool) (*types.StartingPosition, error) {
+	if sc.checkpoints != nil {
+		seq, err := sc.checkpoints.Get(ctx, checkpointPrefix(sc.consumer, sc.stream)+shard)
+		if err != nil {
+			return nil, err
+		}
+
+		if seq != "" {
+			return &types.StartingPosition{
+				Type:           types.ShardIteratorTypeAfterSequenceNumber,
+				SequenceNumber: aws.String(seq),
+			}, nil
+		}
+	}
+
+	opts := sc.handler.opts
+
+	switch {
+	case child || opts.Backfill:
+		return &types.StartingPosition{Type: types.ShardIteratorTypeTrimHorizon}, nil
+	case opts.StartTime != nil:
+		return &types.StartingPosition{
+			Type:      types.ShardIteratorTypeAtTimestamp,
+			Timestamp: opts.StartTime,
+		}, nil
+	}
+
+	return &types.StartingPosition{Type: types.ShardIteratorTypeLatest}, nil
+}
+
+// consume subscribes to the shard until the context is done or the shard
+// is closed. Subscriptions to a shard expire after five minutes, so the
+// shard is subscribed to again after the last record read.
+func (sc *shardConsumer) consume(ctx context.Context, shard string, child bool) {
+	var (
+		pos *types.StartingPosition
+		err error
+	)
+
+	for ctx.Err() == nil {
+		if pos == nil {
+			if pos, err = sc.position(ctx, shard, child); err != nil {
+				if !sc.conn.retry(ctx, sc.stream, err) {
+					return
+				}
+				continue
+			}
+		}
+
+		out, err := sc.conn.kinesis.SubscribeToShard(ctx, &kinesis.SubscribeToShardInput{
+			ConsumerARN:      aws.String(sc.consumerARN),
+			ShardId:          aws.String(shard),
+			StartingPosition: pos,
+		})
+		if err != nil {
+			if !sc.conn.retry(ctx, sc.stream, err) {
+				return
+			}
+			continue
+		}
+
+		es := out.GetStream()
+		seq, closed, ok := sc.handleEvents(ctx, shard, es.Events())
+		es.Close()
+
+		if !ok || closed {
+			return
+		}
+
+		if err := es.Err(); err != nil && !sc.conn.retry(ctx, sc.stream, err) {
+			return
+		}
+
+		if seq != "" {
+			pos = &types.StartingPosition{
+				Type:           types.ShardIteratorTypeAfterSequenceNumber,
+				SequenceNumber: aws.String(seq),
+			}
+		}
+	}
+}
+
+// handleEvents handles the records of the shard events until the channel
+// is closed. It returns the sequence number to continue from and true if
+// the shard was closed, in which case its child shards are started. It
+// returns false if the context is done.
+func (sc *shardConsumer) handleEvents(ctx context.Context, shard string, events <-chan types.SubscribeToShardEventStream) (string, bool, bool) {
+	var seq string
+
+	for {
+		var ev types.SubscribeToShardEventStream
+
+		select {
+		case <-ctx.Done():
+			return seq, false, false
+		case e, open := <-events:
+			if !open {
+				return seq, false, true
+			}
+			ev = e
+		}
+
+		e, ok := ev.(*types.SubscribeToShardEventStreamMemberSubscribeToShardEvent)
+		if !ok {
+			continue
+		}
+
+		for _, r := range e.Value.Records {
+			if !sc.handler.process(sc.stream, r, ctx.Done()) {
+				return seq, false, false
+			}
+		}
+
+		if n := len(e.Value.Records); n > 0 && sc.checkpoints != nil {
+			last := aws.ToString(e.Value.Records[n-1].SequenceNumber)
+			if err := sc.checkpoints.Set(ctx, checkpointPrefix(sc.consumer, sc.stream)+shard, last); err != nil {
+				sc.conn.logger.Error("checkpoint failed",
+					slog.String("stream", sc.stream),
+					slog.String("shard", shard),
+					slog.Any("error", err),
+				)
+			}
+		}
+
+		// A shard without a continuation sequence number is closed.
+		if e.Value.ContinuationSequenceNumber == nil {
+			for _, c := range e.Value.ChildShards {
+				sc.start(ctx, aws.ToString(c.ShardId), true)
+			}
+			return seq, true, true
+		}
+
+		seq = *e.Value.ContinuationSequenceNumber
+	}
+}
+
+// retry logs the error and waits before retrying. It returns false if the
+// context is done.
+func (c *conn) retry(ctx context.Context, stream string, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	c.logger.Error("shard read error",
+		slog.String("stream", stream),
+		slog.Any("error", err),
+	)
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(pollInterval):
+		return true
+	}
+}
+
+// registerConsumer registers the enhanced fan-out consumer, or describes
+// the existing consumer, and waits until it is active. The ARN of the
+// consumer is returned.
+func (c *conn) registerConsumer(ctx context.Context, streamARN, name string) (string, error) {
+	var (
+		consumerARN string
+		status      types.ConsumerStatus
+	)
+
+	out, err := c.kinesis.RegisterStreamConsumer(ctx, &kinesis.RegisterStreamConsumerInput{
+		StreamARN:    aws.String(streamARN),
+		ConsumerName: aws.String(name),
+	})
+
+	var inUse *types.ResourceInUseException
+
+	switch {
+	case err == nil:
+		consumerARN = aws.ToString(out.Consumer.ConsumerARN)
+		status = out.Consumer.ConsumerStatus
+	case errors.As(err, &inUse):
+		desc, err := c.kinesis.DescribeStreamConsumer(ctx, &kinesis.DescribeStreamConsumerInput{
+			StreamARN:    aws.String(streamARN),
+			ConsumerName: aws.String(name),
+		})
+		if err != nil {
+			return "", err
+		}
+		consumerARN = aws.ToString(desc.ConsumerDescription.ConsumerARN)
+		status = desc.ConsumerDescription.ConsumerStatus
+	default:
+		return "", err
+	}
+
+	for status != types.ConsumerStatusActive {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(pollInterval):
+		}
+
+		desc, err := c.kinesis.DescribeStreamConsumer(ctx, &kinesis.DescribeStreamConsumerInput{
+			ConsumerARN: aws.String(consumerARN),
+		})
+		if err != nil {
+			return "", err
+		}
+
+		status = desc.ConsumerDescription.ConsumerStatus
+		if status == types.ConsumerStatusDeleting {
+			return "", fmt.Errorf("kinesis: consumer %s is being deleted", name)
+		}
+	}
+
+	return consumerARN, nil
+}
+
+func (c *conn) deregisterConsumer(consumerARN string) error {
+	_, err := c.kinesis.DeregisterStreamConsumer(context.Background(), &kinesis.DeregisterStreamConsumerInput{
+		ConsumerARN: aws.String(consumerARN),
+	})
+
+	return err
+}
+
+type subscription struct {
+	cancel  func()
+	done    chan struct{}
+	pause   *pauser
+	expired *atomic.Uint64
+
+	// Deregisters the consumer.
+	deregister func() error
+
+	// Deletes the checkpoints of the consumer. Nil if the subscription is
+	// not durable.
+	remove func() error
+}
+
+// Close stops reading the shards. The consumer of a durable subscription
+// is kept so it can be resumed, otherwise it is deregistered.
+func (s *subscription) Close() error {
+	s.cancel()
+	<-s.done
+
+	if s.remove != nil {
+		return nil
+	}
+
+	return s.deregister()
+}
+
+// Unsubscribe stops reading the shards, deregisters the consumer, and
+// deletes the checkpoints of a durable subscription.
+func (s *subscription) Unsubscribe() error {
+	s.cancel()
+	<-s.done
+
+	if err := s.deregister(); err != nil {
+		return err
+	}
+
+	if s.remove != nil {
+		return s.remove()
+	}
+
+	return nil
+}
+
+// Pause stops handling records once the records being handled return.
+// Records are not buffered, so the PauseBuffer option does not apply.
+func (s *subscription) Pause() error {
+	select {
+	case <-s.done:
+		return eda.ErrSubscriptionClosed
+	default:
+	}
+
+	s.pause.pause()
+	return nil
+}
+
+func (s *subscription) Resume() error {
+	select {
+	case <-s.done:
+		return eda.ErrSubscriptionClosed
+	default:
+	}
+
+	s.pause.resume()
+	return nil
+}
+
+func (s *subscription) IsPaused() bool {
+	return s.pause.isPaused()
+}
+
+// Expired returns the number of expired events skipped without being
+// handled.
+func (s *subscription) Expired() uint64 {
+	return s.expired.Load()
+}
+
+// GetLatest is not supported by this backend.
+func (s *subscription) GetLatest(key string) (*eda.Event, bool) {
+	return nil, false
+}
+
+// checkOptions returns an error for subscription options that are not
+// supported by this backend.
+func checkOptions(opts *eda.SubscriptionOptions) error {
+	switch {
+	case opts.ManualACK:
+		return &eda.ErrUnsupportedOption{Option: "ManualACK"}
+	case opts.LastValueCache:
+		return &eda.ErrUnsupportedOption{Option: "LastValueCache"}
+	case opts.DeduplicateWindow > 0:
+		return &eda.ErrUnsupportedOption{Option: "DeduplicateWindow"}
+	}
+
+	return nil
+}
+
+// Subscribe reads each shard of the stream with an enhanced fan-out
+// consumer. Durable subscriptions use a consumer named by opts.Name or the
+// client ID and checkpoint the sequence number of the last record handled
+// in each shard, from which they resume. Other subscriptions register a
+// consumer with a unique name which is deregistered when closed. New
+// subscriptions start at the latest record, the oldest record with
+// Backfill, or the StartTime. StartSeq is not supported. Shards are read
+// concurrently and since Kinesis does not redeliver records, a failed
+// event is retried after the backoff or timeout until it succeeds or is
+// published to the DeadLetterStream. The ManualACK, LastValueCache, and
+// DeduplicateWindow options are not supported.
+func (c *conn) Subscribe(stream string, handle eda.Handler, opts *eda.SubscriptionOptions) (eda.Subscription, error) {
+	if opts == nil {
+		opts = &eda.SubscriptionOptions{}
+	} else {
+		opts = &(*opts)
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.StartSeq > 0 {
+		return nil, errSeqNotSupported
+	}
+
+	if err := checkOptions(opts); err != nil {
+		return nil, err
+	}
+
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	ctx := context.Background()
+	streamARN := c.streamARN(stream)
+
+	name := opts.Name
+	if name == "" {
+		name = c.client
+	}
+
+	if !opts.Durable {
+		name = name + "-" + nuid.Next()
+	}
+
+	prefix := checkpointPrefix(name, stream)
+
+	if opts.Durable && opts.Reset {
+		if err := c.checkpoints.Delete(ctx, prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	shards, err := c.shards(ctx, streamARN)
+	if err != nil {
+		return nil, err
+	}
+
+	consumerARN, err := c.registerConsumer(ctx, streamARN, name)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &handler{
+		conn:    c,
+		handle:  handle,
+		opts:    opts,
+		pause:   &pauser{},
+		expired: new(atomic.Uint64),
+	}
+
+	if opts.Serial {
+		h.serial = &sync.Mutex{}
+	}
+
+	sc := &shardConsumer{
+		conn:        c,
+		handler:     h,
+		stream:      stream,
+		consumer:    name,
+		consumerARN: consumerARN,
+		started:     make(map[string]bool),
+	}
+
+	sctx, cancel := context.WithCancel(ctx)
+
+	sub := &subscription{
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		pause:   h.pause,
+		expired: h.expired,
+		deregister: func() error {
+			return c.deregisterConsumer(consumerARN)
+		},
+	}
+
+	if opts.Durable {
+		sc.checkpoints = c.checkpoints
+		sub.remove = func() error {
+			return c.checkpoints.Delete(context.Background(), prefix)
+		}
+	}
+
+	for _, shard := range shards {
+		sc.start(sctx, shard, false)
+	}
+
+	go func() {
+		sc.wg.Wait()
+		close(sub.done)
+	}()
+
+	return sub, nil
+}
+
+// Read reads each shard of the stream in turn from the oldest record or
+// the time of the From option until the shard is caught up. Records are
+// ordered within a shard but not across shards. Sequence options are not
+// supported.
+func (c *conn) Read(stream string, opts *eda.ReadOptions) (eda.EventIterator, error) {
+	if opts == nil {
+		opts = &eda.ReadOptions{}
+	}
+
+	if opts.From.Seq > 0 || opts.To.Seq > 0 {
+		return nil, errSeqNotSupported
+	}
+
+	streamARN := c.streamARN(stream)
+
+	shards, err := c.shards(context.Background(), streamARN)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		iter *string
+		buf  []types.Record
+	)
+
+	next := func(ctx context.Context) (*eda.Event, error) {
+		for len(buf) == 0 {
+			if iter == nil {
+				if len(shards) == 0 {
+					return nil, io.EOF
+				}
+
+				in := &kinesis.GetShardIteratorInput{
+					StreamARN:         aws.String(streamARN),
+					ShardId:           aws.String(shards[0]),
+					ShardIteratorType: types.ShardIteratorTypeTrimHorizon,
+				}
+				shards = shards[1:]
+
+				if !opts.From.Time.IsZero() {
+					in.ShardIteratorType = types.ShardIteratorTypeAtTimestamp
+					in.Timestamp = aws.Time(opts.From.Time)
+				}
+
+				out, err := c.kinesis.GetShardIterator(ctx, in)
+				if err != nil {
+					return nil, err
+				}
+
+				iter = out.ShardIterator
+			}
+
+			out, err := c.kinesis.GetRecords(ctx, &kinesis.GetRecordsInput{
+				StreamARN:     aws.String(streamARN),
+				ShardIterator: iter,
+				Limit:         aws.Int32(readLimit),
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			buf = out.Records
+			iter = out.NextShardIterator
+
+			// The shard is read once it is closed or caught up.
+			if len(out.Records) == 0 && aws.ToInt64(out.MillisBehindLatest) == 0 {
+				iter = nil
+			}
+		}
+
+		r := buf[0]
+		buf = buf[1:]
+
+		return decode(stream, r)
+	}
+
+	return eda.NewEventIterator(next, nil, opts), nil
+}
+
+// StreamStats is not supported by this backend.
+func (c *conn) StreamStats(ctx context.Context, stream string) (*eda.StreamStats, error) {
+	return nil, errStatsNotSupported
+}
+
+// MultiStreamStats is not supported by this backend.
+func (c *conn) MultiStreamStats(ctx context.Context, streams []string) (map[string]*eda.StreamStats, error) {
+	return nil, errStatsNotSupported
+}
+
+// Close is a no-op since the Kinesis client holds no connections that
+// need to be closed.
+func (c *conn) Close() error {
+	return nil
+}
+
+// Connect returns a connection to Kinesis in the account and region of
+// the stream ARN.
+func Connect(streamARN string, opts ...ConnectOption) (eda.Conn, error) {
+	a, err := arn.Parse(streamARN)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Service != "kinesis" {
+		return nil, fmt.Errorf("kinesis: not a Kinesis stream ARN: %s", streamARN)
+	}
+
+	o := &ConnectOptions{}
+
+	for _, f := range opts {
+		f(o)
+	}
+
+	if o.Logger == nil {
+		o.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	if o.ClientID == "" {
+		o.ClientID = nuid.Next()
+	}
+
+	if o.Checkpoints == nil {
+		o.Checkpoints = NewMemCheckpointStore()
+	}
+
+	if o.Config == nil {
+		cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(a.Region))
+		if err != nil {
+			return nil, err
+		}
+		o.Config = &cfg
+	}
+
+	return &conn{
+		logger:      o.Logger.With(slog.String("client", o.ClientID)),
+		client:      o.ClientID,
+		arn:         a,
+		kinesis:     kinesis.NewFromConfig(*o.Config),
+		checkpoints: o.Checkpoints,
+	}, nil
+}
@@ -0,0 +1,386 @@
+package kinesis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	"github.com/chop-dbhi/eda"
+)
+
+const testARN = "arn:aws:kinesis:us-east-1:123456789012:stream/orders"
+
+// fakeKinesis stores records in memory. Enhanced fan-out streams cannot be
+// constructed outside of the SDK, so SubscribeToShard always fails.
+type fakeKinesis struct {
+	mux          sync.Mutex
+	shards       map[string][]types.Record
+	keys         []string
+	deregistered []string
+}
+
+func (f *fakeKinesis) PutRecord(ctx context.Context, in *kinesis.PutRecordInput, opts ...func(*kinesis.Options)) (*kinesis.PutRecordOutput, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	seq := strconv.Itoa(len(f.shards["shard-0"]))
+	f.shards["shard-0"] = append(f.shards["shard-0"], types.Record{
+		Data:                        in.Data,
+		PartitionKey:                in.PartitionKey,
+		SequenceNumber:              aws.String(seq),
+		ApproximateArrivalTimestamp: aws.Time(time.Now()),
+	})
+	f.keys = append(f.keys, aws.ToString(in.PartitionKey))
+
+	return &kinesis.PutRecordOutput{SequenceNumber: aws.String(seq)}, nil
+}
+
+func (f *fakeKinesis) ListShards(ctx context.Context, in *kinesis.ListShardsInput, opts ...func(*kinesis.Options)) (*kinesis.ListShardsOutput, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	var ids []string
+	for id := range f.shards {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	out := &kinesis.ListShardsOutput{}
+	for _, id := range ids {
+		out.Shards = append(out.Shards, types.Shard{ShardId: aws.String(id)})
+	}
+
+	return out, nil
+}
+
+func (f *fakeKinesis) GetShardIterator(ctx context.Context, in *kinesis.GetShardIteratorInput, opts ...func(*kinesis.Options)) (*kinesis.GetShardIteratorOutput, error) {
+	return &kinesis.GetShardIteratorOutput{
+		ShardIterator: aws.String(aws.ToString(in.ShardId) + ":0"),
+	}, nil
+}
+
+// GetRecords returns one record at a time.
+func (f *fakeKinesis) GetRecords(ctx context.Context, in *kinesis.GetRecordsInput, opts ...func(*kinesis.Options)) (*kinesis.GetRecordsOutput, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	shard, pos, _ := strings.Cut(aws.ToString(in.ShardIterator), ":")
+	i, _ := strconv.Atoi(pos)
+
+	out := &kinesis.GetRecordsOutput{
+		NextShardIterator:  aws.String(fmt.Sprintf("%s:%d", shard, i+1)),
+		MillisBehindLatest: aws.Int64(0),
+	}
+
+	if i < len(f.shards[shard]) {
+		out.Records = f.shards[shard][i : i+1]
+	}
+
+	return out, nil
+}
+
+func (f *fakeKinesis) RegisterStreamConsumer(ctx context.Context, in *kinesis.RegisterStreamConsumerInput, opts ...func(*kinesis.Options)) (*kinesis.RegisterStreamConsumerOutput, error) {
+	return &kinesis.RegisterStreamConsumerOutput{
+		Consumer: &types.Consumer{
+			ConsumerARN:    aws.String(aws.ToString(in.StreamARN) + "/consumer/" + aws.ToString(in.ConsumerName)),
+			ConsumerName:   in.ConsumerName,
+			ConsumerStatus: types.ConsumerStatusActive,
+		},
+	}, nil
+}
+
+func (f *fakeKinesis) DescribeStreamConsumer(ctx context.Context, in *kinesis.DescribeStreamConsumerInput, opts ...func(*kinesis.Options)) (*kinesis.DescribeStreamConsumerOutput, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeKinesis) DeregisterStreamConsumer(ctx context.Context, in *kinesis.DeregisterStreamConsumerInput, opts ...func(*kinesis.Options)) (*kinesis.DeregisterStreamConsumerOutput, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	f.deregistered = append(f.deregistered, aws.ToString(in.ConsumerARN))
+
+	return &kinesis.DeregisterStreamConsumerOutput{}, nil
+}
+
+func (f *fakeKinesis) SubscribeToShard(ctx context.Context, in *kinesis.SubscribeToShardInput, opts ...func(*kinesis.Options)) (*kinesis.SubscribeToShardOutput, error) {
+	return nil, errors.New("not implemented")
+}
+
+func connect(t *testing.T) (*fakeKinesis, *conn) {
+	c, err := Connect(testARN,
+		WithClientID("test-client"),
+		WithConfig(aws.Config{Region: "us-east-1"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeKinesis{shards: map[string][]types.Record{"shard-0": nil}}
+
+	conn := c.(*conn)
+	conn.kinesis = f
+
+	return f, conn
+}
+
+func TestConnect(t *testing.T) {
+	if _, err := Connect("orders"); err == nil {
+		t.Error("expected error for invalid ARN")
+	}
+
+	if _, err := Connect("arn:aws:sqs:us-east-1:123456789012:orders"); err == nil {
+		t.Error("expected error for non-Kinesis ARN")
+	}
+
+	_, conn := connect(t)
+
+	if a := conn.streamARN("payments"); a != "arn:aws:kinesis:us-east-1:123456789012:stream/payments" {
+		t.Errorf("unexpected stream ARN: %s", a)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	f, conn := connect(t)
+
+	id, err := conn.Publish("orders", &eda.Event{Type: "a", Aggregate: "order-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id2, err := conn.Publish("orders", &eda.Event{Type: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Events without an aggregate are partitioned by ID.
+	if f.keys[0] != "order-1" || f.keys[1] != id2 {
+		t.Errorf("unexpected partition keys: %v", f.keys)
+	}
+
+	evt, err := decode("orders", f.shards["shard-0"][0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if evt.ID != id || evt.Client != "test-client" || evt.Stream != "orders" || evt.AckTime.IsZero() {
+		t.Errorf("unexpected event: %+v", evt)
+	}
+}
+
+func TestRead(t *testing.T) {
+	f, conn := connect(t)
+
+	for _, typ := range []string{"a", "b", "c"} {
+		if _, err := conn.Publish("orders", &eda.Event{Type: typ}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Move the last record to a second shard.
+	f.shards["shard-1"] = f.shards["shard-0"][2:]
+	f.shards["shard-0"] = f.shards["shard-0"][:2]
+
+	it, err := conn.Read("orders", &eda.ReadOptions{Types: []string{"a", "c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	var types []string
+
+	for {
+		evt, err := it.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		types = append(types, evt.Type)
+	}
+
+	if len(types) != 2 || types[0] != "a" || types[1] != "c" {
+		t.Errorf("unexpected events: %v", types)
+	}
+
+	if _, err := conn.Read("orders", &eda.ReadOptions{From: eda.AtSeq(1)}); err != errSeqNotSupported {
+		t.Errorf("expected errSeqNotSupported, got %v", err)
+	}
+}
+
+func TestHandleEvents(t *testing.T) {
+	f, conn := connect(t)
+
+	for _, typ := range []string{"a", "b"} {
+		if _, err := conn.Publish("orders", &eda.Event{Type: typ}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var handled []string
+
+	sc := &shardConsumer{
+		conn: conn,
+		handler: &handler{
+			conn: conn,
+			handle: func(ctx context.Context, evt *eda.Event) error {
+				handled = append(handled, evt.Type)
+				return nil
+			},
+			opts:    &eda.SubscriptionOptions{Timeout: time.Second},
+			pause:   &pauser{},
+			expired: new(atomic.Uint64),
+		},
+		stream:      "orders",
+		consumer:    "c",
+		checkpoints: NewMemCheckpointStore(),
+		started:     make(map[string]bool),
+	}
+
+	events := make(chan types.SubscribeToShardEventStream, 2)
+	events <- &types.SubscribeToShardEventStreamMemberSubscribeToShardEvent{
+		Value: types.SubscribeToShardEvent{
+			Records:                    f.shards["shard-0"],
+			ContinuationSequenceNumber: aws.String("1"),
+		},
+	}
+	// The shard is closed and split.
+	events <- &types.SubscribeToShardEventStreamMemberSubscribeToShardEvent{
+		Value: types.SubscribeToShardEvent{
+			ChildShards: []types.ChildShard{
+				{ShardId: aws.String("shard-1")},
+				{ShardId: aws.String("shard-2")},
+			},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	seq, closed, ok := sc.handleEvents(ctx, "shard-0", events)
+	if seq != "1" || !closed || !ok {
+		t.Errorf("unexpected result: %q %v %v", seq, closed, ok)
+	}
+
+	cancel()
+	sc.wg.Wait()
+
+	if len(handled) != 2 || handled[0] != "a" || handled[1] != "b" {
+		t.Errorf("unexpected events: %v", handled)
+	}
+
+	if cp, _ := sc.checkpoints.Get(ctx, "c/orders/shard-0"); cp != "1" {
+		t.Errorf("expected checkpoint 1, got %q", cp)
+	}
+
+	if !sc.started["shard-1"] || !sc.started["shard-2"] {
+		t.Errorf("expected child shards to be started: %v", sc.started)
+	}
+
+	// Child shards start at the oldest record without a checkpoint.
+	pos, err := sc.position(ctx, "shard-1", true)
+	if err != nil || pos.Type != types.ShardIteratorTypeTrimHorizon {
+		t.Errorf("unexpected position: %+v %v", pos, err)
+	}
+
+	pos, err = sc.position(ctx, "shard-0", false)
+	if err != nil || pos.Type != types.ShardIteratorTypeAfterSequenceNumber || aws.ToString(pos.SequenceNumber) != "1" {
+		t.Errorf("unexpected position: %+v %v", pos, err)
+	}
+}
+
+func TestProcessDeadLetter(t *testing.T) {
+	f, conn := connect(t)
+
+	if _, err := conn.Publish("orders", &eda.Event{Type: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &handler{
+		conn: conn,
+		handle: func(ctx context.Context, evt *eda.Event) error {
+			return errors.New("failed")
+		},
+		opts:    &eda.SubscriptionOptions{Timeout: time.Second, DeadLetterStream: "orders.dlq"},
+		pause:   &pauser{},
+		expired: new(atomic.Uint64),
+	}
+
+	if !h.process("orders", f.shards["shard-0"][0], make(chan struct{})) {
+		t.Fatal("expected record to be handled")
+	}
+
+	// The fake puts records on one shard regardless of the stream.
+	evt, err := decode("orders.dlq", f.shards["shard-0"][1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if evt.Type != "a" || evt.Meta["dlq.original_stream"] != "orders" || evt.Meta["dlq.attempt_count"] != "1" {
+		t.Errorf("unexpected event: %+v", evt)
+	}
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	f, conn := connect(t)
+
+	handle := func(ctx context.Context, evt *eda.Event) error {
+		return nil
+	}
+
+	if _, err := conn.Subscribe("orders", handle, &eda.SubscriptionOptions{StartSeq: 1}); err != errSeqNotSupported {
+		t.Errorf("expected errSeqNotSupported, got %v", err)
+	}
+
+	if _, err := conn.Subscribe("orders", handle, &eda.SubscriptionOptions{LastValueCache: true}); err == nil {
+		t.Error("expected error for unsupported option")
+	}
+
+	sub, err := conn.Subscribe("orders", handle, &eda.SubscriptionOptions{Name: "c", Durable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.checkpoints.Set(context.Background(), "c/orders/shard-0", "1")
+
+	// Durable consumers are kept when closed.
+	if err := sub.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.deregistered) != 0 {
+		t.Errorf("unexpected deregistered consumers: %v", f.deregistered)
+	}
+
+	if err := sub.Pause(); err != eda.ErrSubscriptionClosed {
+		t.Errorf("expected ErrSubscriptionClosed, got %v", err)
+	}
+
+	sub, err = conn.Subscribe("orders", handle, &eda.SubscriptionOptions{Name: "c", Durable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sub.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.deregistered) != 1 || f.deregistered[0] != testARN+"/consumer/c" {
+		t.Errorf("unexpected deregistered consumers: %v", f.deregistered)
+	}
+
+	if cp, _ := conn.checkpoints.Get(context.Background(), "c/orders/shard-0"); cp != "" {
+		t.Errorf("expected checkpoint to be deleted, got %q", cp)
+	}
+}
@@ -0,0 +1,611 @@
+/*
+Package pubsub provides an eda.Conn backed by Google Cloud Pub/Sub. Streams
+map to topics, which are created if they do not exist, and events are
+encoded with the same envelope as the NATS backends.
+
+	conn, err := pubsub.Connect("my-project", pubsub.WithClientID("orders"))
+*/
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/chop-dbhi/eda"
+	"github.com/nats-io/nuid"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultTimeout is the handler timeout and the ack deadline of
+	// subscriptions if the subscription does not set one.
+	defaultTimeout = 30 * time.Second
+
+	// Ack deadlines allowed by Pub/Sub.
+	minAckDeadline = 10 * time.Second
+	maxAckDeadline = 600 * time.Second
+
+	// expiration is the time after which subscriptions that are not
+	// durable are deleted by Pub/Sub if they are inactive, for example if
+	// the process exits before closing them. This is the minimum allowed.
+	expiration = 24 * time.Hour
+)
+
+// TypeAttribute is the message attribute holding the event type, which
+// can be used in subscription filters.
+const TypeAttribute = "eda_type"
+
+// errStatsNotSupported is returned by StreamStats since Pub/Sub does not
+// provide message counts for topics.
+var errStatsNotSupported = errors.New("pubsub: stream stats are not supported")
+
+// ConnectOptions are options for Connect.
+type ConnectOptions struct {
+	// ClientID identifies the connection. It is set on published events and
+	// is the subscription ID of durable subscriptions without a name.
+	ClientID string
+
+	// Logger for internal logging. Defaults to discarding logs.
+	Logger *slog.Logger
+
+	// ClientOptions are passed to the Pub/Sub client, for example to set
+	// credentials or the endpoint of an emulator.
+	ClientOptions []option.ClientOption
+}
+
+type ConnectOption func(o *ConnectOptions)
+
+// WithClientID sets the client ID of the connection.
+func WithClientID(id string) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.ClientID = id
+	}
+}
+
+// WithLogger sets the logger for internal logging.
+func WithLogger(l *slog.Logger) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.Logger = l
+	}
+}
+
+// WithClientOptions sets the options of the Pub/Sub client.
+func WithClientOptions(opts ...option.ClientOption) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.ClientOptions = append(o.ClientOptions, opts...)
+	}
+}
+
+type conn struct {
+	logger *slog.Logger
+	client string
+	pubsub *pubsub.Client
+
+	// Topics that are known to exist.
+	topicsMux sync.Mutex
+	topics    map[string]*pubsub.Topic
+}
+
+// topic returns the topic of the stream, creating it if it does not exist.
+func (c *conn) topic(ctx context.Context, stream string) (*pubsub.Topic, error) {
+	c.topicsMux.Lock()
+	defer c.topicsMux.Unlock()
+
+	if t, ok := c.topics[stream]; ok {
+		return t, nil
+	}
+
+	t := c.pubsub.Topic(stream)
+
+	ok, err := t.Exists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		t, err = c.pubsub.CreateTopic(ctx, stream)
+		if status.Code(err) == codes.AlreadyExists {
+			t, err = c.pubsub.Topic(stream), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.topics[stream] = t
+
+	return t, nil
+}
+
+// Publish publishes the event to the topic and waits for the server to
+// acknowledge it. The event type is set as the TypeAttribute attribute.
+func (c *conn) Publish(stream string, evt *eda.Event) (string, error) {
+	if evt == nil {
+		evt = &eda.Event{}
+	}
+
+	if evt.Time.IsZero() {
+		evt.Time = time.Now()
+	}
+
+	id := nuid.Next()
+
+	// Copy to set the ID and client without modifying the caller's event.
+	e := *evt
+	e.ID = id
+	e.Client = c.client
+	e.Stream = ""
+
+	b, err := eda.MarshalEvent(&e)
+	if err != nil {
+		return "", err
+	}
+
+	ctx := context.Background()
+
+	t, err := c.topic(ctx, stream)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = t.Publish(ctx, &pubsub.Message{
+		Data:       b,
+		Attributes: map[string]string{TypeAttribute: evt.Type},
+	}).Get(ctx)
+	if err != nil {
+		return id, err
+	}
+
+	return id, nil
+}
+
+// PublishBatch publishes the events one at a time, stopping at the first
+// failure.
+func (c *conn) PublishBatch(stream string, evts []*eda.Event) ([]string, error) {
+	return eda.PublishEach(c.Publish, stream, evts)
+}
+
+// Request is not supported by this backend. It returns
+// eda.ErrRequestNotSupported.
+func (c *conn) Request(ctx context.Context, stream string, evt *eda.Event) (*eda.Reply, error) {
+	return nil, eda.ErrRequestNotSupported
+}
+
+// decode returns the event of the message.
+func decode(stream string, msg *pubsub.Message) (*eda.Event, error) {
+	evt, err := eda.UnmarshalEvent(msg.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	evt.Stream = stream
+
+	if evt.AckTime.IsZero() {
+		evt.AckTime = msg.PublishTime
+	}
+
+	return evt, nil
+}
+
+// handler handles the messages of a subscription.
+type handler struct {
+	conn   *conn
+	stream string
+	handle eda.Handler
+	opts   *eda.SubscriptionOptions
+	pause  *pauser
+
+	// Number of expired events skipped without being handled.
+	expired *atomic.Uint64
+}
+
+// receive handles the message, acking it if it is handled and nacking it
+// otherwise so it is redelivered.
+func (h *handler) receive(ctx context.Context, msg *pubsub.Message) {
+	if !h.pause.wait(ctx.Done()) {
+		msg.Nack()
+		return
+	}
+
+	evt, err := decode(h.stream, msg)
+	if err != nil {
+		h.conn.logger.Error("envelope decode failed",
+			slog.String("stream", h.stream),
+			slog.Any("error", err),
+		)
+		msg.Ack()
+		return
+	}
+
+	if evt.IsExpired() {
+		h.expired.Add(1)
+		msg.Ack()
+		return
+	}
+
+	if !h.opts.AcceptsType(evt.Type) {
+		msg.Ack()
+		return
+	}
+
+	if err := h.call(evt); err != nil {
+		h.conn.logger.Error("handler error",
+			slog.String("stream", evt.Stream),
+			slog.String("event_id", evt.ID),
+			slog.Any("error", err),
+		)
+		msg.Nack()
+		return
+	}
+
+	msg.Ack()
+}
+
+// call calls the handler, recovering a panic as an error.
+func (h *handler) call(evt *eda.Event) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.opts.Timeout)
+	defer cancel()
+
+	if id := evt.CorrelationID(); id != "" {
+		ctx = eda.WithCorrelationID(ctx, id)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("recovered handler panic")
+		}
+	}()
+
+	return h.handle(ctx, evt)
+}
+
+// pauser blocks handling messages while a subscription is paused.
+type pauser struct {
+	mux     sync.Mutex
+	paused  bool
+	resumed chan struct{}
+}
+
+func (p *pauser) pause() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if !p.paused {
+		p.paused = true
+		p.resumed = make(chan struct{})
+	}
+}
+
+func (p *pauser) resume() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.paused {
+		p.paused = false
+		close(p.resumed)
+	}
+}
+
+func (p *pauser) isPaused() bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	return p.paused
+}
+
+// wait blocks while paused. It returns false if the done channel is
+// closed first.
+func (p *pauser) wait(done <-chan struct{}) bool {
+	p.mux.Lock()
+	if !p.paused {
+		p.mux.Unlock()
+		return true
+	}
+	resumed := p.resumed
+	p.mux.Unlock()
+
+	select {
+	case <-resumed:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+type subscription struct {
+	cancel  func()
+	done    chan struct{}
+	pause   *pauser
+	expired *atomic.Uint64
+	sub     *pubsub.Subscription
+
+	// True if the Pub/Sub subscription is kept on close.
+	durable bool
+}
+
+// Close stops receiving messages. The Pub/Sub subscription of a durable
+// subscription is kept so it can be resumed, otherwise it is deleted.
+func (s *subscription) Close() error {
+	s.cancel()
+	<-s.done
+
+	if s.durable {
+		return nil
+	}
+
+	return s.sub.Delete(context.Background())
+}
+
+// Unsubscribe stops receiving messages and deletes the Pub/Sub
+// subscription.
+func (s *subscription) Unsubscribe() error {
+	s.cancel()
+	<-s.done
+
+	return s.sub.Delete(context.Background())
+}
+
+// Pause stops handling messages. Messages received while paused block
+// until the subscription is resumed, which stops more messages from being
+// pulled once the outstanding message limit is reached.
+func (s *subscription) Pause() error {
+	select {
+	case <-s.done:
+		return eda.ErrSubscriptionClosed
+	default:
+	}
+
+	s.pause.pause()
+	return nil
+}
+
+func (s *subscription) Resume() error {
+	select {
+	case <-s.done:
+		return eda.ErrSubscriptionClosed
+	default:
+	}
+
+	s.pause.resume()
+	return nil
+}
+
+func (s *subscription) IsPaused() bool {
+	return s.pause.isPaused()
+}
+
+// Expired returns the number of expired events skipped without being
+// handled.
+func (s *subscription) Expired() uint64 {
+	return s.expired.Load()
+}
+
+// GetLatest is not supported by this backend.
+func (s *subscription) GetLatest(key string) (*eda.Event, bool) {
+	return nil, false
+}
+
+// checkOptions returns an error for subscription options that are not
+// supported by this backend.
+func checkOptions(opts *eda.SubscriptionOptions) error {
+	switch {
+	case opts.ManualACK:
+		return &eda.ErrUnsupportedOption{Option: "ManualACK"}
+	case opts.LastValueCache:
+		return &eda.ErrUnsupportedOption{Option: "LastValueCache"}
+	case opts.DeduplicateWindow > 0:
+		return &eda.ErrUnsupportedOption{Option: "DeduplicateWindow"}
+	case opts.DeadLetterStream != "":
+		return &eda.ErrUnsupportedOption{Option: "DeadLetterStream"}
+	}
+
+	return nil
+}
+
+// Subscribe creates a pull subscription on the topic. Durable
+// subscriptions are named by opts.Name or the client ID and are kept in
+// Pub/Sub when closed, so they resume where they left off. Other
+// subscriptions are given a unique name and deleted when closed. New
+// subscriptions with Backfill are seeked to the oldest message retained by
+// the topic, which requires topic message retention to be configured, and
+// those with StartTime are seeked to the time. StartSeq is not supported.
+// Events are handled one at a time unless MaxConcurrency is set, and events
+// that fail to be handled are nacked to be redelivered. The ManualACK,
+// LastValueCache, DeduplicateWindow, and DeadLetterStream options are not
+// supported.
+func (c *conn) Subscribe(stream string, handle eda.Handler, opts *eda.SubscriptionOptions) (eda.Subscription, error) {
+	if opts == nil {
+		opts = &eda.SubscriptionOptions{}
+	} else {
+		opts = &(*opts)
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.StartSeq > 0 {
+		return nil, errors.New("pubsub: StartSeq is not supported")
+	}
+
+	if err := checkOptions(opts); err != nil {
+		return nil, err
+	}
+
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	ctx := context.Background()
+
+	t, err := c.topic(ctx, stream)
+	if err != nil {
+		return nil, err
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = c.client
+	}
+
+	cfg := pubsub.SubscriptionConfig{
+		Topic:       t,
+		AckDeadline: opts.Timeout,
+	}
+
+	if cfg.AckDeadline < minAckDeadline {
+		cfg.AckDeadline = minAckDeadline
+	} else if cfg.AckDeadline > maxAckDeadline {
+		cfg.AckDeadline = maxAckDeadline
+	}
+
+	if !opts.Durable {
+		name = name + "-" + nuid.Next()
+		cfg.ExpirationPolicy = expiration
+	}
+
+	sub := c.pubsub.Subscription(name)
+
+	exists := false
+
+	if opts.Durable {
+		if exists, err = sub.Exists(ctx); err != nil {
+			return nil, err
+		}
+
+		if exists && opts.Reset {
+			if err := sub.Delete(ctx); err != nil {
+				return nil, err
+			}
+			exists = false
+		}
+	}
+
+	if !exists {
+		if sub, err = c.pubsub.CreateSubscription(ctx, name, cfg); err != nil {
+			return nil, err
+		}
+
+		var seek time.Time
+
+		switch {
+		case opts.StartTime != nil:
+			seek = *opts.StartTime
+		case opts.Backfill:
+			seek = time.Unix(0, 0)
+		}
+
+		if !seek.IsZero() {
+			if err := sub.SeekToTime(ctx, seek); err != nil {
+				sub.Delete(ctx)
+				return nil, err
+			}
+		}
+	}
+
+	sub.ReceiveSettings.NumGoroutines = 1
+	sub.ReceiveSettings.MaxOutstandingMessages = 1
+
+	if opts.MaxConcurrency > 1 && !opts.Serial {
+		sub.ReceiveSettings.MaxOutstandingMessages = opts.MaxConcurrency
+	}
+
+	h := &handler{
+		conn:    c,
+		stream:  stream,
+		handle:  handle,
+		opts:    opts,
+		pause:   &pauser{},
+		expired: new(atomic.Uint64),
+	}
+
+	rctx, cancel := context.WithCancel(ctx)
+
+	s := &subscription{
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		pause:   h.pause,
+		expired: h.expired,
+		sub:     sub,
+		durable: opts.Durable,
+	}
+
+	go func() {
+		defer close(s.done)
+
+		if err := sub.Receive(rctx, h.receive); err != nil {
+			c.logger.Error("subscription receive error",
+				slog.String("stream", stream),
+				slog.String("subscription", name),
+				slog.Any("error", err),
+			)
+		}
+	}()
+
+	return s, nil
+}
+
+// Read is not supported since Pub/Sub messages can only be received with
+// a subscription. It returns eda.ErrReadNotSupported.
+func (c *conn) Read(stream string, opts *eda.ReadOptions) (eda.EventIterator, error) {
+	return nil, eda.ErrReadNotSupported
+}
+
+// StreamStats is not supported by this backend.
+func (c *conn) StreamStats(ctx context.Context, stream string) (*eda.StreamStats, error) {
+	return nil, errStatsNotSupported
+}
+
+// MultiStreamStats is not supported by this backend.
+func (c *conn) MultiStreamStats(ctx context.Context, streams []string) (map[string]*eda.StreamStats, error) {
+	return nil, errStatsNotSupported
+}
+
+// Close stops the topics, flushing published messages, and closes the
+// client.
+func (c *conn) Close() error {
+	c.topicsMux.Lock()
+	for _, t := range c.topics {
+		t.Stop()
+	}
+	c.topicsMux.Unlock()
+
+	return c.pubsub.Close()
+}
+
+// Connect creates a Pub/Sub client for the project.
+func Connect(projectID string, opts ...ConnectOption) (eda.Conn, error) {
+	o := &ConnectOptions{}
+
+	for _, f := range opts {
+		f(o)
+	}
+
+	if o.Logger == nil {
+		o.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	if o.ClientID == "" {
+		o.ClientID = nuid.Next()
+	}
+
+	client, err := pubsub.NewClient(context.Background(), projectID, o.ClientOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{
+		logger: o.Logger.With(slog.String("client", o.ClientID)),
+		client: o.ClientID,
+		pubsub: client,
+		topics: make(map[string]*pubsub.Topic),
+	}, nil
+}
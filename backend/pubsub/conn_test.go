@@ -0,0 +1,137 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/chop-dbhi/eda"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func connect(t *testing.T) (*pstest.Server, *conn) {
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+
+	c, err := Connect("test-project",
+		WithClientID("test-client"),
+		WithClientOptions(
+			option.WithEndpoint(srv.Addr),
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	return srv, c.(*conn)
+}
+
+func TestPublish(t *testing.T) {
+	srv, conn := connect(t)
+
+	id, err := conn.Publish("orders", &eda.Event{
+		Type: "order-placed",
+		Data: eda.String("foo"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := srv.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(msgs))
+	}
+
+	if typ := msgs[0].Attributes[TypeAttribute]; typ != "order-placed" {
+		t.Errorf("expected event type attribute, got %q", typ)
+	}
+
+	evt, err := decode("orders", &pubsub.Message{
+		Data:        msgs[0].Data,
+		PublishTime: msgs[0].PublishTime,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if evt.ID != id || evt.Client != "test-client" || evt.Stream != "orders" {
+		t.Errorf("unexpected event: %+v", evt)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	_, conn := connect(t)
+
+	received := make(chan *eda.Event, 2)
+
+	handle := func(ctx context.Context, evt *eda.Event) error {
+		received <- evt
+		return nil
+	}
+
+	if _, err := conn.Subscribe("orders", handle, &eda.SubscriptionOptions{DeadLetterStream: "orders.dlq"}); err == nil {
+		t.Error("expected error for unsupported option")
+	}
+
+	opts := &eda.SubscriptionOptions{
+		Name:    "orders-consumer",
+		Durable: true,
+	}
+
+	sub, err := conn.Subscribe("orders", handle, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := conn.Publish("orders", &eda.Event{Type: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case evt := <-received:
+		if evt.Type != "a" || evt.AckTime.IsZero() {
+			t.Errorf("unexpected event: %+v", evt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not received")
+	}
+
+	if err := sub.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The durable subscription receives events published while closed.
+	if _, err := conn.Publish("orders", &eda.Event{Type: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err = conn.Subscribe("orders", handle, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	select {
+	case evt := <-received:
+		if evt.Type != "b" {
+			t.Errorf("expected event b, got %s", evt.Type)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not received")
+	}
+}
+
+func TestReadNotSupported(t *testing.T) {
+	_, conn := connect(t)
+
+	if _, err := conn.Read("orders", nil); err != eda.ErrReadNotSupported {
+		t.Errorf("expected ErrReadNotSupported, got %v", err)
+	}
+}
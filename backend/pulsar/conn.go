@@ -0,0 +1,765 @@
+/*
+Package pulsar provides an eda.Conn backed by Apache Pulsar. Streams map to
+topics, so short stream names resolve to persistent topics in the
+public/default namespace, and events are encoded with the same envelope as
+the NATS backends.
+
+	conn, err := pulsar.Connect("pulsar://localhost:6650", pulsar.WithClientID("orders"))
+*/
+package pulsar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/apache/pulsar-client-go/pulsaradmin"
+	"github.com/apache/pulsar-client-go/pulsaradmin/pkg/rest"
+	"github.com/apache/pulsar-client-go/pulsaradmin/pkg/utils"
+	"github.com/chop-dbhi/eda"
+	"github.com/nats-io/nuid"
+)
+
+// defaultTimeout is the handler timeout and the delay before redelivering
+// a failed event if the subscription does not set one.
+const defaultTimeout = 30 * time.Second
+
+// TypeProperty is the message property holding the event type.
+const TypeProperty = "eda_type"
+
+var (
+	// errSeqNotSupported is returned for options with sequences, since
+	// Pulsar message IDs do not fit in an event sequence.
+	errSeqNotSupported = fmt.Errorf("pulsar: %w", eda.ErrSeqNotSupported)
+
+	// errStatsNotSupported is returned by StreamStats.
+	errStatsNotSupported = errors.New("pulsar: stream stats are not supported")
+)
+
+// ConnectOptions are options for Connect.
+type ConnectOptions struct {
+	// ClientID identifies the connection. It is set on published events and
+	// is the subscription name of subscriptions without a name.
+	ClientID string
+
+	// Logger for internal logging. Defaults to discarding logs.
+	Logger *slog.Logger
+
+	// ClientOptions are passed to the Pulsar client, for example to set
+	// authentication. The URL is set to the service URL.
+	ClientOptions pulsar.ClientOptions
+
+	// Partitions is the number of partitions of topics created by the
+	// connection. Topics are partitioned with the admin API before they are
+	// first used, which requires AdminURL. Defaults to letting the broker
+	// create topics with the partitioning of the namespace.
+	Partitions int
+
+	// AdminURL is the URL of the admin web service, such as
+	// http://localhost:8080.
+	AdminURL string
+}
+
+type ConnectOption func(o *ConnectOptions)
+
+// WithClientID sets the client ID of the connection.
+func WithClientID(id string) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.ClientID = id
+	}
+}
+
+// WithLogger sets the logger for internal logging.
+func WithLogger(l *slog.Logger) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.Logger = l
+	}
+}
+
+// WithClientOptions sets the options of the Pulsar client.
+func WithClientOptions(co pulsar.ClientOptions) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.ClientOptions = co
+	}
+}
+
+// WithPartitions sets the number of partitions of topics created by the
+// connection.
+func WithPartitions(n int) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.Partitions = n
+	}
+}
+
+// WithAdminURL sets the URL of the admin web service used to create
+// partitioned topics.
+func WithAdminURL(url string) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.AdminURL = url
+	}
+}
+
+type conn struct {
+	logger     *slog.Logger
+	client     string
+	pulsar     pulsar.Client
+	admin      pulsaradmin.Client
+	partitions int
+
+	// Topics that are known to exist with the partitions.
+	topicsMux sync.Mutex
+	topics    map[string]bool
+
+	producersMux sync.Mutex
+	producers    map[string]pulsar.Producer
+}
+
+// topic creates the partitioned topic of the stream if the connection
+// sets partitions and the topic has not been created yet.
+func (c *conn) topic(stream string) error {
+	if c.partitions == 0 {
+		return nil
+	}
+
+	c.topicsMux.Lock()
+	defer c.topicsMux.Unlock()
+
+	if c.topics[stream] {
+		return nil
+	}
+
+	name, err := utils.GetTopicName(stream)
+	if err != nil {
+		return err
+	}
+
+	err = c.admin.Topics().Create(*name, c.partitions)
+
+	var rerr rest.Error
+	if errors.As(err, &rerr) && rerr.Code == http.StatusConflict {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	c.topics[stream] = true
+
+	return nil
+}
+
+// producer returns the producer of the stream, creating it if needed.
+func (c *conn) producer(stream string) (pulsar.Producer, error) {
+	c.producersMux.Lock()
+	defer c.producersMux.Unlock()
+
+	if p, ok := c.producers[stream]; ok {
+		return p, nil
+	}
+
+	if err := c.topic(stream); err != nil {
+		return nil, err
+	}
+
+	p, err := c.pulsar.CreateProducer(pulsar.ProducerOptions{
+		Topic: stream,
+		Name:  c.client + "-" + nuid.Next(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.producers[stream] = p
+
+	return p, nil
+}
+
+// Publish sends the event with its aggregate as the message key, so events
+// of an aggregate are routed to the same partition, and waits for the
+// broker to acknowledge it. The event type is set as the TypeProperty
+// property.
+func (c *conn) Publish(stream string, evt *eda.Event) (string, error) {
+	if evt == nil {
+		evt = &eda.Event{}
+	}
+
+	if evt.Time.IsZero() {
+		evt.Time = time.Now()
+	}
+
+	id := nuid.Next()
+
+	// Copy to set the ID and client without modifying the caller's event.
+	e := *evt
+	e.ID = id
+	e.Client = c.client
+	e.Stream = ""
+
+	b, err := eda.MarshalEvent(&e)
+	if err != nil {
+		return "", err
+	}
+
+	p, err := c.producer(stream)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = p.Send(context.Background(), &pulsar.ProducerMessage{
+		Payload:    b,
+		Key:        evt.Aggregate,
+		EventTime:  evt.Time,
+		Properties: map[string]string{TypeProperty: evt.Type},
+	})
+	if err != nil {
+		return id, err
+	}
+
+	return id, nil
+}
+
+// PublishBatch publishes the events one at a time, stopping at the first
+// failure.
+func (c *conn) PublishBatch(stream string, evts []*eda.Event) ([]string, error) {
+	return eda.PublishEach(c.Publish, stream, evts)
+}
+
+// Request is not supported by this backend. It returns
+// eda.ErrRequestNotSupported.
+func (c *conn) Request(ctx context.Context, stream string, evt *eda.Event) (*eda.Reply, error) {
+	return nil, eda.ErrRequestNotSupported
+}
+
+// decode returns the event of the message.
+func decode(stream string, msg pulsar.Message) (*eda.Event, error) {
+	evt, err := eda.UnmarshalEvent(msg.Payload())
+	if err != nil {
+		return nil, err
+	}
+
+	evt.Stream = stream
+
+	if evt.AckTime.IsZero() {
+		evt.AckTime = msg.PublishTime()
+	}
+
+	return evt, nil
+}
+
+// handler handles the messages of a subscription.
+type handler struct {
+	conn     *conn
+	stream   string
+	handle   eda.Handler
+	opts     *eda.SubscriptionOptions
+	pause    *pauser
+	consumer pulsar.Consumer
+
+	// Number of expired events skipped without being handled.
+	expired *atomic.Uint64
+}
+
+// receive handles the message, acking it if it is handled and nacking it
+// otherwise so it is redelivered.
+func (h *handler) receive(done <-chan struct{}, msg pulsar.Message) {
+	if !h.pause.wait(done) {
+		h.consumer.Nack(msg)
+		return
+	}
+
+	evt, err := decode(h.stream, msg)
+	if err != nil {
+		h.conn.logger.Error("envelope decode failed",
+			slog.String("stream", h.stream),
+			slog.Any("error", err),
+		)
+		h.consumer.Ack(msg)
+		return
+	}
+
+	if evt.IsExpired() {
+		h.expired.Add(1)
+		h.consumer.Ack(msg)
+		return
+	}
+
+	if !h.opts.AcceptsType(evt.Type) {
+		h.consumer.Ack(msg)
+		return
+	}
+
+	if err := h.call(evt); err != nil {
+		attrs := []any{
+			slog.String("stream", evt.Stream),
+			slog.String("event_id", evt.ID),
+		}
+
+		h.conn.logger.Error("handler error", append(attrs, slog.Any("error", err))...)
+
+		// The redelivery count is tracked by the broker.
+		if h.deadLetter(evt, err, int(msg.RedeliveryCount())+1, attrs) {
+			h.consumer.Ack(msg)
+			return
+		}
+
+		h.consumer.Nack(msg)
+		return
+	}
+
+	h.consumer.Ack(msg)
+}
+
+// deadLetter publishes the event to the dead letter stream once it has
+// failed on every attempt. It returns true if the event was published, in
+// which case it is acked.
+func (h *handler) deadLetter(evt *eda.Event, err error, attempt int, attrs []any) bool {
+	if h.opts.DeadLetterStream == "" || attempt <= len(h.opts.RetryBackoff) {
+		return false
+	}
+
+	if _, err := h.conn.Publish(h.opts.DeadLetterStream, eda.DeadLetterEvent(evt, err, attempt)); err != nil {
+		h.conn.logger.Error("dead letter publish failed", append(attrs, slog.Any("error", err))...)
+		return false
+	}
+
+	return true
+}
+
+// call calls the handler, recovering a panic as an error.
+func (h *handler) call(evt *eda.Event) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.opts.Timeout)
+	defer cancel()
+
+	if id := evt.CorrelationID(); id != "" {
+		ctx = eda.WithCorrelationID(ctx, id)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("recovered handler panic")
+		}
+	}()
+
+	return h.handle(ctx, evt)
+}
+
+// pauser blocks handling messages while a subscription is paused.
+type pauser struct {
+	mux     sync.Mutex
+	paused  bool
+	resumed chan struct{}
+}
+
+func (p *pauser) pause() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if !p.paused {
+		p.paused = true
+		p.resumed = make(chan struct{})
+	}
+}
+
+func (p *pauser) resume() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.paused {
+		p.paused = false
+		close(p.resumed)
+	}
+}
+
+func (p *pauser) isPaused() bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	return p.paused
+}
+
+// wait blocks while paused. It returns false if the done channel is
+// closed first.
+func (p *pauser) wait(done <-chan struct{}) bool {
+	p.mux.Lock()
+	if !p.paused {
+		p.mux.Unlock()
+		return true
+	}
+	resumed := p.resumed
+	p.mux.Unlock()
+
+	select {
+	case <-resumed:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+type subscription struct {
+	cancel   func()
+	done     chan struct{}
+	pause    *pauser
+	expired  *atomic.Uint64
+	consumer pulsar.Consumer
+}
+
+// Close stops receiving messages and closes the consumer. The cursor of a
+// durable subscription is kept by the broker so it can be resumed.
+func (s *subscription) Close() error {
+	s.cancel()
+	<-s.done
+
+	s.consumer.Close()
+	return nil
+}
+
+// Unsubscribe stops receiving messages and deletes the subscription.
+func (s *subscription) Unsubscribe() error {
+	s.cancel()
+	<-s.done
+
+	defer s.consumer.Close()
+	return s.consumer.Unsubscribe()
+}
+
+// Pause stops handling messages. Received messages block until the
+// subscription is resumed, which stops more messages from being delivered
+// once the receiver queue is full.
+func (s *subscription) Pause() error {
+	select {
+	case <-s.done:
+		return eda.ErrSubscriptionClosed
+	default:
+	}
+
+	s.pause.pause()
+	return nil
+}
+
+func (s *subscription) Resume() error {
+	select {
+	case <-s.done:
+		return eda.ErrSubscriptionClosed
+	default:
+	}
+
+	s.pause.resume()
+	return nil
+}
+
+func (s *subscription) IsPaused() bool {
+	return s.pause.isPaused()
+}
+
+// Expired returns the number of expired events skipped without being
+// handled.
+func (s *subscription) Expired() uint64 {
+	return s.expired.Load()
+}
+
+// GetLatest is not supported by this backend.
+func (s *subscription) GetLatest(key string) (*eda.Event, bool) {
+	return nil, false
+}
+
+// consumerOptions returns the options of the consumer of the subscription.
+func consumerOptions(stream, name string, opts *eda.SubscriptionOptions) pulsar.ConsumerOptions {
+	co := pulsar.ConsumerOptions{
+		Topic:                       stream,
+		SubscriptionName:            name,
+		Type:                        pulsar.Shared,
+		SubscriptionMode:            pulsar.NonDurable,
+		SubscriptionInitialPosition: pulsar.SubscriptionPositionLatest,
+		NackRedeliveryDelay:         opts.Timeout,
+	}
+
+	if opts.Durable {
+		co.Type = pulsar.Failover
+		co.SubscriptionMode = pulsar.Durable
+	}
+
+	if opts.Serial {
+		co.Type = pulsar.Exclusive
+	}
+
+	if opts.Backfill {
+		co.SubscriptionInitialPosition = pulsar.SubscriptionPositionEarliest
+	}
+
+	return co
+}
+
+// checkOptions returns an error for subscription options that are not
+// supported by this backend.
+func checkOptions(opts *eda.SubscriptionOptions) error {
+	switch {
+	case opts.ManualACK:
+		return &eda.ErrUnsupportedOption{Option: "ManualACK"}
+	case opts.LastValueCache:
+		return &eda.ErrUnsupportedOption{Option: "LastValueCache"}
+	case opts.DeduplicateWindow > 0:
+		return &eda.ErrUnsupportedOption{Option: "DeduplicateWindow"}
+	}
+
+	return nil
+}
+
+// Subscribe creates a consumer on the topic. Subscriptions are Shared, so
+// consumers with the same name, which defaults to the client ID, split the
+// events like a queue group. Durable subscriptions use a Failover
+// subscription whose cursor is kept by the broker when closed, and Serial
+// subscriptions use an Exclusive subscription. New subscriptions start at
+// the latest message, or the earliest with Backfill. StartTime seeks
+// subscriptions that are not durable, or durable ones with Reset, to the
+// time. StartSeq is not supported. Events that fail to be handled are
+// nacked and redelivered after the timeout, until they are published to
+// the DeadLetterStream. The ManualACK, LastValueCache, and
+// DeduplicateWindow options are not supported.
+func (c *conn) Subscribe(stream string, handle eda.Handler, opts *eda.SubscriptionOptions) (eda.Subscription, error) {
+	if opts == nil {
+		opts = &eda.SubscriptionOptions{}
+	} else {
+		opts = &(*opts)
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.StartSeq > 0 {
+		return nil, errSeqNotSupported
+	}
+
+	if err := checkOptions(opts); err != nil {
+		return nil, err
+	}
+
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	if err := c.topic(stream); err != nil {
+		return nil, err
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = c.client
+	}
+
+	co := consumerOptions(stream, name, opts)
+
+	consumer, err := c.pulsar.Subscribe(co)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delete the existing cursor and subscribe again from the start
+	// position.
+	if opts.Durable && opts.Reset {
+		if err := consumer.Unsubscribe(); err != nil {
+			consumer.Close()
+			return nil, err
+		}
+		consumer.Close()
+
+		if consumer, err = c.pulsar.Subscribe(co); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.StartTime != nil && (!opts.Durable || opts.Reset) {
+		if err := consumer.SeekByTime(*opts.StartTime); err != nil {
+			consumer.Close()
+			return nil, err
+		}
+	}
+
+	h := &handler{
+		conn:     c,
+		stream:   stream,
+		handle:   handle,
+		opts:     opts,
+		pause:    &pauser{},
+		expired:  new(atomic.Uint64),
+		consumer: consumer,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &subscription{
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		pause:    h.pause,
+		expired:  h.expired,
+		consumer: consumer,
+	}
+
+	workers := 1
+	if opts.MaxConcurrency > 1 && !opts.Serial {
+		workers = opts.MaxConcurrency
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for {
+				msg, err := consumer.Receive(ctx)
+				if ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					c.logger.Error("subscription receive error",
+						slog.String("stream", stream),
+						slog.String("subscription", name),
+						slog.Any("error", err),
+					)
+					continue
+				}
+
+				h.receive(ctx.Done(), msg)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(s.done)
+	}()
+
+	return s, nil
+}
+
+// Read reads the topic with a reader from the earliest message or the time
+// of the From option until it is caught up. Sequence options are not
+// supported.
+func (c *conn) Read(stream string, opts *eda.ReadOptions) (eda.EventIterator, error) {
+	if opts == nil {
+		opts = &eda.ReadOptions{}
+	}
+
+	if opts.From.Seq > 0 || opts.To.Seq > 0 {
+		return nil, errSeqNotSupported
+	}
+
+	r, err := c.pulsar.CreateReader(pulsar.ReaderOptions{
+		Topic:                   stream,
+		StartMessageID:          pulsar.EarliestMessageID(),
+		StartMessageIDInclusive: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.From.Time.IsZero() {
+		if err := r.SeekByTime(opts.From.Time); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+
+	next := func(ctx context.Context) (*eda.Event, error) {
+		if !r.HasNext() {
+			return nil, io.EOF
+		}
+
+		msg, err := r.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return decode(stream, msg)
+	}
+
+	return eda.NewEventIterator(next, func() error {
+		r.Close()
+		return nil
+	}, opts), nil
+}
+
+// StreamStats is not supported by this backend.
+func (c *conn) StreamStats(ctx context.Context, stream string) (*eda.StreamStats, error) {
+	return nil, errStatsNotSupported
+}
+
+// MultiStreamStats is not supported by this backend.
+func (c *conn) MultiStreamStats(ctx context.Context, streams []string) (map[string]*eda.StreamStats, error) {
+	return nil, errStatsNotSupported
+}
+
+// Close flushes and closes the producers and closes the client.
+func (c *conn) Close() error {
+	c.producersMux.Lock()
+	for _, p := range c.producers {
+		p.Close()
+	}
+	c.producersMux.Unlock()
+
+	c.pulsar.Close()
+	return nil
+}
+
+// Connect creates a Pulsar client for the service URL, such as
+// pulsar://localhost:6650.
+func Connect(serviceURL string, opts ...ConnectOption) (eda.Conn, error) {
+	o := &ConnectOptions{}
+
+	for _, f := range opts {
+		f(o)
+	}
+
+	if o.Logger == nil {
+		o.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	if o.ClientID == "" {
+		o.ClientID = nuid.Next()
+	}
+
+	if o.Partitions < 0 {
+		return nil, errors.New("pulsar: partitions must not be negative")
+	}
+
+	c := &conn{
+		logger:     o.Logger.With(slog.String("client", o.ClientID)),
+		client:     o.ClientID,
+		partitions: o.Partitions,
+		topics:     make(map[string]bool),
+		producers:  make(map[string]pulsar.Producer),
+	}
+
+	if o.Partitions > 0 {
+		if o.AdminURL == "" {
+			return nil, errors.New("pulsar: partitions require an admin URL")
+		}
+
+		admin, err := pulsaradmin.NewClient(&pulsaradmin.Config{WebServiceURL: o.AdminURL})
+		if err != nil {
+			return nil, err
+		}
+		c.admin = admin
+	}
+
+	co := o.ClientOptions
+	co.URL = serviceURL
+
+	client, err := pulsar.NewClient(co)
+	if err != nil {
+		return nil, err
+	}
+	c.pulsar = client
+
+	return c, nil
+}
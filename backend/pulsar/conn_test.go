@@ -0,0 +1,228 @@
+package pulsar
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/chop-dbhi/eda"
+)
+
+type fakeMessage struct {
+	pulsar.Message
+	payload     []byte
+	publishTime time.Time
+	redelivery  uint32
+}
+
+func (m *fakeMessage) Payload() []byte         { return m.payload }
+func (m *fakeMessage) PublishTime() time.Time  { return m.publishTime }
+func (m *fakeMessage) RedeliveryCount() uint32 { return m.redelivery }
+
+// fakeProducer records sent messages.
+type fakeProducer struct {
+	pulsar.Producer
+	sent []*pulsar.ProducerMessage
+}
+
+func (p *fakeProducer) Send(ctx context.Context, msg *pulsar.ProducerMessage) (pulsar.MessageID, error) {
+	p.sent = append(p.sent, msg)
+	return nil, nil
+}
+
+// fakeConsumer records acked and nacked messages.
+type fakeConsumer struct {
+	pulsar.Consumer
+	acked  int
+	nacked int
+}
+
+func (c *fakeConsumer) Ack(msg pulsar.Message) error {
+	c.acked++
+	return nil
+}
+
+func (c *fakeConsumer) Nack(msg pulsar.Message) {
+	c.nacked++
+}
+
+func message(t *testing.T, evt *eda.Event) *fakeMessage {
+	b, err := eda.MarshalEvent(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &fakeMessage{payload: b, publishTime: time.Now()}
+}
+
+func TestConnect(t *testing.T) {
+	if _, err := Connect("pulsar://localhost:6650", WithPartitions(4)); err == nil {
+		t.Error("expected error for partitions without admin URL")
+	}
+
+	c, err := Connect("pulsar://localhost:6650",
+		WithPartitions(4),
+		WithAdminURL("http://localhost:8080"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if c.(*conn).partitions != 4 {
+		t.Errorf("expected 4 partitions, got %d", c.(*conn).partitions)
+	}
+
+	if _, err := c.Subscribe("orders", nil, &eda.SubscriptionOptions{DeduplicateWindow: time.Minute}); err == nil {
+		t.Error("expected error for unsupported option")
+	}
+}
+
+func TestConsumerOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts *eda.SubscriptionOptions
+		typ  pulsar.SubscriptionType
+		mode pulsar.SubscriptionMode
+		pos  pulsar.SubscriptionInitialPosition
+	}{
+		"default": {
+			opts: &eda.SubscriptionOptions{},
+			typ:  pulsar.Shared,
+			mode: pulsar.NonDurable,
+			pos:  pulsar.SubscriptionPositionLatest,
+		},
+		"durable": {
+			opts: &eda.SubscriptionOptions{Durable: true, Backfill: true},
+			typ:  pulsar.Failover,
+			mode: pulsar.Durable,
+			pos:  pulsar.SubscriptionPositionEarliest,
+		},
+		"serial": {
+			opts: &eda.SubscriptionOptions{Durable: true, Serial: true},
+			typ:  pulsar.Exclusive,
+			mode: pulsar.Durable,
+			pos:  pulsar.SubscriptionPositionLatest,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			co := consumerOptions("orders", "c", test.opts)
+
+			if co.Topic != "orders" || co.SubscriptionName != "c" {
+				t.Errorf("unexpected topic or name: %s %s", co.Topic, co.SubscriptionName)
+			}
+
+			if co.Type != test.typ || co.SubscriptionMode != test.mode || co.SubscriptionInitialPosition != test.pos {
+				t.Errorf("unexpected options: %+v", co)
+			}
+		})
+	}
+}
+
+func TestHandlerReceive(t *testing.T) {
+	consumer := &fakeConsumer{}
+
+	var received []*eda.Event
+
+	h := &handler{
+		conn:   &conn{logger: slog.New(slog.NewTextHandler(io.Discard, nil))},
+		stream: "orders",
+		handle: func(ctx context.Context, evt *eda.Event) error {
+			received = append(received, evt)
+			if evt.Type == "fail" {
+				return errors.New("failed")
+			}
+			return nil
+		},
+		opts:     &eda.SubscriptionOptions{Timeout: time.Second, TypeFilter: []string{"a", "fail"}},
+		pause:    &pauser{},
+		expired:  new(atomic.Uint64),
+		consumer: consumer,
+	}
+
+	done := make(chan struct{})
+
+	h.receive(done, message(t, &eda.Event{ID: "1", Type: "a"}))
+	h.receive(done, message(t, &eda.Event{ID: "2", Type: "b"}))
+	h.receive(done, message(t, &eda.Event{ID: "3", Type: "fail"}))
+	h.receive(done, message(t, &eda.Event{ID: "4", Type: "a", Expiry: time.Now().Add(-time.Second)}))
+
+	if len(received) != 2 || received[0].Stream != "orders" || received[0].AckTime.IsZero() {
+		t.Errorf("unexpected events: %v", received)
+	}
+
+	// Filtered and expired events are acked and failed events are nacked.
+	if consumer.acked != 3 || consumer.nacked != 1 {
+		t.Errorf("expected 3 acked and 1 nacked, got %d and %d", consumer.acked, consumer.nacked)
+	}
+
+	if n := h.expired.Load(); n != 1 {
+		t.Errorf("expected 1 expired event, got %d", n)
+	}
+
+	// Paused subscriptions nack messages when closed.
+	h.pause.pause()
+	close(done)
+
+	h.receive(done, message(t, &eda.Event{ID: "5", Type: "a"}))
+
+	if len(received) != 2 || consumer.nacked != 2 {
+		t.Errorf("expected paused message to be nacked")
+	}
+}
+
+func TestHandlerDeadLetter(t *testing.T) {
+	consumer := &fakeConsumer{}
+	producer := &fakeProducer{}
+
+	h := &handler{
+		conn: &conn{
+			logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+			producers: map[string]pulsar.Producer{"orders.dlq": producer},
+		},
+		stream: "orders",
+		handle: func(ctx context.Context, evt *eda.Event) error {
+			return errors.New("failed")
+		},
+		opts: &eda.SubscriptionOptions{
+			Timeout:          time.Second,
+			RetryBackoff:     []time.Duration{time.Second},
+			DeadLetterStream: "orders.dlq",
+		},
+		pause:    &pauser{},
+		expired:  new(atomic.Uint64),
+		consumer: consumer,
+	}
+
+	done := make(chan struct{})
+	msg := message(t, &eda.Event{ID: "1", Type: "a"})
+
+	// The first attempt is nacked to be retried.
+	h.receive(done, msg)
+
+	if consumer.nacked != 1 || len(producer.sent) != 0 {
+		t.Fatalf("expected message to be nacked")
+	}
+
+	msg.redelivery = 1
+	h.receive(done, msg)
+
+	if consumer.acked != 1 || len(producer.sent) != 1 {
+		t.Fatalf("expected message to be dead-lettered and acked")
+	}
+
+	evt, err := eda.UnmarshalEvent(producer.sent[0].Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if evt.Meta["dlq.original_id"] != "1" || evt.Meta["dlq.attempt_count"] != "2" {
+		t.Errorf("unexpected meta: %v", evt.Meta)
+	}
+}
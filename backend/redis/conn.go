@@ -0,0 +1,822 @@
+/*
+Package redis provides an eda.Conn backed by Redis Streams. Streams map to
+stream keys and events are encoded with the same envelope as the NATS
+backends.
+
+	conn, err := redis.Connect("localhost:6379", redis.WithClientID("orders"))
+
+NewIDStore returns an eda.IDStore backed by Redis for deduplicating events
+across processes:
+
+	store := redis.NewIDStore(client, "dedup:", time.Hour)
+*/
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chop-dbhi/eda"
+	"github.com/nats-io/nuid"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// defaultTimeout is the handler timeout and the time to wait before
+	// retrying a failed event if the subscription does not set one.
+	defaultTimeout = 30 * time.Second
+
+	// readCount is the number of entries read from a stream at a time.
+	readCount = 64
+
+	// blockTimeout is the time a subscription waits for new entries before
+	// reading again, so closing the subscription is not delayed.
+	blockTimeout = time.Second
+
+	// eventField is the field of a stream entry holding the encoded event.
+	eventField = "event"
+)
+
+// errSeqNotSupported is returned for options with sequences, since stream
+// entry IDs are not sequences.
+var errSeqNotSupported = fmt.Errorf("redis: %w", eda.ErrSeqNotSupported)
+
+// ConnectOptions are options for Connect.
+type ConnectOptions struct {
+	// ClientID identifies the connection. It is set on published events and
+	// is the consumer group of durable subscriptions without a name.
+	ClientID string
+
+	// Logger for internal logging. Defaults to discarding logs.
+	Logger *slog.Logger
+
+	// Options are the base go-redis client options. The address is set to
+	// the address passed to Connect.
+	Options *goredis.Options
+}
+
+type ConnectOption func(o *ConnectOptions)
+
+// WithClientID sets the client ID of the connection.
+func WithClientID(id string) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.ClientID = id
+	}
+}
+
+// WithLogger sets the logger for internal logging.
+func WithLogger(l *slog.Logger) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.Logger = l
+	}
+}
+
+// WithOptions sets the base go-redis client options.
+func WithOptions(opts *goredis.Options) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.Options = opts
+	}
+}
+
+type conn struct {
+	logger *slog.Logger
+	client string
+	redis  *goredis.Client
+}
+
+// Publish adds the event to the stream with an ID generated by the server.
+func (c *conn) Publish(stream string, evt *eda.Event) (string, error) {
+	if evt == nil {
+		evt = &eda.Event{}
+	}
+
+	if evt.Time.IsZero() {
+		evt.Time = time.Now()
+	}
+
+	id := nuid.Next()
+
+	// Copy to set the ID and client without modifying the caller's event.
+	e := *evt
+	e.ID = id
+	e.Client = c.client
+	e.Stream = ""
+
+	b, err := eda.MarshalEvent(&e)
+	if err != nil {
+		return "", err
+	}
+
+	err = c.redis.XAdd(context.Background(), &goredis.XAddArgs{
+		Stream: stream,
+		Values: map[string]interface{}{eventField: b},
+	}).Err()
+	if err != nil {
+		return id, err
+	}
+
+	return id, nil
+}
+
+// PublishBatch adds the events one at a time, stopping at the first
+// failure.
+func (c *conn) PublishBatch(stream string, evts []*eda.Event) ([]string, error) {
+	return eda.PublishEach(c.Publish, stream, evts)
+}
+
+// Request is not supported by this backend. It returns
+// eda.ErrRequestNotSupported.
+func (c *conn) Request(ctx context.Context, stream string, evt *eda.Event) (*eda.Reply, error) {
+	return nil, eda.ErrRequestNotSupported
+}
+
+// entryTime returns the time of the entry ID, which is the time in
+// milliseconds the entry was added.
+func entryTime(id string) time.Time {
+	ms, _ := strconv.ParseInt(strings.SplitN(id, "-", 2)[0], 10, 64)
+	return time.UnixMilli(ms)
+}
+
+// nextEntryID returns the smallest entry ID after the ID.
+func nextEntryID(id string) string {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 {
+		return id
+	}
+
+	seq, _ := strconv.ParseUint(parts[1], 10, 64)
+	return fmt.Sprintf("%s-%d", parts[0], seq+1)
+}
+
+// decode returns the event of the stream entry.
+func decode(stream string, msg goredis.XMessage) (*eda.Event, error) {
+	v, ok := msg.Values[eventField].(string)
+	if !ok {
+		return nil, fmt.Errorf("redis: entry %s has no event", msg.ID)
+	}
+
+	evt, err := eda.UnmarshalEvent([]byte(v))
+	if err != nil {
+		return nil, err
+	}
+
+	evt.Stream = stream
+
+	if evt.AckTime.IsZero() {
+		evt.AckTime = entryTime(msg.ID)
+	}
+
+	return evt, nil
+}
+
+// handler handles the entries of a subscription.
+type handler struct {
+	conn   *conn
+	handle eda.Handler
+	opts   *eda.SubscriptionOptions
+	pause  *pauser
+
+	// Number of expired events skipped without being handled.
+	expired *atomic.Uint64
+}
+
+// wait returns the time to wait before retrying the event after the
+// failed attempt.
+func (h *handler) wait(attempt int) time.Duration {
+	if n := len(h.opts.RetryBackoff); n > 0 {
+		if attempt > n {
+			attempt = n
+		}
+		return h.opts.RetryBackoff[attempt-1]
+	}
+
+	return h.opts.Timeout
+}
+
+// process handles the entry, retrying until it succeeds, it is published
+// to the dead letter stream, or the done channel is closed since entries
+// read from a stream are not redelivered. It returns true if the entry was
+// handled.
+func (h *handler) process(stream string, msg goredis.XMessage, done <-chan struct{}) bool {
+	if !h.pause.wait(done) {
+		return false
+	}
+
+	evt, err := decode(stream, msg)
+	if err != nil {
+		h.conn.logger.Error("envelope decode failed",
+			slog.String("stream", stream),
+			slog.Any("error", err),
+		)
+		return true
+	}
+
+	if evt.IsExpired() {
+		h.expired.Add(1)
+		return true
+	}
+
+	if !h.opts.AcceptsType(evt.Type) {
+		return true
+	}
+
+	attrs := []any{
+		slog.String("stream", evt.Stream),
+		slog.String("event_id", evt.ID),
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := h.call(evt)
+		if err == nil {
+			return true
+		}
+
+		h.conn.logger.Error("handler error", append(attrs, slog.Any("error", err))...)
+
+		if h.deadLetter(evt, err, attempt, attrs) {
+			return true
+		}
+
+		select {
+		case <-done:
+			return false
+		case <-time.After(h.wait(attempt)):
+		}
+	}
+}
+
+// deadLetter publishes the event to the dead letter stream once it has
+// failed on every attempt. It returns true if the event was published, in
+// which case it is marked as handled.
+func (h *handler) deadLetter(evt *eda.Event, err error, attempt int, attrs []any) bool {
+	if h.opts.DeadLetterStream == "" || attempt <= len(h.opts.RetryBackoff) {
+		return false
+	}
+
+	if _, err := h.conn.Publish(h.opts.DeadLetterStream, eda.DeadLetterEvent(evt, err, attempt)); err != nil {
+		h.conn.logger.Error("dead letter publish failed", append(attrs, slog.Any("error", err))...)
+		return false
+	}
+
+	return true
+}
+
+// call calls the handler, recovering a panic as an error.
+func (h *handler) call(evt *eda.Event) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.opts.Timeout)
+	defer cancel()
+
+	if id := evt.CorrelationID(); id != "" {
+		ctx = eda.WithCorrelationID(ctx, id)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("recovered handler panic")
+		}
+	}()
+
+	return h.handle(ctx, evt)
+}
+
+// pauser blocks processing entries while a subscription is paused.
+type pauser struct {
+	mux     sync.Mutex
+	paused  bool
+	resumed chan struct{}
+}
+
+func (p *pauser) pause() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if !p.paused {
+		p.paused = true
+		p.resumed = make(chan struct{})
+	}
+}
+
+func (p *pauser) resume() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.paused {
+		p.paused = false
+		close(p.resumed)
+	}
+}
+
+func (p *pauser) isPaused() bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	return p.paused
+}
+
+// wait blocks while paused. It returns false if the done channel is
+// closed first.
+func (p *pauser) wait(done <-chan struct{}) bool {
+	p.mux.Lock()
+	if !p.paused {
+		p.mux.Unlock()
+		return true
+	}
+	resumed := p.resumed
+	p.mux.Unlock()
+
+	select {
+	case <-resumed:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+type subscription struct {
+	cancel  func()
+	done    chan struct{}
+	pause   *pauser
+	expired *atomic.Uint64
+
+	// Destroys the consumer group on unsubscribe. Nil if the subscription
+	// is not durable.
+	remove func() error
+}
+
+func (s *subscription) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// Unsubscribe closes the subscription and destroys the consumer group of
+// a durable subscription.
+func (s *subscription) Unsubscribe() error {
+	if err := s.Close(); err != nil {
+		return err
+	}
+
+	if s.remove != nil {
+		return s.remove()
+	}
+
+	return nil
+}
+
+// Pause stops reading entries once the entry being handled returns.
+// Entries are not buffered, so the PauseBuffer option does not apply.
+func (s *subscription) Pause() error {
+	select {
+	case <-s.done:
+		return eda.ErrSubscriptionClosed
+	default:
+	}
+
+	s.pause.pause()
+	return nil
+}
+
+func (s *subscription) Resume() error {
+	select {
+	case <-s.done:
+		return eda.ErrSubscriptionClosed
+	default:
+	}
+
+	s.pause.resume()
+	return nil
+}
+
+func (s *subscription) IsPaused() bool {
+	return s.pause.isPaused()
+}
+
+// Expired returns the number of expired events skipped without being
+// handled.
+func (s *subscription) Expired() uint64 {
+	return s.expired.Load()
+}
+
+// GetLatest is not supported by this backend.
+func (s *subscription) GetLatest(key string) (*eda.Event, bool) {
+	return nil, false
+}
+
+// checkOptions returns an error for subscription options that are not
+// supported by this backend.
+func checkOptions(opts *eda.SubscriptionOptions) error {
+	switch {
+	case opts.ManualACK:
+		return &eda.ErrUnsupportedOption{Option: "ManualACK"}
+	case opts.LastValueCache:
+		return &eda.ErrUnsupportedOption{Option: "LastValueCache"}
+	case opts.DeduplicateWindow > 0:
+		return &eda.ErrUnsupportedOption{Option: "DeduplicateWindow"}
+	}
+
+	return nil
+}
+
+// startID returns the ID after which a new subscription reads entries.
+func startID(opts *eda.SubscriptionOptions) (string, error) {
+	switch {
+	case opts.StartSeq > 0:
+		return "", errSeqNotSupported
+	case opts.StartTime != nil:
+		// The last possible ID of the millisecond before the start time.
+		return fmt.Sprintf("%d-%d", opts.StartTime.UnixMilli()-1, uint64(math.MaxUint64)), nil
+	case opts.Backfill:
+		return "0", nil
+	}
+
+	return "$", nil
+}
+
+// Subscribe reads the stream. Durable subscriptions use a consumer group
+// named by opts.Name or the client ID, in which entries are acknowledged
+// with XACK once handled. Entries left pending by a previous subscription
+// of the client are handled first. Otherwise entries are read with XREAD
+// without being acknowledged. Serial subscriptions read one entry at a
+// time. Since entries are not redelivered, a failed event is retried after
+// the backoff or timeout until it succeeds or is published to the
+// DeadLetterStream. The ManualACK, LastValueCache, and DeduplicateWindow
+// options are not supported.
+func (c *conn) Subscribe(stream string, handle eda.Handler, opts *eda.SubscriptionOptions) (eda.Subscription, error) {
+	if opts == nil {
+		opts = &eda.SubscriptionOptions{}
+	} else {
+		opts = &(*opts)
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := checkOptions(opts); err != nil {
+		return nil, err
+	}
+
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	start, err := startID(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &handler{
+		conn:    c,
+		handle:  handle,
+		opts:    opts,
+		pause:   &pauser{},
+		expired: new(atomic.Uint64),
+	}
+
+	count := int64(readCount)
+	if opts.Serial {
+		count = 1
+	}
+
+	if opts.Durable {
+		return c.subscribeGroup(stream, h, start, count)
+	}
+
+	return c.subscribeStream(stream, h, start, count)
+}
+
+// retry logs the read error and waits before reading again. It returns
+// false if the context is done.
+func (c *conn) retry(ctx context.Context, stream string, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	c.logger.Error("stream read error",
+		slog.String("stream", stream),
+		slog.Any("error", err),
+	)
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(blockTimeout):
+		return true
+	}
+}
+
+func (c *conn) subscribeGroup(stream string, h *handler, start string, count int64) (eda.Subscription, error) {
+	group := h.opts.Name
+	if group == "" {
+		group = c.client
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if h.opts.Reset {
+		if err := c.redis.XGroupDestroy(ctx, stream, group).Err(); err != nil && !isNoKey(err) {
+			cancel()
+			return nil, err
+		}
+	}
+
+	err := c.redis.XGroupCreateMkStream(ctx, stream, group, start).Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		cancel()
+		return nil, err
+	}
+
+	sub := &subscription{
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		pause:   h.pause,
+		expired: h.expired,
+		remove: func() error {
+			return c.redis.XGroupDestroy(context.Background(), stream, group).Err()
+		},
+	}
+
+	go func() {
+		defer close(sub.done)
+
+		// Read the pending entries of the consumer before new entries.
+		id := "0"
+
+		for ctx.Err() == nil {
+			res, err := c.redis.XReadGroup(ctx, &goredis.XReadGroupArgs{
+				Group:    group,
+				Consumer: c.client,
+				Streams:  []string{stream, id},
+				Count:    count,
+				Block:    blockTimeout,
+			}).Result()
+			if err == goredis.Nil {
+				continue
+			}
+			if err != nil {
+				if !c.retry(ctx, stream, err) {
+					return
+				}
+				continue
+			}
+
+			var n int
+
+			for _, s := range res {
+				for _, msg := range s.Messages {
+					n++
+
+					// Pending entries that were deleted have no values.
+					if msg.Values != nil && !h.process(stream, msg, ctx.Done()) {
+						return
+					}
+
+					if err := c.redis.XAck(ctx, stream, group, msg.ID).Err(); err != nil {
+						c.logger.Error("ack failed",
+							slog.String("stream", stream),
+							slog.Any("error", err),
+						)
+					}
+				}
+			}
+
+			if n == 0 {
+				id = ">"
+			}
+		}
+	}()
+
+	return sub, nil
+}
+
+func (c *conn) subscribeStream(stream string, h *handler, start string, count int64) (eda.Subscription, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Resolve the ID of the last entry so entries added between reads
+	// are not missed.
+	if start == "$" {
+		msgs, err := c.redis.XRevRangeN(ctx, stream, "+", "-", 1).Result()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		start = "0"
+		if len(msgs) > 0 {
+			start = msgs[0].ID
+		}
+	}
+
+	sub := &subscription{
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		pause:   h.pause,
+		expired: h.expired,
+	}
+
+	go func() {
+		defer close(sub.done)
+
+		id := start
+
+		for ctx.Err() == nil {
+			res, err := c.redis.XRead(ctx, &goredis.XReadArgs{
+				Streams: []string{stream, id},
+				Count:   count,
+				Block:   blockTimeout,
+			}).Result()
+			if err == goredis.Nil {
+				continue
+			}
+			if err != nil {
+				if !c.retry(ctx, stream, err) {
+					return
+				}
+				continue
+			}
+
+			for _, s := range res {
+				for _, msg := range s.Messages {
+					if !h.process(stream, msg, ctx.Done()) {
+						return
+					}
+
+					id = msg.ID
+				}
+			}
+		}
+	}()
+
+	return sub, nil
+}
+
+// isNoKey returns true if the error is for a stream that does not exist.
+func isNoKey(err error) bool {
+	return strings.Contains(err.Error(), "no such key") || strings.Contains(err.Error(), "requires the key to exist")
+}
+
+// Read reads the entries of the stream up to the last entry at the time
+// of the read. Sequence options are not supported since entry IDs are not
+// sequences.
+func (c *conn) Read(stream string, opts *eda.ReadOptions) (eda.EventIterator, error) {
+	if opts == nil {
+		opts = &eda.ReadOptions{}
+	}
+
+	if opts.From.Seq > 0 || opts.To.Seq > 0 {
+		return nil, errSeqNotSupported
+	}
+
+	last, err := c.redis.XRevRangeN(context.Background(), stream, "+", "-", 1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	start := "-"
+	if !opts.From.Time.IsZero() {
+		start = fmt.Sprintf("%d-0", opts.From.Time.UnixMilli())
+	}
+
+	var (
+		buf  []goredis.XMessage
+		done = len(last) == 0
+	)
+
+	next := func(ctx context.Context) (*eda.Event, error) {
+		for len(buf) == 0 {
+			if done {
+				return nil, io.EOF
+			}
+
+			msgs, err := c.redis.XRangeN(ctx, stream, start, last[0].ID, readCount).Result()
+			if err != nil {
+				return nil, err
+			}
+
+			if len(msgs) == 0 {
+				done = true
+				continue
+			}
+
+			end := msgs[len(msgs)-1].ID
+			done = end == last[0].ID
+			start = nextEntryID(end)
+			buf = msgs
+		}
+
+		msg := buf[0]
+		buf = buf[1:]
+
+		return decode(stream, msg)
+	}
+
+	return eda.NewEventIterator(next, nil, opts), nil
+}
+
+// StreamStats returns the entry count and the times of the first and last
+// entries of the stream. Sequences and sizes are not provided.
+func (c *conn) StreamStats(ctx context.Context, stream string) (*eda.StreamStats, error) {
+	n, err := c.redis.XLen(ctx, stream).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &eda.StreamStats{
+		MsgCount: n,
+	}
+
+	if n == 0 {
+		return stats, nil
+	}
+
+	first, err := c.redis.XRangeN(ctx, stream, "-", "+", 1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	last, err := c.redis.XRevRangeN(ctx, stream, "+", "-", 1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(first) > 0 {
+		stats.FirstTime = entryTime(first[0].ID)
+	}
+
+	if len(last) > 0 {
+		stats.LastTime = entryTime(last[0].ID)
+	}
+
+	return stats, nil
+}
+
+func (c *conn) MultiStreamStats(ctx context.Context, streams []string) (map[string]*eda.StreamStats, error) {
+	m := make(map[string]*eda.StreamStats, len(streams))
+
+	for _, stream := range streams {
+		stats, err := c.StreamStats(ctx, stream)
+		if err != nil {
+			return nil, err
+		}
+
+		m[stream] = stats
+	}
+
+	return m, nil
+}
+
+// Close closes the client.
+func (c *conn) Close() error {
+	return c.redis.Close()
+}
+
+// Connect establishes a connection to the Redis server at the address.
+// Streams map directly to Redis stream keys.
+func Connect(addr string, opts ...ConnectOption) (eda.Conn, error) {
+	o := &ConnectOptions{}
+
+	for _, f := range opts {
+		f(o)
+	}
+
+	if o.Logger == nil {
+		o.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	if o.ClientID == "" {
+		o.ClientID = nuid.Next()
+	}
+
+	var ropts goredis.Options
+	if o.Options != nil {
+		ropts = *o.Options
+	}
+
+	ropts.Addr = addr
+
+	if ropts.ClientName == "" {
+		ropts.ClientName = o.ClientID
+	}
+
+	client := goredis.NewClient(&ropts)
+
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &conn{
+		logger: o.Logger.With(slog.String("client", o.ClientID)),
+		client: o.ClientID,
+		redis:  client,
+	}, nil
+}
@@ -0,0 +1,236 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chop-dbhi/eda"
+)
+
+func connect(t *testing.T) *conn {
+	srv := miniredis.RunT(t)
+
+	c, err := Connect(srv.Addr(), WithClientID("test-client"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { c.Close() })
+
+	return c.(*conn)
+}
+
+// receive returns the types of the next n events received on the channel.
+func receive(t *testing.T, ch <-chan *eda.Event, n int) []string {
+	var types []string
+
+	for i := 0; i < n; i++ {
+		select {
+		case evt := <-ch:
+			types = append(types, evt.Type)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %d events, got %v", n, types)
+		}
+	}
+
+	return types
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	conn := connect(t)
+
+	if _, err := conn.Publish("orders", &eda.Event{Type: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan *eda.Event, 2)
+
+	sub, err := conn.Subscribe("orders", func(ctx context.Context, evt *eda.Event) error {
+		received <- evt
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	id, err := conn.Publish("orders", &eda.Event{Type: "b", Data: eda.String("foo")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Events published before subscribing are not received.
+	select {
+	case evt := <-received:
+		if evt.ID != id || evt.Type != "b" || evt.Stream != "orders" || evt.Client != "test-client" || evt.AckTime.IsZero() {
+			t.Errorf("unexpected event: %+v", evt)
+		}
+
+		var v string
+		if err := evt.Data.Decode(&v); err != nil || v != "foo" {
+			t.Errorf("unexpected data %q: %v", v, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not received")
+	}
+}
+
+func TestSubscribeDurable(t *testing.T) {
+	conn := connect(t)
+
+	for _, typ := range []string{"a", "b"} {
+		if _, err := conn.Publish("orders", &eda.Event{Type: typ}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	received := make(chan *eda.Event, 3)
+
+	handle := func(ctx context.Context, evt *eda.Event) error {
+		received <- evt
+		return nil
+	}
+
+	opts := &eda.SubscriptionOptions{
+		Name:     "group",
+		Durable:  true,
+		Backfill: true,
+	}
+
+	sub, err := conn.Subscribe("orders", handle, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if types := receive(t, received, 2); types[0] != "a" || types[1] != "b" {
+		t.Errorf("unexpected events: %v", types)
+	}
+
+	sub.Close()
+
+	if _, err := conn.Publish("orders", &eda.Event{Type: "c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The group resumes after the acknowledged events.
+	sub, err = conn.Subscribe("orders", handle, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if types := receive(t, received, 1); types[0] != "c" {
+		t.Errorf("unexpected events: %v", types)
+	}
+
+	if err := sub.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+
+	if groups, _ := conn.redis.XInfoGroups(context.Background(), "orders").Result(); len(groups) != 0 {
+		t.Errorf("expected group to be destroyed, got %v", groups)
+	}
+}
+
+func TestSubscribeDeadLetter(t *testing.T) {
+	conn := connect(t)
+
+	handle := func(ctx context.Context, evt *eda.Event) error {
+		return errors.New("failed")
+	}
+
+	if _, err := conn.Subscribe("orders", handle, &eda.SubscriptionOptions{ManualACK: true}); err == nil {
+		t.Error("expected error for unsupported option")
+	}
+
+	received := make(chan *eda.Event, 1)
+
+	dlq, err := conn.Subscribe("orders.dlq", func(ctx context.Context, evt *eda.Event) error {
+		received <- evt
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dlq.Close()
+
+	sub, err := conn.Subscribe("orders", handle, &eda.SubscriptionOptions{
+		RetryBackoff:     []time.Duration{time.Millisecond},
+		DeadLetterStream: "orders.dlq",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	id, err := conn.Publish("orders", &eda.Event{Type: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The event is dead-lettered after the retry.
+	select {
+	case evt := <-received:
+		if evt.Meta["dlq.original_id"] != id || evt.Meta["dlq.attempt_count"] != "2" {
+			t.Errorf("unexpected meta: %v", evt.Meta)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not dead-lettered")
+	}
+}
+
+func TestRead(t *testing.T) {
+	conn := connect(t)
+
+	for _, typ := range []string{"a", "b", "c"} {
+		if _, err := conn.Publish("orders", &eda.Event{Type: typ}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	it, err := conn.Read("orders", &eda.ReadOptions{Types: []string{"a", "c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	var types []string
+
+	for {
+		evt, err := it.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		types = append(types, evt.Type)
+	}
+
+	if len(types) != 2 || types[0] != "a" || types[1] != "c" {
+		t.Errorf("unexpected events: %v", types)
+	}
+
+	if _, err := conn.Read("orders", &eda.ReadOptions{From: eda.AtSeq(1)}); err != errSeqNotSupported {
+		t.Errorf("expected errSeqNotSupported, got %v", err)
+	}
+
+	stats, err := conn.StreamStats(context.Background(), "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.MsgCount != 3 || stats.FirstTime.IsZero() || stats.LastTime.IsZero() {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestNextEntryID(t *testing.T) {
+	if id := nextEntryID("1526919030474-55"); id != "1526919030474-56" {
+		t.Errorf("unexpected ID: %s", id)
+	}
+}
@@ -0,0 +1,49 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/chop-dbhi/eda"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+type idStore struct {
+	client *goredis.Client
+	prefix string
+	window time.Duration
+}
+
+func (s *idStore) key(id string) string {
+	return s.prefix + id
+}
+
+func (s *idStore) Seen(id string) (bool, error) {
+	n, err := s.client.Exists(context.Background(), s.key(id)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// Mark sets the key if it does not exist. A zero TTL defaults to the
+// window of the store.
+func (s *idStore) Mark(id string, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = s.window
+	}
+
+	return s.client.SetNX(context.Background(), s.key(id), 1, ttl).Err()
+}
+
+// NewIDStore returns an IDStore backed by Redis. Keys are prefixed with
+// keyPrefix and expire after the window, so multiple consumers sharing
+// the same database deduplicate across processes.
+func NewIDStore(client *goredis.Client, keyPrefix string, window time.Duration) eda.IDStore {
+	return &idStore{
+		client: client,
+		prefix: keyPrefix,
+		window: window,
+	}
+}
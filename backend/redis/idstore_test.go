@@ -0,0 +1,60 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func TestIDStore(t *testing.T) {
+	srv := miniredis.RunT(t)
+
+	client := goredis.NewClient(&goredis.Options{Addr: srv.Addr()})
+	defer client.Close()
+
+	s := NewIDStore(client, "dedup:", time.Minute)
+
+	if seen, err := s.Seen("1"); err != nil || seen {
+		t.Fatalf("expected 1 to not be seen: %v", err)
+	}
+
+	if err := s.Mark("1", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if seen, _ := s.Seen("1"); !seen {
+		t.Error("expected 1 to be seen")
+	}
+
+	// A zero TTL defaults to the window.
+	if ttl := srv.TTL("dedup:1"); ttl != time.Minute {
+		t.Errorf("expected TTL of the window, got %s", ttl)
+	}
+
+	// Marking an ID again does not extend its TTL.
+	srv.FastForward(30 * time.Second)
+
+	if err := s.Mark("1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl := srv.TTL("dedup:1"); ttl != 30*time.Second {
+		t.Errorf("expected TTL to be kept, got %s", ttl)
+	}
+
+	srv.FastForward(30 * time.Second)
+
+	if seen, _ := s.Seen("1"); seen {
+		t.Error("expected 1 to expire")
+	}
+
+	if err := s.Mark("2", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl := srv.TTL("dedup:2"); ttl != time.Hour {
+		t.Errorf("expected TTL of an hour, got %s", ttl)
+	}
+}
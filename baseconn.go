@@ -0,0 +1,587 @@
+package eda
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chop-dbhi/eda/internal/pb"
+	stan "github.com/nats-io/go-nats-streaming"
+	"github.com/nats-io/nuid"
+)
+
+// baseConn is the backend-independent state of a connection used to
+// encode published events and handle received events.
+type baseConn struct {
+	logger *slog.Logger
+
+	// Event fields that must be set in order to publish.
+	required []string
+
+	// Codec used to encode the event envelope.
+	envelope envelopeCodec
+
+	// Returns the current time for events without a time set.
+	now func() time.Time
+
+	// Logical clocks used to stamp published events.
+	clock  *LamportClock
+	vclock *nodeClock
+
+	client string
+
+	// Key used to decrypt received data.
+	key []byte
+
+	// Publishes events with the connection, such as to a dead letter stream.
+	publish func(stream string, evt *Event) (string, error)
+
+	// Publishes a reply to the subject on core NATS.
+	respond func(subject string, data []byte) error
+}
+
+// encode prepares the event for publishing and encodes the envelope. The
+// ID assigned to the event is returned.
+func (c *baseConn) encode(evt *Event) (string, []byte, error) {
+	for _, f := range c.required {
+		if eventFields[f](evt) == "" {
+			return "", nil, &ErrMissingField{Field: f}
+		}
+	}
+
+	// Add event time if not set.
+	if evt.Time.IsZero() {
+		evt.Time = c.now()
+	}
+
+	if c.clock != nil {
+		evt.LamportTime = c.clock.Tick()
+	}
+
+	if c.vclock != nil {
+		if err := c.vclock.stamp(evt); err != nil {
+			return "", nil, &EncodeError{Err: err}
+		}
+	}
+
+	e, err := eventToPB(evt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	id := nuid.Next()
+	e.Id = id
+	e.Client = c.client
+
+	b, err := encodeEnvelope(c.envelope, e)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return id, b, nil
+}
+
+// deadLetter publishes a copy of the event that failed to be handled to
+// the dead letter stream.
+func (c *baseConn) deadLetter(stream string, evt *Event, err error, attempts int) error {
+	_, err = c.publish(stream, DeadLetterEvent(evt, err, attempts))
+	return err
+}
+
+// newSubscription initializes the state of a subscription.
+func (c *baseConn) newSubscription(stream, consumer string, opts *SubscriptionOptions) *subscription {
+	sub := &subscription{
+		channel:  stream,
+		consumer: consumer,
+		durable:  opts.Durable,
+		keyFn:    opts.KeyFn,
+	}
+
+	sub.pauseBuffer = opts.PauseBuffer
+	if sub.pauseBuffer <= 0 {
+		sub.pauseBuffer = defaultPauseBuffer
+	}
+	sub.resumed = sync.NewCond(&sub.inflightMux)
+
+	if opts.LastValueCache {
+		if sub.keyFn == nil {
+			sub.keyFn = func(evt *Event) string {
+				return evt.Aggregate
+			}
+		}
+
+		sub.latestMux = &sync.RWMutex{}
+		sub.latest = make(map[string]*Event)
+	}
+
+	if opts.DeduplicateWindow > 0 {
+		sub.dedup = newDedupCache(opts.DeduplicateWindow, opts.DeduplicateMaxSize)
+	}
+
+	if len(opts.RetryBackoff) > 0 || opts.DeadLetterStream != "" {
+		sub.attemptsMux = &sync.Mutex{}
+		sub.attempts = make(map[string]int)
+	}
+
+	return sub
+}
+
+// decode decodes the event from the raw message.
+func (c *baseConn) decode(msg *rawMsg) (*Event, error) {
+	var e pb.Event
+	if err := decodeEnvelope(msg.data, &e); err != nil {
+		return nil, err
+	}
+
+	evt := eventFromPB(&e)
+	evt.Stream = msg.subject
+	evt.Seq = msg.seq
+	evt.Ephemeral = msg.ephemeral
+	evt.msg = msg.stan
+
+	if c.key != nil {
+		decryptWith(evt.Data, c.key)
+	}
+
+	// Use stored ack time if set.
+	if evt.AckTime.IsZero() {
+		evt.AckTime = time.Unix(0, msg.timestamp)
+	}
+
+	if subject := evt.Meta[ReplyMetaKey]; subject != "" && c.respond != nil {
+		evt.reply = func(r *Reply) error {
+			b, err := encodeReply(r)
+			if err != nil {
+				return err
+			}
+
+			return c.respond(subject, b)
+		}
+	}
+
+	return evt, nil
+}
+
+// msgHandler returns the handler of raw messages received on the
+// subscription which decodes the event and calls the handler.
+func (c *baseConn) msgHandler(sub *subscription, handle Handler, opts *SubscriptionOptions) func(*rawMsg) {
+	h := func(msg *rawMsg) {
+		// Message sent on stream that is not a known envelope format.
+		evt, err := c.decode(msg)
+		if err != nil {
+			c.logger.Error("envelope decode failed",
+				slog.String("stream", msg.subject),
+				slog.Any("error", err),
+			)
+			return
+		}
+
+		expired := evt.IsExpired()
+		if expired {
+			sub.expired.Add(1)
+		}
+
+		// Ack events the subscription does not handle, has already
+		// handled, or that have expired, so they are not redelivered.
+		if expired || !opts.AcceptsType(evt.Type) || (sub.dedup != nil && sub.dedup.contains(evt.ID)) {
+			if err := msg.ack(); err != nil {
+				c.logger.Error("ack failed",
+					slog.String("stream", evt.Stream),
+					slog.String("event_id", evt.ID),
+					slog.String("event_type", evt.Type),
+					slog.Any("error", err),
+				)
+			}
+			return
+		}
+
+		if c.clock != nil {
+			c.clock.Witness(evt.LamportTime)
+		}
+
+		if c.vclock != nil {
+			if err := c.vclock.witness(evt); err != nil {
+				c.logger.Error("vector clock decode failed",
+					slog.String("stream", evt.Stream),
+					slog.String("event_id", evt.ID),
+					slog.String("event_type", evt.Type),
+					slog.Any("error", err),
+				)
+			}
+		}
+
+		// Update the cache prior to handling so the handler sees the
+		// current state of all keys.
+		if sub.latest != nil {
+			sub.cache(sub.keyFn(evt), evt)
+		}
+
+		// Use ack timeout as max context timeout to signal handler components.
+		ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+		defer cancel()
+
+		if id := evt.CorrelationID(); id != "" {
+			ctx = WithCorrelationID(ctx, id)
+		}
+
+		var acker *msgAcker
+		if opts.ManualACK {
+			acker = &msgAcker{ack: msg.ack}
+			ctx = context.WithValue(ctx, ackerContextKey, acker)
+		}
+
+		attrs := []any{
+			slog.String("stream", evt.Stream),
+			slog.String("event_id", evt.ID),
+			slog.String("event_type", evt.Type),
+		}
+
+		// Recover and log handler panic.
+		defer func() {
+			if err := recover(); err != nil {
+				c.logger.ErrorContext(ctx, "recovered handler panic", append(attrs, slog.Any("error", err))...)
+			}
+		}()
+
+		// Handler error implies a timeout or implementation issue.
+		if err := handle(ctx, evt); err != nil {
+			c.logger.ErrorContext(ctx, "handler error", append(attrs, slog.Any("error", err))...)
+
+			if sub.attempts == nil {
+				return
+			}
+
+			n := sub.attempt(evt.ID)
+
+			// Final attempt failed. The event is acknowledged only if it
+			// was published to the dead letter stream.
+			if opts.DeadLetterStream != "" && n > len(opts.RetryBackoff) {
+				if err := c.deadLetter(opts.DeadLetterStream, evt, err, n); err != nil {
+					c.logger.ErrorContext(ctx, "dead letter publish failed", append(attrs, slog.Any("error", err))...)
+					return
+				}
+
+				sub.resetAttempts(evt.ID)
+				sub.handled(evt.ID)
+
+				if err := msg.ack(); err != nil {
+					c.logger.ErrorContext(ctx, "ack failed", append(attrs, slog.Any("error", err))...)
+				}
+
+				return
+			}
+
+			// Wait before returning to delay redelivery, but no longer
+			// than the context allows.
+			if len(opts.RetryBackoff) > 0 {
+				select {
+				case <-time.After(backoff(opts.RetryBackoff, n)):
+				case <-ctx.Done():
+				}
+			}
+
+			return
+		}
+
+		if sub.attempts != nil {
+			sub.resetAttempts(evt.ID)
+		}
+
+		if acker == nil || !acker.nacked() {
+			sub.handled(evt.ID)
+		}
+
+		if acker != nil {
+			if acker.done() {
+				return
+			}
+
+			c.logger.WarnContext(ctx, "handler did not ack or nack event, acking", attrs...)
+		}
+
+		// Couldn't acknowledge the event has been handled.
+		// Bad subscription or bad connection.
+		if err := msg.ack(); err != nil {
+			c.logger.ErrorContext(ctx, "ack failed", append(attrs, slog.Any("error", err))...)
+		}
+	}
+
+	if opts.MaxConcurrency > 1 && !opts.Serial {
+		h = sub.concurrent(h, opts.MaxConcurrency)
+	}
+
+	sub.handle = h
+
+	return sub.pausable(h)
+}
+
+// closer is the subset of the subscription methods common to the
+// subscriptions of each backend.
+type closer interface {
+	Unsubscribe() error
+	Close() error
+}
+
+// subscription is the backend-independent state of a subscription.
+type subscription struct {
+	channel  string
+	consumer string
+	durable  bool
+	sub      closer
+
+	// Returns the key of an event for the last-value cache.
+	keyFn func(*Event) string
+
+	// Last-value cache keyed by opts.KeyFn. Nil if not enabled.
+	latestMux *sync.RWMutex
+	latest    map[string]*Event
+
+	// IDs of recently handled events. Nil if not enabled.
+	dedup *dedupCache
+
+	// Delivery attempts of events that failed to be handled.
+	attemptsMux *sync.Mutex
+	attempts    map[string]int
+
+	// Number of expired events acked without being handled.
+	expired atomic.Uint64
+
+	// Handlers running concurrently with MaxConcurrency. Once closing,
+	// messages are no longer dispatched.
+	inflightMux sync.Mutex
+	inflight    sync.WaitGroup
+	closing     bool
+
+	// Messages received while paused, or while the buffer is drained on
+	// resume. Delivery blocks on resumed once the buffer is full.
+	handle      func(*rawMsg)
+	paused      bool
+	draining    bool
+	buffer      []*rawMsg
+	pauseBuffer int
+	resumed     *sync.Cond
+}
+
+// Expired returns the number of expired events the subscription acked
+// without handling.
+func (s *subscription) Expired() uint64 {
+	return s.expired.Load()
+}
+
+// drain stops dispatching messages to concurrent handlers and waits for
+// the running handlers to return, so they can ack before the underlying
+// subscription is closed. Buffered messages are dropped without being
+// acked.
+func (s *subscription) drain() {
+	s.inflightMux.Lock()
+	s.closing = true
+	s.buffer = nil
+	s.resumed.Broadcast()
+	s.inflightMux.Unlock()
+
+	s.inflight.Wait()
+}
+
+// Pause stops handling received messages. Messages received while paused
+// are buffered without being acked, up to the PauseBuffer option, after
+// which delivery blocks until the subscription is resumed.
+func (s *subscription) Pause() error {
+	s.inflightMux.Lock()
+	defer s.inflightMux.Unlock()
+
+	if s.closing {
+		return ErrSubscriptionClosed
+	}
+
+	s.paused = true
+
+	return nil
+}
+
+// Resume handles the buffered messages in order and then resumes handling
+// received messages. Resume returns once the buffer is drained, unless
+// the subscription is paused again.
+func (s *subscription) Resume() error {
+	s.inflightMux.Lock()
+	defer s.inflightMux.Unlock()
+
+	if s.closing {
+		return ErrSubscriptionClosed
+	}
+
+	if !s.paused || s.draining {
+		s.paused = false
+		return nil
+	}
+
+	s.paused = false
+	s.draining = true
+
+	for len(s.buffer) > 0 && !s.paused && !s.closing {
+		msg := s.buffer[0]
+		s.buffer = s.buffer[1:]
+
+		s.inflightMux.Unlock()
+		s.handle(msg)
+		s.inflightMux.Lock()
+	}
+
+	s.draining = false
+	s.resumed.Broadcast()
+
+	return nil
+}
+
+func (s *subscription) IsPaused() bool {
+	s.inflightMux.Lock()
+	defer s.inflightMux.Unlock()
+
+	return s.paused
+}
+
+// pausable returns a message handler that buffers messages while the
+// subscription is paused or the buffer is being drained, so messages are
+// handled in the order they are received.
+func (s *subscription) pausable(h func(*rawMsg)) func(*rawMsg) {
+	return func(msg *rawMsg) {
+		s.inflightMux.Lock()
+
+		for (s.paused || s.draining) && len(s.buffer) >= s.pauseBuffer && !s.closing {
+			s.resumed.Wait()
+		}
+
+		if s.closing {
+			s.inflightMux.Unlock()
+			return
+		}
+
+		if s.paused || s.draining {
+			s.buffer = append(s.buffer, msg)
+			s.inflightMux.Unlock()
+			return
+		}
+
+		s.inflightMux.Unlock()
+
+		h(msg)
+	}
+}
+
+func (s *subscription) Close() error {
+	s.drain()
+	return s.sub.Close()
+}
+
+func (s *subscription) Unsubscribe() error {
+	s.drain()
+	return s.sub.Unsubscribe()
+}
+
+// concurrent returns a message handler that runs the handler in up to n
+// goroutines. Messages are dispatched in order and dispatching blocks
+// while n handlers are running. Messages dispatched while closing are
+// dropped without being acked.
+func (s *subscription) concurrent(h func(*rawMsg), n int) func(*rawMsg) {
+	sem := make(chan struct{}, n)
+
+	return func(msg *rawMsg) {
+		sem <- struct{}{}
+
+		s.inflightMux.Lock()
+		if s.closing {
+			s.inflightMux.Unlock()
+			<-sem
+			return
+		}
+		s.inflight.Add(1)
+		s.inflightMux.Unlock()
+
+		go func() {
+			defer func() {
+				s.inflight.Done()
+				<-sem
+			}()
+
+			h(msg)
+		}()
+	}
+}
+
+func (s *subscription) GetLatest(key string) (*Event, bool) {
+	if s.latest == nil {
+		return nil, false
+	}
+
+	s.latestMux.RLock()
+	evt, ok := s.latest[key]
+	s.latestMux.RUnlock()
+
+	return evt, ok
+}
+
+// attempt increments and returns the number of failed delivery attempts
+// for the event.
+func (s *subscription) attempt(id string) int {
+	s.attemptsMux.Lock()
+	defer s.attemptsMux.Unlock()
+
+	s.attempts[id]++
+	return s.attempts[id]
+}
+
+// handled remembers the event for deduplication, if enabled.
+func (s *subscription) handled(id string) {
+	if s.dedup != nil {
+		s.dedup.add(id)
+	}
+}
+
+// resetAttempts clears the delivery attempts of the event.
+func (s *subscription) resetAttempts(id string) {
+	s.attemptsMux.Lock()
+	delete(s.attempts, id)
+	s.attemptsMux.Unlock()
+}
+
+// defaultPauseBuffer is the number of messages buffered while a
+// subscription is paused if the PauseBuffer option is not set.
+const defaultPauseBuffer = 256
+
+// backoff returns the jittered duration to wait after the failed attempt.
+// The last backoff is used for all subsequent attempts.
+func backoff(durations []time.Duration, attempt int) time.Duration {
+	i := attempt - 1
+	if i >= len(durations) {
+		i = len(durations) - 1
+	}
+
+	d := durations[i]
+	if d <= 0 {
+		return 0
+	}
+
+	// Equal jitter: half of the duration plus a random portion of the other half.
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// cache sets the event as the latest value for its key.
+func (s *subscription) cache(key string, evt *Event) {
+	s.latestMux.Lock()
+	s.latest[key] = evt
+	s.latestMux.Unlock()
+}
+
+// rawMsg is a message received from a backend.
+type rawMsg struct {
+	subject   string
+	seq       uint64
+	data      []byte
+	timestamp int64
+	ephemeral bool
+	ack       func() error
+	stan      *stan.Msg
+}
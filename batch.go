@@ -0,0 +1,140 @@
+package eda
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBatchRequiresManualACK is returned by a batch handler if the
+// subscription does not have ManualACK enabled.
+var ErrBatchRequiresManualACK = errors.New("batch handler requires ManualACK")
+
+// ErrBatchHeld is returned by a batch handler for events received while a
+// batch that failed to flush is held, so they are redelivered instead of
+// growing the batch.
+var ErrBatchHeld = errors.New("batch held after failed flush")
+
+// BatchOptions are options for NewBatchHandler.
+type BatchOptions struct {
+	// MaxSize is the number of events that triggers a flush. Defaults to 100.
+	MaxSize int
+
+	// MaxWait is the maximum time the first event in a batch waits before
+	// the batch is flushed. It is also the time between retries of a failed
+	// flush. Defaults to one second.
+	MaxWait time.Duration
+
+	// If true, a batch that fails to flush is dropped without acknowledging
+	// its events so they are redelivered by the server. Otherwise the batch
+	// is held and retried after MaxWait, and new events are rejected with
+	// ErrBatchHeld until the flush succeeds.
+	FlushOnError bool
+}
+
+type batch struct {
+	inner func(ctx context.Context, events []*Event) error
+	opts  BatchOptions
+
+	mux    sync.Mutex
+	events []*Event
+	ackers []Acker
+	timer  *time.Timer
+
+	// True while a batch that failed to flush is held for retry.
+	held bool
+}
+
+func (b *batch) handle(ctx context.Context, evt *Event) error {
+	acker, ok := AckerFromContext(ctx)
+	if !ok {
+		return ErrBatchRequiresManualACK
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.held {
+		return ErrBatchHeld
+	}
+
+	// Defer acknowledgement until the batch is flushed.
+	if err := acker.Nack(); err != nil {
+		return err
+	}
+
+	b.events = append(b.events, evt)
+	b.ackers = append(b.ackers, acker)
+
+	if len(b.events) >= b.opts.MaxSize {
+		b.flush(ctx)
+	} else if b.timer == nil {
+		b.timer = time.AfterFunc(b.opts.MaxWait, b.expire)
+	}
+
+	return nil
+}
+
+// expire flushes the batch once the max wait has elapsed.
+func (b *batch) expire() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.timer = nil
+
+	if len(b.events) > 0 {
+		b.flush(context.Background())
+	}
+}
+
+// flush passes the batch to the inner handler and acknowledges the events
+// on success. The lock must be held.
+func (b *batch) flush(ctx context.Context) {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	if err := b.inner(ctx, b.events); err != nil {
+		if !b.opts.FlushOnError {
+			b.held = true
+			b.timer = time.AfterFunc(b.opts.MaxWait, b.expire)
+			return
+		}
+	} else {
+		for _, a := range b.ackers {
+			a.Ack()
+		}
+	}
+
+	b.held = false
+	b.events = nil
+	b.ackers = nil
+}
+
+// NewBatchHandler returns a handler that accumulates events and passes them
+// to the inner handler in batches. A batch is flushed when it reaches the
+// max size or the max wait has elapsed, and its events are acknowledged
+// together once the flush succeeds.
+//
+// The subscription must have ManualACK enabled and must not be Serial so
+// the server continues to deliver events while a batch is pending. The
+// subscription Timeout should be greater than MaxWait to prevent pending
+// events from being redelivered.
+func NewBatchHandler(inner func(ctx context.Context, events []*Event) error, opts BatchOptions) Handler {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = 100
+	}
+
+	if opts.MaxWait <= 0 {
+		opts.MaxWait = time.Second
+	}
+
+	b := &batch{
+		inner: inner,
+		opts:  opts,
+	}
+
+	return b.handle
+}
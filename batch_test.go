@@ -0,0 +1,112 @@
+package eda
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatchHandler(t *testing.T) {
+	var (
+		fail    = true
+		flushed = make(chan int, 1)
+		acked   atomic.Int32
+	)
+
+	// The inner handler is called with the batch lock held.
+	inner := func(ctx context.Context, evts []*Event) error {
+		if fail {
+			fail = false
+			return errors.New("insert failed")
+		}
+		flushed <- len(evts)
+		return nil
+	}
+
+	handle := NewBatchHandler(inner, BatchOptions{
+		MaxSize: 2,
+		MaxWait: 10 * time.Millisecond,
+	})
+
+	if err := handle(context.Background(), &Event{}); err != ErrBatchRequiresManualACK {
+		t.Fatalf("expected manual ack error, got %v", err)
+	}
+
+	ackerContext := func() (*msgAcker, context.Context) {
+		a := &msgAcker{ack: func() error {
+			acked.Add(1)
+			return nil
+		}}
+		return a, context.WithValue(context.Background(), ackerContextKey, a)
+	}
+
+	for i := 0; i < 2; i++ {
+		a, ctx := ackerContext()
+		if err := handle(ctx, &Event{}); err != nil {
+			t.Fatal(err)
+		}
+
+		if !a.done() {
+			t.Error("expected acker to be marked as handled")
+		}
+	}
+
+	// The first flush failed so the batch is held and new events are
+	// rejected to be redelivered.
+	a, ctx := ackerContext()
+	if err := handle(ctx, &Event{}); err != ErrBatchHeld {
+		t.Fatalf("expected ErrBatchHeld, got %v", err)
+	}
+
+	if a.done() {
+		t.Error("expected rejected event to not be marked as handled")
+	}
+
+	select {
+	case n := <-flushed:
+		if n != 2 {
+			t.Fatalf("expected held batch of 2 events, got %d", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("held batch not retried")
+	}
+
+	// Events are accepted once the held batch is flushed. The handler
+	// waits for the flush to acknowledge the batch.
+	if _, ctx := ackerContext(); handle(ctx, &Event{}) != nil {
+		t.Fatal("expected event to be accepted")
+	}
+
+	if n := acked.Load(); n != 2 {
+		t.Errorf("expected 2 acks, got %d", n)
+	}
+}
+
+func TestBatchHandlerMaxWait(t *testing.T) {
+	flushed := make(chan int)
+
+	handle := NewBatchHandler(func(ctx context.Context, evts []*Event) error {
+		flushed <- len(evts)
+		return nil
+	}, BatchOptions{
+		MaxWait: 10 * time.Millisecond,
+	})
+
+	a := &msgAcker{ack: func() error { return nil }}
+	ctx := context.WithValue(context.Background(), ackerContextKey, a)
+
+	if err := handle(ctx, &Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case n := <-flushed:
+		if n != 1 {
+			t.Errorf("expected 1 event, got %d", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("batch not flushed")
+	}
+}
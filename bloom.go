@@ -0,0 +1,65 @@
+package eda
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// bloomFilter is a probabilistic set. Membership tests may return false
+// positives, but never false negatives.
+type bloomFilter struct {
+	bits []uint64
+	m    uint64
+	k    uint64
+}
+
+// newBloomFilter returns a filter sized for n items with the given false
+// positive rate.
+func newBloomFilter(n int, p float64) *bloomFilter {
+	if n < 1 {
+		n = 1
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Ceil(math.Ln2 * float64(m) / float64(n)))
+
+	return &bloomFilter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// hashes returns two hashes used to derive the k bit positions.
+func (f *bloomFilter) hashes(s string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	a := h.Sum64()
+
+	h.Write([]byte{0})
+	b := h.Sum64()
+
+	return a, b
+}
+
+func (f *bloomFilter) Add(s string) {
+	a, b := f.hashes(s)
+
+	for i := uint64(0); i < f.k; i++ {
+		n := (a + i*b) % f.m
+		f.bits[n/64] |= 1 << (n % 64)
+	}
+}
+
+func (f *bloomFilter) Test(s string) bool {
+	a, b := f.hashes(s)
+
+	for i := uint64(0); i < f.k; i++ {
+		n := (a + i*b) % f.m
+		if f.bits[n/64]&(1<<(n%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
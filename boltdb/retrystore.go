@@ -0,0 +1,141 @@
+/*
+Package boltdb provides BoltDB-backed implementations of eda interfaces.
+*/
+package boltdb
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/chop-dbhi/eda"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// queueBucket maps a sequence number to the encoded event.
+	queueBucket = []byte("retry-queue")
+
+	// idBucket maps an event ID to its sequence number.
+	idBucket = []byte("retry-ids")
+
+	// ephemeralBucket contains the sequence numbers of ephemeral events
+	// since the encoded event does not include the flag.
+	ephemeralBucket = []byte("retry-ephemeral")
+)
+
+// RetryStore is an eda.RetryStore persisted to a BoltDB file so queued
+// events survive restarts.
+type RetryStore struct {
+	db *bolt.DB
+}
+
+func (s *RetryStore) Enqueue(evt *eda.Event) error {
+	b, err := eda.MarshalEvent(evt)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		q := tx.Bucket(queueBucket)
+
+		n, err := q.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		seq := make([]byte, 8)
+		binary.BigEndian.PutUint64(seq, n)
+
+		if err := q.Put(seq, b); err != nil {
+			return err
+		}
+
+		if evt.Ephemeral {
+			if err := tx.Bucket(ephemeralBucket).Put(seq, []byte{1}); err != nil {
+				return err
+			}
+		}
+
+		return tx.Bucket(idBucket).Put([]byte(evt.ID), seq)
+	})
+}
+
+func (s *RetryStore) Dequeue(n int) ([]*eda.Event, error) {
+	var evts []*eda.Event
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(queueBucket).Cursor()
+		ephemeral := tx.Bucket(ephemeralBucket)
+
+		for k, v := c.First(); k != nil && len(evts) < n; k, v = c.Next() {
+			evt, err := eda.UnmarshalEvent(v)
+			if err != nil {
+				return err
+			}
+
+			evt.Ephemeral = ephemeral.Get(k) != nil
+
+			evts = append(evts, evt)
+		}
+
+		return nil
+	})
+
+	return evts, err
+}
+
+func (s *RetryStore) Ack(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		ids := tx.Bucket(idBucket)
+
+		seq := ids.Get([]byte(id))
+		if seq == nil {
+			return nil
+		}
+
+		if err := tx.Bucket(queueBucket).Delete(seq); err != nil {
+			return err
+		}
+
+		if err := tx.Bucket(ephemeralBucket).Delete(seq); err != nil {
+			return err
+		}
+
+		return ids.Delete([]byte(id))
+	})
+}
+
+// Close closes the database.
+func (s *RetryStore) Close() error {
+	return s.db.Close()
+}
+
+// NewRetryStore opens or creates the BoltDB file at path for storing
+// events to be retried.
+func NewRetryStore(path string) (*RetryStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{
+		Timeout: time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(queueBucket); err != nil {
+			return err
+		}
+
+		if _, err := tx.CreateBucketIfNotExists(ephemeralBucket); err != nil {
+			return err
+		}
+
+		_, err := tx.CreateBucketIfNotExists(idBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &RetryStore{db: db}, nil
+}
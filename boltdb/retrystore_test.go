@@ -0,0 +1,109 @@
+package boltdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/chop-dbhi/eda"
+)
+
+func TestRetryStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.db")
+
+	s, err := NewRetryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"1", "2", "3"} {
+		err := s.Enqueue(&eda.Event{
+			ID:     id,
+			Stream: "test",
+			Type:   "foo",
+			Data:   eda.String("bar"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Ack("1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen to ensure the queue is persisted.
+	s.Close()
+
+	s, err = NewRetryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	evts, err := s.Dequeue(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(evts) != 2 || evts[0].ID != "2" || evts[1].ID != "3" {
+		t.Fatalf("unexpected events: %v", evts)
+	}
+
+	evt := evts[0]
+	if evt.Stream != "test" || evt.Type != "foo" {
+		t.Errorf("unexpected event: %+v", evt)
+	}
+
+	var v string
+	if err := evt.Data.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v != "bar" {
+		t.Errorf("expected bar, got %q", v)
+	}
+}
+
+func TestRetryStoreEphemeral(t *testing.T) {
+	s, err := NewRetryStore(filepath.Join(t.TempDir(), "retry.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for _, evt := range []*eda.Event{
+		{ID: "1", Type: "foo", Ephemeral: true},
+		{ID: "2", Type: "foo"},
+	} {
+		if err := s.Enqueue(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	evts, err := s.Dequeue(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(evts) != 2 || !evts[0].Ephemeral || evts[1].Ephemeral {
+		t.Fatalf("expected only the first event to be ephemeral: %+v", evts)
+	}
+
+	// The flag is removed with the acked event.
+	if err := s.Ack("1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Enqueue(&eda.Event{ID: "3", Type: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	evts, err = s.Dequeue(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(evts) != 2 || evts[0].Ephemeral || evts[1].Ephemeral {
+		t.Fatalf("expected no ephemeral events: %+v", evts)
+	}
+}
@@ -0,0 +1,88 @@
+package eda
+
+// EventBuilder builds an event by chaining field setters. The zero value
+// is ready to use.
+//
+//	evt, err := eda.NewEventBuilder().
+//		WithType("order.placed").
+//		WithAggregate(orderID).
+//		WithData(eda.JSON(order)).
+//		Build()
+type EventBuilder struct {
+	evt Event
+}
+
+// NewEventBuilder returns an empty EventBuilder.
+func NewEventBuilder() *EventBuilder {
+	return &EventBuilder{}
+}
+
+// WithType sets the event type.
+func (b *EventBuilder) WithType(t string) *EventBuilder {
+	b.evt.Type = t
+	return b
+}
+
+// WithData sets the event data.
+func (b *EventBuilder) WithData(d Data) *EventBuilder {
+	b.evt.Data = d
+	return b
+}
+
+// WithCause sets the ID of the event that caused this event.
+func (b *EventBuilder) WithCause(id string) *EventBuilder {
+	b.evt.Cause = id
+	return b
+}
+
+// WithCorrelation sets the correlation ID of the event.
+func (b *EventBuilder) WithCorrelation(id string) *EventBuilder {
+	return b.WithMeta(CorrelationMetaKey, id)
+}
+
+// WithMeta sets a meta key-value pair.
+func (b *EventBuilder) WithMeta(k, v string) *EventBuilder {
+	if b.evt.Meta == nil {
+		b.evt.Meta = make(map[string]string)
+	}
+
+	b.evt.Meta[k] = v
+	return b
+}
+
+// WithAggregate sets the ID of the aggregate the event applies to.
+func (b *EventBuilder) WithAggregate(id string) *EventBuilder {
+	b.evt.Aggregate = id
+	return b
+}
+
+// Build returns a new event with the fields set on the builder, so the
+// builder can be used to build more events. An *ErrMissingField is
+// returned if the type is not set.
+func (b *EventBuilder) Build() (*Event, error) {
+	if b.evt.Type == "" {
+		return nil, &ErrMissingField{Field: "type"}
+	}
+
+	evt := b.evt
+
+	if b.evt.Meta != nil {
+		evt.Meta = make(map[string]string, len(b.evt.Meta))
+		for k, v := range b.evt.Meta {
+			evt.Meta[k] = v
+		}
+	}
+
+	return &evt, nil
+}
+
+// MustBuild is like Build but panics if the type is not set. It is
+// intended for events built from constant fields.
+func (b *EventBuilder) MustBuild() *Event {
+	evt, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+
+	return evt
+}
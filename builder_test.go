@@ -0,0 +1,51 @@
+package eda
+
+import "testing"
+
+func TestEventBuilder(t *testing.T) {
+	b := NewEventBuilder().
+		WithType("foo").
+		WithData(String("bar")).
+		WithCause("1").
+		WithCorrelation("2").
+		WithMeta("k", "v").
+		WithAggregate("3")
+
+	evt, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if evt.Type != "foo" || evt.Cause != "1" || evt.Aggregate != "3" || evt.Data.Type() != "string" {
+		t.Errorf("unexpected event: %+v", evt)
+	}
+
+	if evt.CorrelationID() != "2" || evt.Meta["k"] != "v" {
+		t.Errorf("unexpected meta: %v", evt.Meta)
+	}
+
+	// Events built later do not share meta.
+	b.WithMeta("k", "w")
+
+	if evt.Meta["k"] != "v" {
+		t.Errorf("expected built event to be unchanged, got %v", evt.Meta)
+	}
+}
+
+func TestEventBuilderMissingType(t *testing.T) {
+	_, err := NewEventBuilder().WithAggregate("1").Build()
+	if e, ok := err.(*ErrMissingField); !ok || e.Field != "type" {
+		t.Errorf("expected missing type error, got %v", err)
+	}
+}
+
+func TestEventBuilderMustBuild(t *testing.T) {
+	defer func() {
+		err, ok := recover().(*ErrMissingField)
+		if !ok || err.Field != "type" {
+			t.Errorf("expected missing type panic, got %v", err)
+		}
+	}()
+
+	NewEventBuilder().WithAggregate("1").MustBuild()
+}
@@ -0,0 +1,31 @@
+package eda
+
+import (
+	"sync"
+	"time"
+)
+
+// FrozenClock returns a clock function that always returns t.
+func FrozenClock(t time.Time) func() time.Time {
+	return func() time.Time {
+		return t
+	}
+}
+
+// AdvancingClock returns a clock function that returns start on the first
+// call and advances by step on each subsequent call.
+func AdvancingClock(start time.Time, step time.Duration) func() time.Time {
+	var (
+		mux sync.Mutex
+		t   = start
+	)
+
+	return func() time.Time {
+		mux.Lock()
+		defer mux.Unlock()
+
+		now := t
+		t = t.Add(step)
+		return now
+	}
+}
@@ -0,0 +1,27 @@
+package eda
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFrozenClock(t *testing.T) {
+	now := time.Now()
+	clock := FrozenClock(now)
+
+	if !clock().Equal(now) || !clock().Equal(now) {
+		t.Fatal("expected frozen time")
+	}
+}
+
+func TestAdvancingClock(t *testing.T) {
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := AdvancingClock(start, time.Second)
+
+	for i := 0; i < 3; i++ {
+		exp := start.Add(time.Duration(i) * time.Second)
+		if now := clock(); !now.Equal(exp) {
+			t.Fatalf("expected %s, got %s", exp, now)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package eda
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// compressor compresses the bytes of encoded data.
+type compressor interface {
+	Compress(b []byte) ([]byte, error)
+	Decompress(b []byte) ([]byte, error)
+}
+
+// Compression algorithms by name. Each is registered as a suffix of the
+// built-in encodings, such as "json+gzip".
+var compressors = map[string]compressor{
+	"gzip": &gzipCompressor{},
+	"zstd": newZstdCompressor(),
+	"lz4":  &lz4Compressor{},
+}
+
+func init() {
+	encMux.Lock()
+	defer encMux.Unlock()
+
+	// Copy since the map is modified.
+	names := make(map[string]encoder, len(encMap))
+	for name, enc := range encMap {
+		names[name] = enc
+	}
+
+	for name, enc := range names {
+		if name == "nil" {
+			continue
+		}
+
+		for algo, c := range compressors {
+			encMap[name+"+"+algo] = &compressedEncoder{enc: enc, c: c}
+		}
+	}
+}
+
+// CompressedJSON returns Data that encodes the value as JSON and compresses
+// it using the algorithm, one of "gzip", "zstd", or "lz4". The encoding is
+// named "json+<algo>" so consumers decompress it automatically.
+func CompressedJSON(v interface{}, algo string) Data {
+	t := "json+" + algo
+
+	return &decodable{
+		t:   t,
+		v:   v,
+		enc: encMap[t],
+	}
+}
+
+// compressedEncoder compresses the output of the inner encoder.
+type compressedEncoder struct {
+	enc encoder
+	c   compressor
+}
+
+func (e *compressedEncoder) Encode(v interface{}) ([]byte, error) {
+	b, err := e.enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.c.Compress(b)
+}
+
+func (e *compressedEncoder) Decode(b []byte, v interface{}) error {
+	b, err := e.c.Decompress(b)
+	if err != nil {
+		return err
+	}
+
+	return e.enc.Decode(b, v)
+}
+
+type gzipCompressor struct{}
+
+func (c *gzipCompressor) Compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (c *gzipCompressor) Decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// zstdCompressor uses a shared encoder and decoder which are safe for
+// concurrent use with EncodeAll and DecodeAll.
+type zstdCompressor struct {
+	enc *zstd.Encoder
+	dec *zstd.Decoder
+}
+
+func (c *zstdCompressor) Compress(b []byte) ([]byte, error) {
+	return c.enc.EncodeAll(b, nil), nil
+}
+
+func (c *zstdCompressor) Decompress(b []byte) ([]byte, error) {
+	return c.dec.DecodeAll(b, nil)
+}
+
+func newZstdCompressor() *zstdCompressor {
+	// Errors only occur for invalid options.
+	enc, _ := zstd.NewWriter(nil)
+	dec, _ := zstd.NewReader(nil)
+
+	return &zstdCompressor{
+		enc: enc,
+		dec: dec,
+	}
+}
+
+type lz4Compressor struct{}
+
+func (c *lz4Compressor) Compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := lz4.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (c *lz4Compressor) Decompress(b []byte) ([]byte, error) {
+	return io.ReadAll(lz4.NewReader(bytes.NewReader(b)))
+}
@@ -0,0 +1,50 @@
+package eda
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressedJSON(t *testing.T) {
+	v := map[string]string{
+		"description": string(bytes.Repeat([]byte("a"), 1024)),
+	}
+
+	for _, algo := range []string{"gzip", "zstd", "lz4"} {
+		d := CompressedJSON(v, algo)
+
+		if d.Type() != "json+"+algo {
+			t.Errorf("unexpected type: %s", d.Type())
+		}
+
+		b, err := d.Encode()
+		if err != nil {
+			t.Fatalf("%s: %s", algo, err)
+		}
+
+		if len(b) >= 1024 {
+			t.Errorf("%s: expected compressed size, got %d bytes", algo, len(b))
+		}
+
+		// Decode as received with the encoding name.
+		r := &decodable{
+			b:   b,
+			t:   d.Type(),
+			e:   true,
+			enc: encMap[d.Type()],
+		}
+
+		var n map[string]string
+		if err := r.Decode(&n); err != nil {
+			t.Fatalf("%s: %s", algo, err)
+		}
+
+		if n["description"] != v["description"] {
+			t.Errorf("%s: decoded value not equal", algo)
+		}
+	}
+
+	if _, err := CompressedJSON(v, "bzip2").Encode(); err == nil {
+		t.Error("expected error for unknown algorithm")
+	}
+}
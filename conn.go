@@ -2,6 +2,9 @@ package eda
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
 	stan "github.com/nats-io/go-nats-streaming"
@@ -15,6 +18,10 @@ type Event struct {
 	// ID is the globally unique ID of the event.
 	ID string `json:"id"`
 
+	// Seq is the sequence of the event in the stream. This is set on
+	// received events and is zero for ephemeral events.
+	Seq uint64 `json:"seq,omitempty"`
+
 	// Type is the event type.
 	Type string `json:"type"`
 
@@ -39,10 +46,90 @@ type Event struct {
 
 	Aggregate string `json:"aggregate"`
 
+	// LamportTime is the logical clock time of the producer when the event
+	// was published. See WithLamportClock.
+	LamportTime uint64 `json:"lamport_time,omitempty"`
+
 	// Meta supports arbitrary key-value information associated with the event.
 	Meta map[string]string `json:"meta,omitempty"`
 
+	// Headers hold transport metadata, such as trace IDs, content types,
+	// and routing keys, separately from the domain metadata in Meta.
+	Headers map[string][]string `json:"headers,omitempty"`
+
+	// Expiry is the time after which the event is no longer relevant.
+	// Subscriptions ack expired events without handling them and count
+	// them in Subscription.Expired. See WithExpiry.
+	Expiry time.Time `json:"expiry,omitempty"`
+
+	// Ephemeral events are published without being persisted to the stream.
+	// They are only received by subscriptions with the Ephemeral option that
+	// are active at the time of publishing.
+	Ephemeral bool `json:"ephemeral,omitempty"`
+
 	msg *stan.Msg
+
+	// Sends a reply to the requester. Set on received events published
+	// with Request.
+	reply func(r *Reply) error
+}
+
+// eventFields maps the names of fields that can be required on publish
+// to their values.
+var eventFields = map[string]func(*Event) string{
+	"type":           func(e *Event) string { return e.Type },
+	"aggregate":      func(e *Event) string { return e.Aggregate },
+	"cause":          func(e *Event) string { return e.Cause },
+	"correlation_id": func(e *Event) string { return e.Meta[CorrelationMetaKey] },
+}
+
+// ErrMissingField is returned when publishing an event that is missing
+// a required field.
+type ErrMissingField struct {
+	Field string
+}
+
+func (e *ErrMissingField) Error() string {
+	return "missing required event field: " + e.Field
+}
+
+// DeadLetterEvent returns the copy of an event that failed to be handled
+// which is published to the DeadLetterStream of a subscription. Backends
+// use this so dead-lettered events have the same meta keys.
+func DeadLetterEvent(evt *Event, err error, attempts int) *Event {
+	meta := make(map[string]string, len(evt.Meta)+4)
+	for k, v := range evt.Meta {
+		meta[k] = v
+	}
+
+	meta["dlq.original_stream"] = evt.Stream
+	meta["dlq.original_id"] = evt.ID
+	meta["dlq.error"] = err.Error()
+	meta["dlq.attempt_count"] = strconv.Itoa(attempts)
+
+	return &Event{
+		Type:      evt.Type,
+		Time:      evt.Time,
+		Data:      evt.Data,
+		Cause:     evt.Cause,
+		Aggregate: evt.Aggregate,
+		Meta:      meta,
+	}
+}
+
+// EncodeError is returned when publishing an event that cannot be encoded,
+// such as when its data fails to encode. Publishing the event again fails
+// the same way.
+type EncodeError struct {
+	Err error
+}
+
+func (e *EncodeError) Error() string {
+	return "event encode failed: " + e.Err.Error()
+}
+
+func (e *EncodeError) Unwrap() error {
+	return e.Err
 }
 
 // IsType returns true if the event is one of the passed types.
@@ -56,6 +143,49 @@ func (e *Event) Is(types ...string) bool {
 	return false
 }
 
+// BatchPublishError is returned by PublishBatch if not all events were
+// published.
+type BatchPublishError struct {
+	// IDs of the events that were published.
+	Succeeded []string
+
+	// Events that were not published, starting with the event that failed.
+	Failed []*Event
+
+	// Err is the error of the failed event.
+	Err error
+}
+
+func (e *BatchPublishError) Error() string {
+	return fmt.Sprintf("published %d of %d events: %s", len(e.Succeeded), len(e.Succeeded)+len(e.Failed), e.Err)
+}
+
+func (e *BatchPublishError) Unwrap() error {
+	return e.Err
+}
+
+// PublishEach publishes the events one at a time with the publish function,
+// stopping at the first failure. This can be used to implement PublishBatch
+// for backends without atomic batches.
+func PublishEach(publish func(stream string, evt *Event) (string, error), stream string, evts []*Event) ([]string, error) {
+	ids := make([]string, 0, len(evts))
+
+	for i, evt := range evts {
+		id, err := publish(stream, evt)
+		if err != nil {
+			return ids, &BatchPublishError{
+				Succeeded: ids,
+				Failed:    evts[i:],
+				Err:       err,
+			}
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
 // Handler is the event handler type for creating subscriptions.
 type Handler func(ctx context.Context, evt *Event) error
 
@@ -64,21 +194,116 @@ type Conn interface {
 	// Publish publishes an event to the specified stream. It returns the ID of the event.
 	Publish(stream string, evt *Event) (string, error)
 
+	// PublishBatch publishes the events to the stream in order. If an event
+	// fails to publish, the IDs of the events that were published are
+	// returned with a *BatchPublishError.
+	PublishBatch(stream string, evts []*Event) ([]string, error)
+
+	// Request publishes the event to the stream and waits for a handler of
+	// the event to reply with Event.Reply or until the context is done.
+	Request(ctx context.Context, stream string, evt *Event) (*Reply, error)
+
 	// Subscribe creates a subscription to the stream and associates the handler.
 	Subscribe(stream string, handle Handler, opts *SubscriptionOptions) (Subscription, error)
 
+	// Read returns an iterator over the events stored in the stream. Unlike
+	// a subscription, the iterator ends once the events in the range of the
+	// options have been read. A stream that no events have been published
+	// to reads as empty.
+	Read(stream string, opts *ReadOptions) (EventIterator, error)
+
+	// StreamStats returns statistics about the events stored in the stream.
+	StreamStats(ctx context.Context, stream string) (*StreamStats, error)
+
+	// MultiStreamStats returns statistics for multiple streams keyed by
+	// stream name.
+	MultiStreamStats(ctx context.Context, streams []string) (map[string]*StreamStats, error)
+
 	// Close closes the connection.
 	Close() error
 }
 
+// ErrSeqConflict is returned by PublishAfter if the last event in the
+// stream does not have the expected sequence.
+var ErrSeqConflict = errors.New("stream has events after the expected sequence")
+
+// ConditionalPublisher is implemented by connections that can publish
+// events only if no other events were published to the stream, for
+// optimistic concurrency control. The JetStream connection implements it.
+type ConditionalPublisher interface {
+	// PublishAfter publishes the events to the stream if the last event in
+	// the stream has the sequence, or the stream is empty if it is zero.
+	// Otherwise ErrSeqConflict is returned. Each following event is only
+	// published if the event before it is still the last, so a conflict
+	// part way through returns a *BatchPublishError wrapping ErrSeqConflict
+	// with the events that were published.
+	PublishAfter(stream string, evts []*Event, seq uint64) ([]string, error)
+}
+
+// StreamStats contains statistics about the events stored in a stream.
+type StreamStats struct {
+	// Sequence of the first and last events in the stream.
+	FirstSeq uint64 `json:"first_seq"`
+	LastSeq  uint64 `json:"last_seq"`
+
+	// Number of events in the stream.
+	MsgCount int64 `json:"msg_count"`
+
+	// Total size of the events in bytes.
+	ByteSize int64 `json:"byte_size"`
+
+	// Times the first and last events were acknowledged by the server.
+	FirstTime time.Time `json:"first_time"`
+	LastTime  time.Time `json:"last_time"`
+}
+
 type Subscription interface {
 	// Unsubscribe closes the subscription and resets the offset.
 	Unsubscribe() error
 
 	// Close closes the subscription and retains the offset.
 	Close() error
+
+	// GetLatest returns the latest event received for the key. This requires
+	// the subscription to be created with the LastValueCache option.
+	GetLatest(key string) (*Event, bool)
+
+	// Pause stops events from being handled until Resume is called, for
+	// example to apply backpressure. Events received while paused are not
+	// acknowledged, so they may be redelivered if the subscription is paused
+	// for longer than the Timeout option.
+	Pause() error
+
+	// Resume handles the events received while paused and resumes handling
+	// new events.
+	Resume() error
+
+	// IsPaused returns true if the subscription is paused.
+	IsPaused() bool
+
+	// Expired returns the number of expired events that were acked without
+	// being handled. See Event.Expiry.
+	Expired() uint64
 }
 
+// ErrSubscriptionClosed is returned when pausing or resuming a subscription
+// that has been closed.
+var ErrSubscriptionClosed = errors.New("subscription closed")
+
+// ErrUnsupportedOption is returned by Subscribe when an option is set that
+// the backend does not support.
+type ErrUnsupportedOption struct {
+	Option string
+}
+
+func (e *ErrUnsupportedOption) Error() string {
+	return "unsupported subscription option: " + e.Option
+}
+
+// ErrStartPositionConflict is returned by Subscribe if more than one of
+// the Backfill, StartSeq, and StartTime options is set.
+var ErrStartPositionConflict = errors.New("only one of Backfill, StartSeq, and StartTime can be set")
+
 type SubscriptionOptions struct {
 	// Unique name of the subscriber. This is used to keep track of the
 	// the offset of messages for a stream. This defaults to the stream name.
@@ -88,6 +313,14 @@ type SubscriptionOptions struct {
 	// in the stream. This useful for
 	Backfill bool
 
+	// StartSeq is the sequence of the first event a new subscription
+	// receives.
+	StartSeq uint64
+
+	// StartTime is the time from which a new subscription receives events,
+	// based on the time the server acknowledged them.
+	StartTime *time.Time
+
 	// If true, the stream offset will be tracked for the subscriber. Upon
 	// reconnect, the next message from the offset will be received.
 	Durable bool
@@ -102,7 +335,127 @@ type SubscriptionOptions struct {
 	// in order, one at a time, then this should be set to true.
 	Serial bool
 
+	// MaxConcurrency is the maximum number of events handled at the same
+	// time. Events are dispatched to handlers in the order they arrive, but
+	// may finish in any order. Closing the subscription waits for running
+	// handlers to return. If zero or one, events are handled one at a time
+	// as they arrive. This is ignored if Serial is true and is not supported
+	// by the Kafka backend, which handles partitions concurrently.
+	MaxConcurrency int
+
+	// PauseBuffer is the maximum number of events buffered while the
+	// subscription is paused, after which delivery from the server blocks
+	// until it is resumed. Defaults to 256. This does not apply to the Kafka
+	// backend, which stops consuming while paused.
+	PauseBuffer int
+
 	// The maximum time to wait before acknowledging an event was handled.
 	// If the timeout is reached, the server will redeliver the event.
 	Timeout time.Duration
+
+	// If true, the subscription receives ephemeral events published on the
+	// stream rather than persisted events. Ephemeral subscriptions are not
+	// load balanced across subscribers with the same name and the durable,
+	// backfill, and ack related options do not apply.
+	Ephemeral bool
+
+	// If true, an Acker is added to the handler context which can be retrieved
+	// with AckerFromContext. This allows the handler to acknowledge the event
+	// early, for example before doing slow asynchronous work. If the handler
+	// returns without calling Ack or Nack, a warning is logged and the event
+	// is acknowledged. This is only supported by the NATS backends.
+	ManualACK bool
+
+	// RetryBackoff are the durations to wait after successive failed attempts
+	// to handle an event before it is left to be redelivered. The number of
+	// attempts is tracked per event and the last duration is used for all
+	// subsequent attempts. The wait is jittered and will not exceed the
+	// handler context deadline.
+	RetryBackoff []time.Duration
+
+	// DeadLetterStream is the stream events are published to once they have
+	// failed to be handled on every attempt, after which they are acknowledged
+	// on the subscribed stream. An event is dead-lettered on the attempt
+	// following the last RetryBackoff duration, or on the first failure if
+	// RetryBackoff is empty. The published event has the meta keys
+	// "dlq.original_stream", "dlq.original_id", "dlq.error", and
+	// "dlq.attempt_count" in addition to the original meta. This is
+	// supported by all backends except Pub/Sub.
+	DeadLetterStream string
+
+	// TypeFilter limits the events passed to the handler to these types.
+	// Other events are acknowledged without being handled.
+	TypeFilter []string
+
+	// TypeExclude are event types that are acknowledged without being
+	// handled.
+	TypeExclude []string
+
+	// DeduplicateWindow is the duration an event is remembered once it has
+	// been handled. Events with the ID of a remembered event, such as those
+	// redelivered after a server restart, are acknowledged without being
+	// handled. If zero, events are not deduplicated. This is only supported
+	// by the NATS backends.
+	DeduplicateWindow time.Duration
+
+	// DeduplicateMaxSize is the maximum number of events remembered with
+	// DeduplicateWindow. The least recently handled events are forgotten
+	// first. Defaults to 10000.
+	DeduplicateMaxSize int
+
+	// If true, the subscription will keep the latest event per key in memory
+	// which can be queried with Subscription.GetLatest. This is useful for
+	// streams where only the current value matters, such as configuration
+	// or reference data. The cache is updated before the handler is called.
+	// This is only supported by the NATS backends.
+	LastValueCache bool
+
+	// KeyFn returns the cache key for an event when LastValueCache is enabled.
+	// This defaults to the event aggregate.
+	KeyFn func(*Event) string
+}
+
+// Validate returns an error if the options conflict.
+func (o *SubscriptionOptions) Validate() error {
+	var n int
+
+	if o.Backfill {
+		n++
+	}
+
+	if o.StartSeq > 0 {
+		n++
+	}
+
+	if o.StartTime != nil {
+		n++
+	}
+
+	if n > 1 {
+		return ErrStartPositionConflict
+	}
+
+	return nil
+}
+
+// AcceptsType returns true if events of the type are passed to the handler
+// given the TypeFilter and TypeExclude options.
+func (o *SubscriptionOptions) AcceptsType(typ string) bool {
+	for _, t := range o.TypeExclude {
+		if t == typ {
+			return false
+		}
+	}
+
+	if len(o.TypeFilter) == 0 {
+		return true
+	}
+
+	for _, t := range o.TypeFilter {
+		if t == typ {
+			return true
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,75 @@
+package eda
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPublishEachPartialFailure(t *testing.T) {
+	evts := []*Event{{Type: "a"}, {Type: "b"}, {Type: "c"}}
+
+	publish := func(stream string, evt *Event) (string, error) {
+		if evt.Type == "b" {
+			return "", errors.New("publish failed")
+		}
+		return evt.Type + "-id", nil
+	}
+
+	ids, err := PublishEach(publish, stream, evts)
+
+	var berr *BatchPublishError
+	if !errors.As(err, &berr) {
+		t.Fatalf("expected batch publish error, got %v", err)
+	}
+
+	if len(ids) != 1 || ids[0] != "a-id" {
+		t.Errorf("unexpected ids: %v", ids)
+	}
+
+	if len(berr.Succeeded) != 1 || len(berr.Failed) != 2 || berr.Failed[0] != evts[1] {
+		t.Errorf("unexpected error: %+v", berr)
+	}
+
+	if berr.Error() != "published 1 of 3 events: publish failed" {
+		t.Errorf("unexpected message: %s", berr)
+	}
+}
+
+func TestPublishBatch(t *testing.T) {
+	conn, err := Connect(addr, cluster, client)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	ids, err := conn.PublishBatch(stream, []*Event{{Type: "a"}, {Type: "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 2 || ids[0] == "" || ids[1] == "" {
+		t.Errorf("unexpected ids: %v", ids)
+	}
+}
+
+func TestAcceptsType(t *testing.T) {
+	tests := []struct {
+		opts SubscriptionOptions
+		typ  string
+		exp  bool
+	}{
+		{SubscriptionOptions{}, "a", true},
+		{SubscriptionOptions{TypeFilter: []string{"a"}}, "a", true},
+		{SubscriptionOptions{TypeFilter: []string{"a"}}, "b", false},
+		{SubscriptionOptions{TypeExclude: []string{"a"}}, "a", false},
+		{SubscriptionOptions{TypeExclude: []string{"a"}}, "b", true},
+		{SubscriptionOptions{TypeFilter: []string{"a", "b"}, TypeExclude: []string{"b"}}, "b", false},
+	}
+
+	for _, test := range tests {
+		if got := test.opts.AcceptsType(test.typ); got != test.exp {
+			t.Errorf("%+v: expected %v for %s, got %v", test.opts, test.exp, test.typ, got)
+		}
+	}
+}
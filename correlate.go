@@ -0,0 +1,141 @@
+package eda
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/nats-io/nuid"
+)
+
+// ErrEventNotFound is returned when an event is not known to a view.
+var ErrEventNotFound = errors.New("event not found")
+
+// CorrelatedView maintains an in-memory index of events on a secondary
+// stream that are correlated to events on a primary stream.
+type CorrelatedView struct {
+	conn      Conn
+	primary   string
+	secondary string
+	fn        func(primary, secondary *Event) bool
+
+	mux         sync.RWMutex
+	primaries   map[string]*Event
+	secondaries []*Event
+	index       map[string][]*Event
+	subs        []Subscription
+}
+
+func (v *CorrelatedView) handlePrimary(ctx context.Context, evt *Event) error {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	v.primaries[evt.ID] = evt
+
+	for _, s := range v.secondaries {
+		if v.fn(evt, s) {
+			v.index[evt.ID] = append(v.index[evt.ID], s)
+		}
+	}
+
+	return nil
+}
+
+func (v *CorrelatedView) handleSecondary(ctx context.Context, evt *Event) error {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	v.secondaries = append(v.secondaries, evt)
+
+	for id, p := range v.primaries {
+		if v.fn(p, evt) {
+			v.index[id] = append(v.index[id], evt)
+		}
+	}
+
+	return nil
+}
+
+// Start subscribes to both streams from the beginning to build the index.
+// The view is stopped when the context is done.
+func (v *CorrelatedView) Start(ctx context.Context) error {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	if v.subs != nil {
+		return errors.New("correlated view already started")
+	}
+
+	// Unique names so the view receives all events rather than sharing
+	// them with other subscribers.
+	psub, err := v.conn.Subscribe(v.primary, v.handlePrimary, &SubscriptionOptions{
+		Name:     nuid.Next(),
+		Backfill: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	ssub, err := v.conn.Subscribe(v.secondary, v.handleSecondary, &SubscriptionOptions{
+		Name:     nuid.Next(),
+		Backfill: true,
+	})
+	if err != nil {
+		psub.Close()
+		return err
+	}
+
+	v.subs = []Subscription{psub, ssub}
+
+	go func() {
+		<-ctx.Done()
+		v.Stop()
+	}()
+
+	return nil
+}
+
+// Stop closes the subscriptions. The index is retained.
+func (v *CorrelatedView) Stop() error {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	var err error
+	for _, sub := range v.subs {
+		if e := sub.Unsubscribe(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	v.subs = nil
+
+	return err
+}
+
+// Query returns the secondary events correlated to the primary event.
+func (v *CorrelatedView) Query(primaryID string) ([]*Event, error) {
+	v.mux.RLock()
+	defer v.mux.RUnlock()
+
+	if _, ok := v.primaries[primaryID]; !ok {
+		return nil, ErrEventNotFound
+	}
+
+	evts := make([]*Event, len(v.index[primaryID]))
+	copy(evts, v.index[primaryID])
+
+	return evts, nil
+}
+
+// NewCorrelatedView returns a view of the events on the secondary stream
+// that are correlated to events on the primary stream by the function.
+func NewCorrelatedView(conn Conn, primaryStream, secondaryStream string, correlationFn func(primary, secondary *Event) bool) *CorrelatedView {
+	return &CorrelatedView{
+		conn:      conn,
+		primary:   primaryStream,
+		secondary: secondaryStream,
+		fn:        correlationFn,
+		primaries: make(map[string]*Event),
+		index:     make(map[string][]*Event),
+	}
+}
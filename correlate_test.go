@@ -0,0 +1,42 @@
+package eda
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCorrelatedView(t *testing.T) {
+	v := NewCorrelatedView(nil, "visits", "tests", func(p, s *Event) bool {
+		return p.Aggregate == s.Aggregate
+	})
+
+	ctx := context.Background()
+
+	// Secondary events may arrive before or after the primary.
+	v.handleSecondary(ctx, &Event{ID: "t1", Aggregate: "a"})
+	v.handlePrimary(ctx, &Event{ID: "v1", Aggregate: "a"})
+	v.handlePrimary(ctx, &Event{ID: "v2", Aggregate: "b"})
+	v.handleSecondary(ctx, &Event{ID: "t2", Aggregate: "a"})
+
+	evts, err := v.Query("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(evts) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(evts))
+	}
+
+	evts, err = v.Query("v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(evts) != 0 {
+		t.Fatalf("expected 0 events, got %d", len(evts))
+	}
+
+	if _, err := v.Query("v3"); err != ErrEventNotFound {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package eda
+
+import "context"
+
+// CorrelationMetaKey is the meta key of the ID that groups related events,
+// such as those of a single request or saga, across streams.
+const CorrelationMetaKey = "correlation_id"
+
+// CorrelationID returns the correlation ID of the event or an empty string
+// if it is not set.
+func (e *Event) CorrelationID() string {
+	return e.Meta[CorrelationMetaKey]
+}
+
+// WithCorrelationID returns a copy of the context with the correlation ID.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationContextKey, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID of the context. The
+// context passed to a handler has the correlation ID of the event being
+// handled.
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(correlationContextKey).(string)
+	return id, ok && id != ""
+}
+
+// PublishWithCorrelation publishes the event with the correlation ID of the
+// context, unless the event already has one. The event is not modified.
+func PublishWithCorrelation(ctx context.Context, conn Conn, stream string, evt *Event) (string, error) {
+	id, ok := CorrelationIDFromContext(ctx)
+	if !ok || evt == nil || evt.CorrelationID() != "" {
+		return conn.Publish(stream, evt)
+	}
+
+	meta := make(map[string]string, len(evt.Meta)+1)
+	for k, v := range evt.Meta {
+		meta[k] = v
+	}
+	meta[CorrelationMetaKey] = id
+
+	e := *evt
+	e.Meta = meta
+
+	return conn.Publish(stream, &e)
+}
@@ -0,0 +1,74 @@
+package eda
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nuid"
+)
+
+func TestCorrelationIDContext(t *testing.T) {
+	ctx := context.Background()
+
+	if _, ok := CorrelationIDFromContext(ctx); ok {
+		t.Error("expected no correlation ID")
+	}
+
+	ctx = WithCorrelationID(ctx, "abc")
+
+	if id, ok := CorrelationIDFromContext(ctx); !ok || id != "abc" {
+		t.Errorf("expected abc, got %q", id)
+	}
+}
+
+func TestPublishWithCorrelation(t *testing.T) {
+	conn, err := Connect(addr, cluster, client)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	stream := stream + "-correlation-" + nuid.Next()
+
+	received := make(chan string, 2)
+
+	sub, err := conn.Subscribe(stream, func(ctx context.Context, evt *Event) error {
+		id, _ := CorrelationIDFromContext(ctx)
+		received <- id
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	ctx := WithCorrelationID(context.Background(), "abc")
+
+	evt := &Event{Type: "a"}
+	if _, err := PublishWithCorrelation(ctx, conn, stream, evt); err != nil {
+		t.Fatal(err)
+	}
+
+	if evt.Meta != nil {
+		t.Errorf("expected event to be unmodified, got %v", evt.Meta)
+	}
+
+	// An existing correlation ID is kept.
+	evt = &Event{Type: "b", Meta: map[string]string{CorrelationMetaKey: "def"}}
+	if _, err := PublishWithCorrelation(ctx, conn, stream, evt); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, exp := range []string{"abc", "def"} {
+		select {
+		case id := <-received:
+			if id != exp {
+				t.Errorf("expected correlation ID %s, got %q", exp, id)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("event not received")
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package eda
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// defaultDeduplicateMaxSize is the maximum number of IDs kept by a
+// subscription with a DeduplicateWindow if DeduplicateMaxSize is not set.
+const defaultDeduplicateMaxSize = 10000
+
+type dedupEntry struct {
+	id  string
+	exp time.Time
+}
+
+// dedupCache is an LRU cache of event IDs that expire after the window.
+type dedupCache struct {
+	mux     sync.Mutex
+	window  time.Duration
+	maxSize int
+	now     func() time.Time
+
+	// Entries ordered from most to least recently added.
+	entries *list.List
+	index   map[string]*list.Element
+}
+
+func newDedupCache(window time.Duration, maxSize int) *dedupCache {
+	if maxSize <= 0 {
+		maxSize = defaultDeduplicateMaxSize
+	}
+
+	return &dedupCache{
+		window:  window,
+		maxSize: maxSize,
+		now:     time.Now,
+		entries: list.New(),
+		index:   make(map[string]*list.Element),
+	}
+}
+
+// contains returns true if the ID was added within the window.
+func (c *dedupCache) contains(id string) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	e, ok := c.index[id]
+	if !ok {
+		return false
+	}
+
+	if !c.now().Before(e.Value.(*dedupEntry).exp) {
+		c.remove(e)
+		return false
+	}
+
+	return true
+}
+
+// add adds the ID, evicting expired IDs and the least recently added IDs
+// beyond the max size.
+func (c *dedupCache) add(id string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	now := c.now()
+	exp := now.Add(c.window)
+
+	if e, ok := c.index[id]; ok {
+		e.Value.(*dedupEntry).exp = exp
+		c.entries.MoveToFront(e)
+	} else {
+		c.index[id] = c.entries.PushFront(&dedupEntry{id: id, exp: exp})
+	}
+
+	for e := c.entries.Back(); e != nil; e = c.entries.Back() {
+		if c.entries.Len() <= c.maxSize && now.Before(e.Value.(*dedupEntry).exp) {
+			break
+		}
+
+		c.remove(e)
+	}
+}
+
+func (c *dedupCache) remove(e *list.Element) {
+	c.entries.Remove(e)
+	delete(c.index, e.Value.(*dedupEntry).id)
+}
@@ -0,0 +1,98 @@
+package eda
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestDedupCache(t *testing.T) {
+	now := time.Now()
+
+	c := newDedupCache(time.Minute, 2)
+	c.now = func() time.Time { return now }
+
+	c.add("a")
+	c.add("b")
+
+	if !c.contains("a") || !c.contains("b") {
+		t.Fatal("expected a and b")
+	}
+
+	// The least recently added ID is evicted.
+	c.add("a")
+	c.add("c")
+
+	if c.contains("b") {
+		t.Error("expected b to be evicted")
+	}
+
+	if !c.contains("a") || !c.contains("c") {
+		t.Error("expected a and c")
+	}
+
+	now = now.Add(time.Minute)
+
+	if c.contains("a") {
+		t.Error("expected a to expire")
+	}
+
+	// Expired IDs are evicted when adding.
+	c.add("d")
+
+	if c.entries.Len() != 1 || len(c.index) != 1 {
+		t.Errorf("expected 1 entry, got %d", c.entries.Len())
+	}
+}
+
+func TestSubscriptionDeduplicate(t *testing.T) {
+	c := &baseConn{
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		envelope: envelopeCodecs["proto"],
+		now:      time.Now,
+	}
+
+	_, b, err := c.encode(&Event{Type: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls, acks int
+
+	fail := true
+
+	opts := &SubscriptionOptions{
+		Timeout:           time.Second,
+		DeduplicateWindow: time.Minute,
+	}
+
+	sub := c.newSubscription(stream, "dedup", opts)
+
+	h := c.msgHandler(sub, func(ctx context.Context, evt *Event) error {
+		calls++
+		if fail {
+			fail = false
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	}, opts)
+
+	// The failed delivery is not remembered, so the event is handled on
+	// the second delivery and acked without being handled on the third.
+	for i := 0; i < 3; i++ {
+		h(&rawMsg{
+			subject: stream,
+			data:    b,
+			ack: func() error {
+				acks++
+				return nil
+			},
+		})
+	}
+
+	if calls != 2 || acks != 2 {
+		t.Errorf("expected 2 calls and 2 acks, got %d and %d", calls, acks)
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Package edatest provides utilities for testing code that uses eda.
+
+	conn := &edatest.MemConn{}
+	conn.Publish("orders", &eda.Event{Type: "order-placed"})
+
+	repo := aggregate.NewRepository(conn, "orders", newOrder)
+*/
+package edatest
+
+import (
+	"context"
+	"io"
+	"strconv"
+
+	"github.com/chop-dbhi/eda"
+)
+
+// MemConn is an eda.Conn that stores published events in memory for
+// reading. Events are assigned sequences starting at 1 and their sequence
+// as ID regardless of the stream they are published to. Methods other than
+// Publish, PublishBatch and Read panic unless a Conn is embedded.
+type MemConn struct {
+	eda.Conn
+
+	// Events are the published events in order.
+	Events []*eda.Event
+}
+
+// Publish appends a copy of the event.
+func (c *MemConn) Publish(stream string, evt *eda.Event) (string, error) {
+	e := *evt
+	e.Stream = stream
+	e.Seq = uint64(len(c.Events) + 1)
+	e.ID = strconv.FormatUint(e.Seq, 10)
+	c.Events = append(c.Events, &e)
+	return e.ID, nil
+}
+
+// PublishBatch appends a copy of each event.
+func (c *MemConn) PublishBatch(stream string, evts []*eda.Event) ([]string, error) {
+	return eda.PublishEach(c.Publish, stream, evts)
+}
+
+// Read iterates over the events published so far, starting at
+// opts.From.Seq if set.
+func (c *MemConn) Read(stream string, opts *eda.ReadOptions) (eda.EventIterator, error) {
+	evts := c.Events
+	if opts != nil && opts.From.Seq > 0 {
+		evts = evts[min(int(opts.From.Seq)-1, len(evts)):]
+	}
+
+	return eda.NewEventIterator(func(ctx context.Context) (*eda.Event, error) {
+		if len(evts) == 0 {
+			return nil, io.EOF
+		}
+		evt := evts[0]
+		evts = evts[1:]
+		return evt, nil
+	}, nil, opts), nil
+}
@@ -0,0 +1,44 @@
+package edatest
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/chop-dbhi/eda"
+)
+
+func TestMemConn(t *testing.T) {
+	conn := &MemConn{}
+
+	ids, err := conn.PublishBatch("orders", []*eda.Event{{Type: "a"}, {Type: "b"}, {Type: "c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 3 || ids[2] != "3" {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+
+	it, err := conn.Read("orders", &eda.ReadOptions{From: eda.ReadPosition{Seq: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	var types string
+	for {
+		evt, err := it.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		types += evt.Type
+	}
+
+	if types != "bc" {
+		t.Errorf("expected events from seq 2, got %q", types)
+	}
+}
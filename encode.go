@@ -1,11 +1,15 @@
 package eda
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"sync"
 
+	"github.com/fxamacker/cbor/v2"
+	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
+	"github.com/hamba/avro/v2/ocf"
 )
 
 // Encoders are the set of built-in data encMap.
@@ -13,11 +17,14 @@ var (
 	encMux = &sync.Mutex{}
 
 	encMap = map[string]encoder{
-		"bytes":  &bytesEncoder{},
-		"string": &stringEncoder{},
-		"json":   &jsonEncoder{},
-		"proto":  &protoEncoder{},
-		"nil":    &nilEncoder{},
+		"bytes":      &bytesEncoder{},
+		"string":     &stringEncoder{},
+		"json":       &jsonEncoder{},
+		"proto":      &protoEncoder{},
+		"proto+json": &protoJSONEncoder{},
+		"avro":       &avroEncoder{},
+		"cbor":       &cborEncoder{},
+		"nil":        &nilEncoder{},
 	}
 )
 
@@ -64,6 +71,36 @@ func Proto(m proto.Message) Data {
 	}
 }
 
+// ProtoJSON returns Data that encodes and decodes the proto message using
+// the proto JSON mapping.
+func ProtoJSON(m proto.Message) Data {
+	return &decodable{
+		t:   "proto+json",
+		v:   m,
+		enc: encMap["proto+json"],
+	}
+}
+
+// Avro returns Data that encodes the value using the Avro schema. The data
+// is encoded as an Avro object container so the schema is embedded and the
+// data can be decoded without it.
+func Avro(schema string, v interface{}) Data {
+	return &decodable{
+		t:   "avro",
+		v:   &avroValue{schema: schema, v: v},
+		enc: encMap["avro"],
+	}
+}
+
+// CBOR returns Data that encodes and decodes the value using CBOR.
+func CBOR(v interface{}) Data {
+	return &decodable{
+		t:   "cbor",
+		v:   v,
+		enc: encMap["cbor"],
+	}
+}
+
 type nilEncoder struct{}
 
 func (n *nilEncoder) Type() string {
@@ -145,6 +182,31 @@ func (e *protoEncoder) Decode(b []byte, v interface{}) error {
 	return proto.Unmarshal(b, x)
 }
 
+type protoJSONEncoder struct{}
+
+func (e *protoJSONEncoder) Encode(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, errors.New("proto message required")
+	}
+
+	var buf bytes.Buffer
+	if err := (&jsonpb.Marshaler{}).Marshal(&buf, m); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (e *protoJSONEncoder) Decode(b []byte, v interface{}) error {
+	x, ok := v.(proto.Message)
+	if !ok {
+		return errors.New("proto.Message required")
+	}
+
+	return jsonpb.Unmarshal(bytes.NewReader(b), x)
+}
+
 // Data encapsulates a value with a known encoding scheme.
 type Data interface {
 	// Type returns the encoding type used to encode the data to bytes.
@@ -194,3 +256,64 @@ func (r *decodable) Decode(v interface{}) error {
 
 	return errors.New("cannot decode non-encoded data")
 }
+
+type cborEncoder struct{}
+
+func (e *cborEncoder) Encode(v interface{}) ([]byte, error) {
+	return cbor.Marshal(v)
+}
+
+func (e *cborEncoder) Decode(b []byte, v interface{}) error {
+	return cbor.Unmarshal(b, v)
+}
+
+// avroValue is a value to encode with its schema.
+type avroValue struct {
+	schema string
+	v      interface{}
+}
+
+type avroEncoder struct{}
+
+func (e *avroEncoder) Encode(v interface{}) ([]byte, error) {
+	x, ok := v.(*avroValue)
+	if !ok {
+		return nil, errors.New("avro value required")
+	}
+
+	var buf bytes.Buffer
+
+	enc, err := ocf.NewEncoder(x.schema, &buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := enc.Encode(x.v); err != nil {
+		return nil, err
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decode decodes into a pointer to a struct with avro tags or a
+// map[string]interface{}.
+func (e *avroEncoder) Decode(b []byte, v interface{}) error {
+	dec, err := ocf.NewDecoder(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	if !dec.HasNext() {
+		if err := dec.Error(); err != nil {
+			return err
+		}
+
+		return errors.New("avro data is empty")
+	}
+
+	return dec.Decode(v)
+}
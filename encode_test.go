@@ -163,3 +163,127 @@ func TestProtoEncodable(t *testing.T) {
 		t.Fatalf("decoded bytes not equal: %v != %v", &n, &r)
 	}
 }
+
+func TestProtoJSONEncodable(t *testing.T) {
+	r := pb.Event{
+		Id:      "foo",
+		AckTime: 10,
+	}
+	e := ProtoJSON(&r)
+
+	// Encode.
+	b, err := e.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(b, []byte(`"ackTime":"10"`)) {
+		t.Fatalf("expected proto JSON mapping: %s", b)
+	}
+
+	// Decode.
+	var n pb.Event
+	if err := (&protoJSONEncoder{}).Decode(b, &n); err != nil {
+		t.Fatal(err)
+	}
+
+	if !proto.Equal(&n, &r) {
+		t.Fatalf("decoded proto not equal: %v != %v", &n, &r)
+	}
+}
+
+const avroSchema = `{
+	"type": "record",
+	"name": "Subject",
+	"fields": [
+		{"name": "id", "type": "string"},
+		{"name": "site", "type": {
+			"type": "record",
+			"name": "Site",
+			"fields": [{"name": "name", "type": "string"}]
+		}},
+		{"name": "age", "type": ["null", "int"]}
+	]
+}`
+
+type avroSite struct {
+	Name string `avro:"name"`
+}
+
+type avroSubject struct {
+	ID   string   `avro:"id"`
+	Site avroSite `avro:"site"`
+	Age  *int     `avro:"age"`
+}
+
+func TestAvroEncodable(t *testing.T) {
+	age := 42
+
+	e := Avro(avroSchema, &avroSubject{
+		ID:   "1",
+		Site: avroSite{Name: "chop"},
+		Age:  &age,
+	})
+
+	if e.Type() != "avro" {
+		t.Fatalf("expected avro type, got %s", e.Type())
+	}
+
+	b, err := e.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s avroSubject
+	if err := (&avroEncoder{}).Decode(b, &s); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.ID != "1" || s.Site.Name != "chop" || s.Age == nil || *s.Age != 42 {
+		t.Fatalf("unexpected decoded value: %+v", s)
+	}
+
+	// Null union and decoding to a map.
+	b, err = Avro(avroSchema, &avroSubject{ID: "2"}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m map[string]interface{}
+	if err := (&avroEncoder{}).Decode(b, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	site, _ := m["site"].(map[string]interface{})
+	if m["id"] != "2" || site["name"] != "" || m["age"] != nil {
+		t.Fatalf("unexpected decoded map: %v", m)
+	}
+}
+
+func TestCBOREncodable(t *testing.T) {
+	type reading struct {
+		Device string
+		Value  float64
+		Tags   []string
+	}
+
+	r := reading{
+		Device: "sensor-1",
+		Value:  36.6,
+		Tags:   []string{"temp"},
+	}
+
+	b, err := CBOR(&r).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n reading
+	if err := (&cborEncoder{}).Decode(b, &n); err != nil {
+		t.Fatal(err)
+	}
+
+	if n.Device != r.Device || n.Value != r.Value || len(n.Tags) != 1 {
+		t.Fatalf("decoded value not equal: %+v != %+v", n, r)
+	}
+}
@@ -0,0 +1,98 @@
+package eda
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+)
+
+// aesGCMSuffix is appended to the name of the inner encoding of encrypted
+// data, such as "json+aes256gcm".
+const aesGCMSuffix = "+aes256gcm"
+
+// ErrDecryptionFailed is returned when decoding encrypted data with the
+// wrong key or data that has been tampered with.
+var ErrDecryptionFailed = errors.New("decryption failed")
+
+// EncryptedJSON returns Data that encodes the value as JSON and encrypts it
+// with AES-256-GCM using the 32-byte key. Consumers decrypt the data when
+// decoding if their connection has the key, see WithEncryptionKey.
+func EncryptedJSON(v interface{}, key []byte) Data {
+	return &decodable{
+		t: "json" + aesGCMSuffix,
+		v: v,
+		enc: &aesGCMEncoder{
+			enc: encMap["json"],
+			key: key,
+		},
+	}
+}
+
+// decryptWith sets the key used to decode the data if it is encrypted.
+func decryptWith(d Data, key []byte) {
+	r, ok := d.(*decodable)
+	if !ok || !strings.HasSuffix(r.t, aesGCMSuffix) {
+		return
+	}
+
+	r.enc = &aesGCMEncoder{
+		enc: encMap[strings.TrimSuffix(r.t, aesGCMSuffix)],
+		key: key,
+	}
+}
+
+// aesGCMEncoder encrypts the output of the inner encoder. The nonce is
+// prepended to the ciphertext.
+type aesGCMEncoder struct {
+	enc encoder
+	key []byte
+}
+
+func (e *aesGCMEncoder) Encode(v interface{}) ([]byte, error) {
+	if len(e.key) != 32 {
+		return nil, errors.New("AES-256 key must be 32 bytes")
+	}
+
+	b, err := e.enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(e.key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(b)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, b, nil), nil
+}
+
+func (e *aesGCMEncoder) Decode(b []byte, v interface{}) error {
+	if e.enc == nil {
+		return errors.New("unknown inner encoding")
+	}
+
+	if len(e.key) != 32 {
+		return errors.New("AES-256 key must be 32 bytes")
+	}
+
+	gcm, err := newGCM(e.key)
+	if err != nil {
+		return err
+	}
+
+	if len(b) < gcm.NonceSize() {
+		return ErrDecryptionFailed
+	}
+
+	p, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], nil)
+	if err != nil {
+		return ErrDecryptionFailed
+	}
+
+	return e.enc.Decode(p, v)
+}
@@ -0,0 +1,59 @@
+package eda
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptedJSON(t *testing.T) {
+	key := bytes.Repeat([]byte("k"), 32)
+
+	d := EncryptedJSON(map[string]string{"mrn": "123"}, key)
+
+	if d.Type() != "json+aes256gcm" {
+		t.Fatalf("unexpected type: %s", d.Type())
+	}
+
+	b, err := d.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(b, []byte("123")) {
+		t.Fatal("expected encrypted bytes")
+	}
+
+	received := func(b []byte, key []byte) Data {
+		r := &decodable{b: b, t: d.Type(), e: true}
+		decryptWith(r, key)
+		return r
+	}
+
+	var v map[string]string
+	if err := received(b, key).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v["mrn"] != "123" {
+		t.Errorf("unexpected decoded value: %v", v)
+	}
+
+	// Wrong key.
+	wrong := bytes.Repeat([]byte("x"), 32)
+	if err := received(b, wrong).Decode(&v); err != ErrDecryptionFailed {
+		t.Errorf("expected decryption error for wrong key, got %v", err)
+	}
+
+	// Tampered ciphertext.
+	tampered := append([]byte(nil), b...)
+	tampered[len(tampered)-1] ^= 0xff
+
+	if err := received(tampered, key).Decode(&v); err != ErrDecryptionFailed {
+		t.Errorf("expected decryption error for tampered data, got %v", err)
+	}
+
+	// Invalid key size.
+	if _, err := EncryptedJSON(v, []byte("short")).Encode(); err == nil {
+		t.Error("expected error for short key")
+	}
+}
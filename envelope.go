@@ -0,0 +1,220 @@
+package eda
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/chop-dbhi/eda/internal/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+// Envelope codecs by name and by version byte.
+var (
+	envelopeCodecs = map[string]envelopeCodec{
+		"proto": &protoEnvelope{},
+		"json":  &jsonEnvelope{},
+	}
+
+	envelopeVersions = map[byte]envelopeCodec{
+		protoEnvelopeVersion: &protoEnvelope{},
+		jsonEnvelopeVersion:  &jsonEnvelope{},
+	}
+)
+
+// Version bytes prepended to an encoded envelope. A proto message cannot
+// begin with these values since field numbers start at 1, so envelopes
+// without a version byte are decoded as proto.
+const (
+	protoEnvelopeVersion byte = 0x01
+	jsonEnvelopeVersion  byte = 0x02
+)
+
+// envelopeCodec marshals the event envelope sent to the backend.
+type envelopeCodec interface {
+	Version() byte
+	Marshal(e *pb.Event) ([]byte, error)
+	Unmarshal(b []byte, e *pb.Event) error
+}
+
+type protoEnvelope struct{}
+
+func (c *protoEnvelope) Version() byte {
+	return protoEnvelopeVersion
+}
+
+func (c *protoEnvelope) Marshal(e *pb.Event) ([]byte, error) {
+	return proto.Marshal(e)
+}
+
+func (c *protoEnvelope) Unmarshal(b []byte, e *pb.Event) error {
+	return proto.Unmarshal(b, e)
+}
+
+type jsonEnvelope struct{}
+
+func (c *jsonEnvelope) Version() byte {
+	return jsonEnvelopeVersion
+}
+
+func (c *jsonEnvelope) Marshal(e *pb.Event) ([]byte, error) {
+	return json.Marshal(e)
+}
+
+func (c *jsonEnvelope) Unmarshal(b []byte, e *pb.Event) error {
+	return json.Unmarshal(b, e)
+}
+
+// encodeEnvelope encodes the envelope and prepends the version byte. Proto
+// envelopes are left as is to remain readable by older clients.
+func encodeEnvelope(c envelopeCodec, e *pb.Event) ([]byte, error) {
+	b, err := c.Marshal(e)
+	if err != nil {
+		return nil, &EncodeError{Err: err}
+	}
+
+	if c.Version() == protoEnvelopeVersion {
+		return b, nil
+	}
+
+	return append([]byte{c.Version()}, b...), nil
+}
+
+// decodeEnvelope decodes the envelope based on the leading version byte
+// which allows for mixed formats on a stream.
+func decodeEnvelope(b []byte, e *pb.Event) error {
+	if len(b) > 0 {
+		if c, ok := envelopeVersions[b[0]]; ok {
+			return c.Unmarshal(b[1:], e)
+		}
+	}
+
+	return proto.Unmarshal(b, e)
+}
+
+// eventToPB converts the event to the envelope message, encoding the data.
+// The ID and client are copied as is and are expected to be set by the
+// caller when publishing.
+func eventToPB(evt *Event) (*pb.Event, error) {
+	var (
+		err      error
+		datab    []byte
+		encoding string
+	)
+
+	if evt.Data == nil {
+		encoding = "nil"
+	} else {
+		encoding = evt.Data.Type()
+		datab, err = evt.Data.Encode()
+	}
+
+	if err != nil {
+		return nil, &EncodeError{Err: err}
+	}
+
+	e := &pb.Event{
+		Id:          evt.ID,
+		Type:        evt.Type,
+		Cause:       evt.Cause,
+		Time:        evt.Time.UnixNano(),
+		Client:      evt.Client,
+		Data:        datab,
+		Encoding:    encoding,
+		Meta:        evt.Meta,
+		Aggregate:   evt.Aggregate,
+		LamportTime: evt.LamportTime,
+		Headers:     headersToPB(evt.Headers),
+	}
+
+	if !evt.AckTime.IsZero() {
+		e.AckTime = evt.AckTime.UnixNano()
+	}
+
+	if !evt.Expiry.IsZero() {
+		e.Expiry = evt.Expiry.UnixNano()
+	}
+
+	return e, nil
+}
+
+func headersToPB(h map[string][]string) map[string]*pb.HeaderValues {
+	if len(h) == 0 {
+		return nil
+	}
+
+	m := make(map[string]*pb.HeaderValues, len(h))
+	for k, v := range h {
+		m[k] = &pb.HeaderValues{Values: v}
+	}
+
+	return m
+}
+
+func headersFromPB(m map[string]*pb.HeaderValues) map[string][]string {
+	if len(m) == 0 {
+		return nil
+	}
+
+	h := make(map[string][]string, len(m))
+	for k, v := range m {
+		h[k] = v.GetValues()
+	}
+
+	return h
+}
+
+// eventFromPB converts the envelope message to an event. The data is left
+// encoded until it is decoded by the consumer.
+func eventFromPB(e *pb.Event) *Event {
+	evt := &Event{
+		Stream:    e.Stream,
+		ID:        e.Id,
+		Time:      time.Unix(0, e.Time),
+		Type:      e.Type,
+		Cause:     e.Cause,
+		Client:    e.Client,
+		Meta:      e.Meta,
+		Aggregate: e.Aggregate,
+		Data: &decodable{
+			b:   e.Data,
+			t:   e.Encoding,
+			e:   true,
+			enc: encMap[e.Encoding],
+		},
+		LamportTime: e.LamportTime,
+		Headers:     headersFromPB(e.Headers),
+	}
+
+	if e.AckTime > 0 {
+		evt.AckTime = time.Unix(0, e.AckTime)
+	}
+
+	if e.Expiry > 0 {
+		evt.Expiry = time.Unix(0, e.Expiry)
+	}
+
+	return evt
+}
+
+// MarshalEvent encodes the event, including its ID and stream, using the
+// proto envelope. This is intended for storing events outside of a stream.
+func MarshalEvent(evt *Event) ([]byte, error) {
+	e, err := eventToPB(evt)
+	if err != nil {
+		return nil, err
+	}
+
+	e.Stream = evt.Stream
+
+	return encodeEnvelope(envelopeCodecs["proto"], e)
+}
+
+// UnmarshalEvent decodes an event encoded by MarshalEvent.
+func UnmarshalEvent(b []byte) (*Event, error) {
+	var e pb.Event
+	if err := decodeEnvelope(b, &e); err != nil {
+		return nil, err
+	}
+
+	return eventFromPB(&e), nil
+}
@@ -0,0 +1,92 @@
+package eda
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chop-dbhi/eda/internal/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestEnvelopeCodecs(t *testing.T) {
+	x := &pb.Event{
+		Id:   "foo",
+		Type: "bar",
+		Data: []byte("baz"),
+		Meta: map[string]string{
+			"qux": "quux",
+		},
+		Headers: map[string]*pb.HeaderValues{
+			"Accept": {Values: []string{"application/json", "text/plain"}},
+		},
+	}
+
+	for name, c := range envelopeCodecs {
+		b, err := encodeEnvelope(c, x)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		var v pb.Event
+		if err := decodeEnvelope(b, &v); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if !proto.Equal(x, &v) {
+			t.Fatalf("%s: decoded envelope not equal: %v != %v", name, x, &v)
+		}
+	}
+}
+
+func TestLegacyEnvelope(t *testing.T) {
+	x := &pb.Event{
+		Id: "foo",
+	}
+
+	b, _ := proto.Marshal(x)
+
+	var v pb.Event
+	if err := decodeEnvelope(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !proto.Equal(x, &v) {
+		t.Fatalf("decoded envelope not equal: %v != %v", x, &v)
+	}
+}
+
+func TestEventHeaders(t *testing.T) {
+	evt := &Event{
+		ID:   "foo",
+		Type: "bar",
+		Meta: map[string]string{"user": "1"},
+		Headers: map[string][]string{
+			"Trace-Id": {"abc"},
+			"Accept":   {"application/json", "text/plain"},
+		},
+	}
+
+	b, err := MarshalEvent(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := UnmarshalEvent(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v.Headers, evt.Headers) {
+		t.Errorf("expected headers %v, got %v", evt.Headers, v.Headers)
+	}
+
+	if v.Meta["user"] != "1" || len(v.Meta) != 1 {
+		t.Errorf("expected meta to be kept separate, got %v", v.Meta)
+	}
+
+	// Events without headers are decoded with nil headers.
+	b, _ = MarshalEvent(&Event{ID: "foo"})
+	if v, _ = UnmarshalEvent(b); v.Headers != nil {
+		t.Errorf("expected nil headers, got %v", v.Headers)
+	}
+}
@@ -0,0 +1,20 @@
+package eda
+
+import "time"
+
+// WithExpiry returns a function that sets the expiry of an event to the
+// duration from now. Expiry is compared with the wall clock, not the Clock
+// connect option, so events expire at the same time on every backend.
+//
+//	evt := &eda.Event{Type: "reminder-sent"}
+//	eda.WithExpiry(time.Hour)(evt)
+func WithExpiry(d time.Duration) func(*Event) {
+	return func(e *Event) {
+		e.Expiry = time.Now().Add(d)
+	}
+}
+
+// IsExpired returns true if the event has an expiry that has passed.
+func (e *Event) IsExpired() bool {
+	return !e.Expiry.IsZero() && e.Expiry.Before(time.Now())
+}
@@ -0,0 +1,99 @@
+package eda
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestWithExpiry(t *testing.T) {
+	evt := &Event{}
+	WithExpiry(time.Hour)(evt)
+
+	if d := time.Until(evt.Expiry); d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("expected expiry in an hour, got %s", evt.Expiry)
+	}
+
+	b, err := MarshalEvent(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := UnmarshalEvent(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !v.Expiry.Equal(evt.Expiry) {
+		t.Errorf("expected expiry %s, got %s", evt.Expiry, v.Expiry)
+	}
+
+	if v.IsExpired() {
+		t.Error("expected event to not be expired")
+	}
+
+	if !(&Event{Expiry: time.Now().Add(-time.Second)}).IsExpired() {
+		t.Error("expected event to be expired")
+	}
+
+	if (&Event{}).IsExpired() {
+		t.Error("expected event without expiry to not expire")
+	}
+}
+
+func TestSubscriptionExpired(t *testing.T) {
+	// The connection clock does not affect expiry.
+	now := time.Now()
+
+	c := &baseConn{
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		envelope: envelopeCodecs["proto"],
+		now:      FrozenClock(now.Add(-time.Hour)),
+	}
+
+	opts := &SubscriptionOptions{Timeout: time.Second}
+	sub := c.newSubscription(stream, "expiry", opts)
+
+	var handled []string
+
+	h := c.msgHandler(sub, func(ctx context.Context, evt *Event) error {
+		handled = append(handled, evt.Type)
+		return nil
+	}, opts)
+
+	acks := 0
+
+	for _, evt := range []*Event{
+		{Type: "expired", Expiry: now.Add(-time.Second)},
+		{Type: "current", Expiry: now.Add(time.Minute)},
+		{Type: "none"},
+	} {
+		_, b, err := c.encode(evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h(&rawMsg{
+			subject: stream,
+			data:    b,
+			ack: func() error {
+				acks++
+				return nil
+			},
+		})
+	}
+
+	if len(handled) != 2 || handled[0] != "current" || handled[1] != "none" {
+		t.Errorf("unexpected handled events: %v", handled)
+	}
+
+	if acks != 3 {
+		t.Errorf("expected 3 acks, got %d", acks)
+	}
+
+	if n := sub.Expired(); n != 1 {
+		t.Errorf("expected 1 expired event, got %d", n)
+	}
+}
@@ -0,0 +1,139 @@
+package eda
+
+import (
+	"context"
+	"sync"
+)
+
+// fanInSubscription contains the subscriptions on each stream of a fan-in.
+type fanInSubscription struct {
+	subs []Subscription
+}
+
+func (s *fanInSubscription) close(unsubscribe bool) error {
+	var err error
+	for _, sub := range s.subs {
+		var e error
+		if unsubscribe {
+			e = sub.Unsubscribe()
+		} else {
+			e = sub.Close()
+		}
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+func (s *fanInSubscription) Close() error {
+	return s.close(false)
+}
+
+func (s *fanInSubscription) Unsubscribe() error {
+	return s.close(true)
+}
+
+// Pause pauses the subscription on each of the streams.
+func (s *fanInSubscription) Pause() error {
+	var err error
+	for _, sub := range s.subs {
+		if e := sub.Pause(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// Resume resumes the subscription on each of the streams.
+func (s *fanInSubscription) Resume() error {
+	var err error
+	for _, sub := range s.subs {
+		if e := sub.Resume(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// IsPaused returns true if the subscriptions on all streams are paused.
+func (s *fanInSubscription) IsPaused() bool {
+	for _, sub := range s.subs {
+		if !sub.IsPaused() {
+			return false
+		}
+	}
+
+	return len(s.subs) > 0
+}
+
+// Expired returns the number of expired events across streams.
+func (s *fanInSubscription) Expired() uint64 {
+	var n uint64
+	for _, sub := range s.subs {
+		n += sub.Expired()
+	}
+
+	return n
+}
+
+// GetLatest returns the most recent event for the key across streams.
+func (s *fanInSubscription) GetLatest(key string) (*Event, bool) {
+	var latest *Event
+
+	for _, sub := range s.subs {
+		if evt, ok := sub.GetLatest(key); ok {
+			if latest == nil || evt.Time.After(latest.Time) {
+				latest = evt
+			}
+		}
+	}
+
+	return latest, latest != nil
+}
+
+// NewMergingFanIn subscribes to all streams and calls the handler once for
+// each unique event by ID. Events already seen, such as copies on another
+// stream, are acknowledged without calling the handler. An event is only
+// marked as seen once the handler succeeds so failed events can be
+// redelivered. Handling is serialized across streams.
+func NewMergingFanIn(conn Conn, streams []string, handle Handler, dedup IDStore, opts *SubscriptionOptions) (Subscription, error) {
+	var mux sync.Mutex
+
+	merged := func(ctx context.Context, evt *Event) error {
+		mux.Lock()
+		defer mux.Unlock()
+
+		seen, err := dedup.Seen(evt.ID)
+		if err != nil {
+			return err
+		}
+
+		if seen {
+			return nil
+		}
+
+		if err := handle(ctx, evt); err != nil {
+			return err
+		}
+
+		return dedup.Mark(evt.ID, 0)
+	}
+
+	s := &fanInSubscription{}
+
+	for _, stream := range streams {
+		sub, err := conn.Subscribe(stream, merged, opts)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+
+		s.subs = append(s.subs, sub)
+	}
+
+	return s, nil
+}
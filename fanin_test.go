@@ -0,0 +1,57 @@
+package eda
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type memIDStore map[string]bool
+
+func (s memIDStore) Seen(id string) (bool, error) {
+	return s[id], nil
+}
+
+func (s memIDStore) Mark(id string, ttl time.Duration) error {
+	s[id] = true
+	return nil
+}
+
+// handlerConn records the handlers of subscriptions by stream.
+type handlerConn struct {
+	Conn
+
+	handlers map[string]Handler
+}
+
+func (c *handlerConn) Subscribe(stream string, handle Handler, opts *SubscriptionOptions) (Subscription, error) {
+	c.handlers[stream] = handle
+	return &fanInSubscription{}, nil
+}
+
+func TestMergingFanIn(t *testing.T) {
+	conn := &handlerConn{handlers: make(map[string]Handler)}
+
+	var handled []string
+
+	handle := func(ctx context.Context, evt *Event) error {
+		handled = append(handled, evt.ID)
+		return nil
+	}
+
+	_, err := NewMergingFanIn(conn, []string{"a", "b"}, handle, memIDStore{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	conn.handlers["a"](ctx, &Event{ID: "1"})
+	conn.handlers["b"](ctx, &Event{ID: "1"})
+	conn.handlers["b"](ctx, &Event{ID: "2"})
+	conn.handlers["a"](ctx, &Event{ID: "2"})
+
+	if len(handled) != 2 || handled[0] != "1" || handled[1] != "2" {
+		t.Errorf("expected events 1 and 2 once, got %v", handled)
+	}
+}
@@ -0,0 +1,113 @@
+package eda
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nuid"
+)
+
+// HealthCheckEventType is the type of the canary event published by a
+// HealthChecker.
+const HealthCheckEventType = "__health_check__"
+
+// healthCheckMetaKey is the meta key of the value identifying the check
+// the canary event was published for.
+const healthCheckMetaKey = "health_check_id"
+
+// HealthChecker verifies the publish/subscribe round-trip by publishing
+// a canary event to a dedicated stream and waiting to receive it. Canary
+// events are persisted, so the stream should not be used for other events.
+type HealthChecker struct {
+	conn    Conn
+	stream  string
+	timeout time.Duration
+}
+
+// Check publishes a canary event and waits until it is received, the
+// timeout passes, or the context is done.
+func (h *HealthChecker) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	id := nuid.Next()
+	received := make(chan struct{})
+
+	var once sync.Once
+
+	handle := func(_ context.Context, evt *Event) error {
+		if evt.Type == HealthCheckEventType && evt.Meta[healthCheckMetaKey] == id {
+			once.Do(func() { close(received) })
+		}
+		return nil
+	}
+
+	sub, err := h.conn.Subscribe(h.stream, handle, nil)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	_, err = h.conn.Publish(h.stream, &Event{
+		Type: HealthCheckEventType,
+		Meta: map[string]string{
+			healthCheckMetaKey: id,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-received:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ServeHTTP runs the check and responds with the status and latency.
+func (h *HealthChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t0 := time.Now()
+	err := h.Check(r.Context())
+	latency := time.Since(t0)
+
+	resp := map[string]interface{}{
+		"status":     "ok",
+		"latency_ms": float64(latency) / float64(time.Millisecond),
+	}
+
+	code := http.StatusOK
+	if err != nil {
+		code = http.StatusServiceUnavailable
+		resp["status"] = "error"
+		resp["error"] = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// RegisterHTTP mounts the check on the mux at the path.
+func (h *HealthChecker) RegisterHTTP(mux *http.ServeMux, path string) {
+	mux.Handle(path, h)
+}
+
+// NewHealthChecker returns a health checker that publishes canary events
+// to the stream. A check fails if the event is not received within the
+// timeout which defaults to five seconds.
+func NewHealthChecker(conn Conn, stream string, timeout time.Duration) *HealthChecker {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	return &HealthChecker{
+		conn:    conn,
+		stream:  stream,
+		timeout: timeout,
+	}
+}
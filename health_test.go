@@ -0,0 +1,48 @@
+package eda
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthChecker(t *testing.T) {
+	conn, err := Connect(addr, cluster, client)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	h := NewHealthChecker(conn, stream+"-health", time.Second)
+
+	if err := h.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	h.RegisterHTTP(mux, "/health")
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	var resp map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp["status"] != "ok" {
+		t.Errorf("expected ok status, got %v", resp["status"])
+	}
+
+	if _, ok := resp["latency_ms"]; !ok {
+		t.Error("expected latency in response")
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Package httpsource provides an HTTP handler that receives webhook requests
+and publishes them as events to a stream.
+*/
+package httpsource
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/chop-dbhi/eda"
+)
+
+const (
+	// DefaultSignatureHeader is the header containing the HMAC signature.
+	DefaultSignatureHeader = "X-Signature"
+
+	// TypeHeader is the header the default MapFn reads the event type from.
+	TypeHeader = "X-Event-Type"
+)
+
+// SourceOptions are options for the webhook handler.
+type SourceOptions struct {
+	// MapFn maps the request to an event. The default uses the X-Event-Type
+	// header as the type and the JSON body as the data.
+	MapFn func(r *http.Request) (*eda.Event, error)
+
+	// HMACSecret enables verification of the HMAC-SHA256 signature of the
+	// request body. The signature is expected to be hex-encoded with an
+	// optional "sha256=" prefix.
+	HMACSecret []byte
+
+	// SignatureHeader is the header containing the signature. This defaults
+	// to X-Signature.
+	SignatureHeader string
+}
+
+// MapJSON maps a request with a JSON body to an event using the
+// X-Event-Type header as the event type.
+func MapJSON(r *http.Request) (*eda.Event, error) {
+	typ := r.Header.Get(TypeHeader)
+	if typ == "" {
+		return nil, errors.New(TypeHeader + " header required")
+	}
+
+	var data json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	return &eda.Event{
+		Type: typ,
+		Data: eda.JSON(data),
+	}, nil
+}
+
+type handler struct {
+	conn   eda.Conn
+	stream string
+	opts   SourceOptions
+}
+
+func (h *handler) verify(r *http.Request, body []byte) bool {
+	sig := strings.TrimPrefix(r.Header.Get(h.opts.SignatureHeader), "sha256=")
+
+	expected, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, h.opts.HMACSecret)
+	mac.Write(body)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if len(h.opts.HMACSecret) > 0 {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !h.verify(r, body) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		// Restore the body for mapping.
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	evt, err := h.opts.MapFn(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := h.conn.Publish(h.stream, evt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"id": id,
+	})
+}
+
+// NewHandler returns an HTTP handler that publishes POST requests to the
+// stream. A request that cannot be mapped to an event results in a 400
+// and a failed publish in a 500. Published events result in a 202 with
+// the event ID.
+func NewHandler(conn eda.Conn, stream string, opts SourceOptions) http.Handler {
+	if opts.MapFn == nil {
+		opts.MapFn = MapJSON
+	}
+
+	if opts.SignatureHeader == "" {
+		opts.SignatureHeader = DefaultSignatureHeader
+	}
+
+	return &handler{
+		conn:   conn,
+		stream: stream,
+		opts:   opts,
+	}
+}
@@ -0,0 +1,73 @@
+package httpsource
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chop-dbhi/eda"
+)
+
+type testConn struct {
+	eda.Conn
+	err  error
+	evts []*eda.Event
+}
+
+func (c *testConn) Publish(stream string, evt *eda.Event) (string, error) {
+	if c.err != nil {
+		return "", c.err
+	}
+
+	c.evts = append(c.evts, evt)
+	return "1", nil
+}
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestHandler(t *testing.T) {
+	body := `{"id": "3292329"}`
+
+	tests := []struct {
+		name   string
+		method string
+		typ    string
+		sig    string
+		err    error
+		code   int
+	}{
+		{"accepted", "POST", "subject-enrolled", sign("secret", body), nil, http.StatusAccepted},
+		{"method", "GET", "subject-enrolled", sign("secret", body), nil, http.StatusMethodNotAllowed},
+		{"signature", "POST", "subject-enrolled", sign("wrong", body), nil, http.StatusUnauthorized},
+		{"mapping", "POST", "", sign("secret", body), nil, http.StatusBadRequest},
+		{"publish", "POST", "subject-enrolled", sign("secret", body), errors.New("failed"), http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		conn := &testConn{err: test.err}
+
+		h := NewHandler(conn, "subjects", SourceOptions{
+			HMACSecret: []byte("secret"),
+		})
+
+		r := httptest.NewRequest(test.method, "/", strings.NewReader(body))
+		r.Header.Set(TypeHeader, test.typ)
+		r.Header.Set(DefaultSignatureHeader, test.sig)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d, got %d", test.name, test.code, w.Code)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+/*
+Package idempotent provides middleware that skips events that have
+already been handled, such as those redelivered after a handler succeeded
+but the ack was lost.
+
+	store := idempotent.NewMemSeenStore(time.Hour)
+	handle = idempotent.IdempotentMiddleware(store)(handle)
+
+Use a RedisSeenStore to share seen IDs across processes.
+*/
+package idempotent
+
+import (
+	"context"
+
+	"github.com/chop-dbhi/eda"
+)
+
+// SeenStore keeps track of the IDs of events that have been handled.
+type SeenStore interface {
+	// HasSeen returns true if the ID has been marked as seen.
+	HasSeen(ctx context.Context, id string) (bool, error)
+
+	// MarkSeen marks the ID as seen.
+	MarkSeen(ctx context.Context, id string) error
+}
+
+// IdempotentMiddleware calls the handler only for events whose ID has not
+// been seen and marks the ID once the handler returns without error.
+// Events that have been seen are skipped without error so they are
+// acknowledged. Errors from the store are returned so the event is
+// redelivered.
+func IdempotentMiddleware(store SeenStore) eda.MiddlewareFunc {
+	return func(next eda.Handler) eda.Handler {
+		return func(ctx context.Context, evt *eda.Event) error {
+			seen, err := store.HasSeen(ctx, evt.ID)
+			if err != nil {
+				return err
+			}
+
+			if seen {
+				return nil
+			}
+
+			if err := next(ctx, evt); err != nil {
+				return err
+			}
+
+			return store.MarkSeen(ctx, evt.ID)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package idempotent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/chop-dbhi/eda"
+)
+
+func TestIdempotentMiddleware(t *testing.T) {
+	var calls int
+	fail := true
+
+	handle := IdempotentMiddleware(NewMemSeenStore(0))(func(ctx context.Context, evt *eda.Event) error {
+		calls++
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	evt := &eda.Event{ID: "1"}
+
+	// Failed events are not marked.
+	if err := handle(ctx, evt); err == nil {
+		t.Fatal("expected error")
+	}
+
+	fail = false
+
+	for i := 0; i < 2; i++ {
+		if err := handle(ctx, evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+
+	if err := handle(ctx, &eda.Event{ID: "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
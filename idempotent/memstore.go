@@ -0,0 +1,62 @@
+package idempotent
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemSeenStore is a SeenStore that keeps IDs in memory for the TTL.
+type MemSeenStore struct {
+	mux  sync.Mutex
+	ttl  time.Duration
+	seen map[string]time.Time
+	now  func() time.Time
+}
+
+func (s *MemSeenStore) HasSeen(ctx context.Context, id string) (bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	exp, ok := s.seen[id]
+	if !ok {
+		return false, nil
+	}
+
+	if s.ttl > 0 && !s.now().Before(exp) {
+		delete(s.seen, id)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// MarkSeen marks the ID and removes expired IDs.
+func (s *MemSeenStore) MarkSeen(ctx context.Context, id string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	now := s.now()
+
+	if s.ttl > 0 {
+		for k, exp := range s.seen {
+			if !now.Before(exp) {
+				delete(s.seen, k)
+			}
+		}
+	}
+
+	s.seen[id] = now.Add(s.ttl)
+
+	return nil
+}
+
+// NewMemSeenStore returns a MemSeenStore that forgets IDs after the TTL. A
+// zero TTL keeps IDs for the lifetime of the store.
+func NewMemSeenStore(ttl time.Duration) *MemSeenStore {
+	return &MemSeenStore{
+		ttl:  ttl,
+		seen: make(map[string]time.Time),
+		now:  time.Now,
+	}
+}
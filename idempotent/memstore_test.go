@@ -0,0 +1,40 @@
+package idempotent
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemSeenStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	s := NewMemSeenStore(time.Minute)
+	s.now = func() time.Time { return now }
+
+	if seen, _ := s.HasSeen(ctx, "1"); seen {
+		t.Error("expected 1 to not be seen")
+	}
+
+	s.MarkSeen(ctx, "1")
+
+	if seen, _ := s.HasSeen(ctx, "1"); !seen {
+		t.Error("expected 1 to be seen")
+	}
+
+	now = now.Add(time.Minute)
+
+	if seen, _ := s.HasSeen(ctx, "1"); seen {
+		t.Error("expected 1 to expire")
+	}
+
+	// Expired IDs are removed when marking.
+	s.MarkSeen(ctx, "2")
+	now = now.Add(time.Minute)
+	s.MarkSeen(ctx, "3")
+
+	if len(s.seen) != 1 {
+		t.Errorf("expected 1 ID, got %d", len(s.seen))
+	}
+}
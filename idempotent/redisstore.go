@@ -0,0 +1,41 @@
+package idempotent
+
+import (
+	"context"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// RedisSeenStore is a SeenStore that keeps IDs as Redis keys, so consumers
+// in different processes share the IDs they have seen.
+type RedisSeenStore struct {
+	client *goredis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// HasSeen returns true if the key of the ID exists.
+func (s *RedisSeenStore) HasSeen(ctx context.Context, id string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.prefix+id).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// MarkSeen sets the key of the ID, which expires after the TTL.
+func (s *RedisSeenStore) MarkSeen(ctx context.Context, id string) error {
+	return s.client.Set(ctx, s.prefix+id, 1, s.ttl).Err()
+}
+
+// NewRedisSeenStore returns a RedisSeenStore whose keys are prefixed with
+// keyPrefix and expire after the TTL. A zero TTL keeps keys indefinitely.
+func NewRedisSeenStore(client *goredis.Client, keyPrefix string, ttl time.Duration) *RedisSeenStore {
+	return &RedisSeenStore{
+		client: client,
+		prefix: keyPrefix,
+		ttl:    ttl,
+	}
+}
@@ -0,0 +1,51 @@
+package idempotent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func TestRedisSeenStore(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+
+	client := goredis.NewClient(&goredis.Options{Addr: srv.Addr()})
+	defer client.Close()
+
+	s := NewRedisSeenStore(client, "seen:", time.Minute)
+
+	if seen, err := s.HasSeen(ctx, "1"); err != nil || seen {
+		t.Fatalf("expected 1 to not be seen: %v", err)
+	}
+
+	if err := s.MarkSeen(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if seen, _ := s.HasSeen(ctx, "1"); !seen {
+		t.Error("expected 1 to be seen")
+	}
+
+	if !srv.Exists("seen:1") {
+		t.Error("expected key to be prefixed")
+	}
+
+	srv.FastForward(time.Minute)
+
+	if seen, _ := s.HasSeen(ctx, "1"); seen {
+		t.Error("expected 1 to expire")
+	}
+
+	// Keys are kept without a TTL.
+	s = NewRedisSeenStore(client, "seen:", 0)
+	s.MarkSeen(ctx, "2")
+	srv.FastForward(time.Hour)
+
+	if seen, _ := s.HasSeen(ctx, "2"); !seen {
+		t.Error("expected 2 to be seen")
+	}
+}
@@ -0,0 +1,13 @@
+package eda
+
+import "time"
+
+// IDStore keeps track of event IDs that have been seen, for example to
+// deduplicate events that are redelivered.
+type IDStore interface {
+	// Seen returns true if the ID has been marked.
+	Seen(id string) (bool, error)
+
+	// Mark marks the ID as seen. The mark expires after the TTL.
+	Mark(id string, ttl time.Duration) error
+}
@@ -10,6 +10,7 @@ It is generated from these files:
 
 It has these top-level messages:
 	Event
+	HeaderValues
 */
 package pb
 
@@ -56,6 +57,16 @@ type Event struct {
 	Meta map[string]string `protobuf:"bytes,10,rep,name=meta" json:"meta,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	// Key of the "aggregate" that this event is about.
 	Aggregate string `protobuf:"bytes,12,opt,name=aggregate" json:"aggregate,omitempty"`
+	// Logical clock time of the producer when the event was published.
+	LamportTime uint64 `protobuf:"varint,13,opt,name=lamport_time,json=lamportTime" json:"lamport_time,omitempty"`
+	// Stream the event was published on. This is only set when the event
+	// is stored outside of the stream, such as in a retry queue.
+	Stream string `protobuf:"bytes,14,opt,name=stream" json:"stream,omitempty"`
+	// Transport metadata such as trace IDs and content types, kept apart
+	// from the domain metadata in meta.
+	Headers map[string]*HeaderValues `protobuf:"bytes,15,rep,name=headers" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Time in Unix nanoseconds after which the event is no longer handled.
+	Expiry int64 `protobuf:"varint,16,opt,name=expiry" json:"expiry,omitempty"`
 }
 
 func (m *Event) Reset()                    { *m = Event{} }
@@ -140,29 +151,81 @@ func (m *Event) GetAggregate() string {
 	return ""
 }
 
+func (m *Event) GetLamportTime() uint64 {
+	if m != nil {
+		return m.LamportTime
+	}
+	return 0
+}
+
+func (m *Event) GetStream() string {
+	if m != nil {
+		return m.Stream
+	}
+	return ""
+}
+
+func (m *Event) GetHeaders() map[string]*HeaderValues {
+	if m != nil {
+		return m.Headers
+	}
+	return nil
+}
+
+func (m *Event) GetExpiry() int64 {
+	if m != nil {
+		return m.Expiry
+	}
+	return 0
+}
+
+// Values of a header. Proto maps cannot have repeated values.
+type HeaderValues struct {
+	Values []string `protobuf:"bytes,1,rep,name=values" json:"values,omitempty"`
+}
+
+func (m *HeaderValues) Reset()                    { *m = HeaderValues{} }
+func (m *HeaderValues) String() string            { return proto.CompactTextString(m) }
+func (*HeaderValues) ProtoMessage()               {}
+func (*HeaderValues) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *HeaderValues) GetValues() []string {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Event)(nil), "pb.Event")
+	proto.RegisterType((*HeaderValues)(nil), "pb.HeaderValues")
 }
 
 func init() { proto.RegisterFile("event.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 263 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x44, 0x90, 0x3d, 0x4f, 0xf3, 0x40,
-	0x0c, 0xc7, 0x95, 0xcb, 0x4b, 0x13, 0xa7, 0x7a, 0xf4, 0xc8, 0x20, 0x74, 0x54, 0x0c, 0x11, 0x0b,
-	0x99, 0x32, 0xc0, 0x00, 0x62, 0xef, 0xc8, 0x12, 0xb1, 0x23, 0xe7, 0x62, 0x85, 0x28, 0xcd, 0x8b,
-	0xd2, 0x6b, 0xa5, 0x7c, 0x4b, 0x3e, 0x12, 0x3a, 0xa7, 0x2a, 0xdb, 0xff, 0xf7, 0xb3, 0xcf, 0x27,
-	0x1b, 0x52, 0x3e, 0xf3, 0x60, 0x8b, 0x69, 0x1e, 0xed, 0x88, 0x6a, 0xaa, 0x1e, 0x7f, 0x14, 0x84,
-	0x7b, 0xe7, 0xf0, 0x1f, 0xa8, 0xb6, 0xd6, 0x5e, 0xe6, 0xe5, 0x49, 0xa9, 0xda, 0x1a, 0x11, 0x02,
-	0xdb, 0xf6, 0xac, 0x55, 0xe6, 0xe5, 0x7e, 0x29, 0x19, 0xef, 0x21, 0x26, 0xd3, 0x7d, 0x89, 0x4f,
-	0xc5, 0x6f, 0xc8, 0x74, 0x9f, 0xae, 0xe4, 0xda, 0x97, 0x89, 0xb5, 0x2f, 0x03, 0x24, 0xe3, 0x2d,
-	0x84, 0x86, 0x4e, 0x47, 0xd6, 0xa1, 0xc8, 0x15, 0xf0, 0x0e, 0x22, 0x73, 0x68, 0x79, 0xb0, 0x3a,
-	0x12, 0x7d, 0x21, 0xe7, 0x8f, 0xe6, 0x9b, 0x7b, 0xd2, 0xc1, 0xea, 0x57, 0xc2, 0x1d, 0xc4, 0x3c,
-	0x98, 0xb1, 0x6e, 0x87, 0x46, 0xc7, 0x52, 0xb9, 0xb2, 0xfb, 0xb5, 0x26, 0x4b, 0x7a, 0x93, 0x79,
-	0xf9, 0xb6, 0x94, 0x8c, 0x4f, 0x10, 0xf4, 0x6c, 0x49, 0x43, 0xe6, 0xe7, 0xe9, 0xf3, 0x4d, 0x31,
-	0x55, 0x85, 0x6c, 0x58, 0x7c, 0xb0, 0xa5, 0xfd, 0x60, 0xe7, 0xa5, 0x94, 0x06, 0x7c, 0x80, 0x84,
-	0x9a, 0x66, 0xe6, 0x86, 0x2c, 0xeb, 0xad, 0x4c, 0xfe, 0x13, 0xbb, 0x57, 0x48, 0xae, 0x0f, 0xf0,
-	0x3f, 0xf8, 0x1d, 0x2f, 0x97, 0xeb, 0xb8, 0xe8, 0x76, 0x3b, 0xd3, 0xe1, 0xb4, 0xde, 0x27, 0x29,
-	0x57, 0x78, 0x57, 0x6f, 0x5e, 0x15, 0xc9, 0x75, 0x5f, 0x7e, 0x03, 0x00, 0x00, 0xff, 0xff, 0xdb,
-	0x37, 0x6a, 0xeb, 0x6c, 0x01, 0x00, 0x00,
+	// 363 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x92, 0x3b, 0x4f, 0xc3, 0x30,
+	0x10, 0xc7, 0x95, 0x47, 0x1f, 0xb9, 0x84, 0x52, 0x19, 0x54, 0x99, 0x8a, 0x21, 0x74, 0x28, 0x99,
+	0x22, 0x54, 0x06, 0x10, 0x7b, 0x25, 0x06, 0x58, 0x22, 0xc4, 0x8a, 0xdc, 0xe4, 0x94, 0x46, 0x6d,
+	0x1e, 0x4a, 0xdc, 0x8a, 0x7c, 0x1e, 0xbe, 0x28, 0xf2, 0x39, 0xb4, 0x19, 0xd8, 0xee, 0xff, 0xbb,
+	0x87, 0xff, 0x67, 0x1b, 0x5c, 0x3c, 0x62, 0x21, 0xc3, 0xaa, 0x2e, 0x65, 0xc9, 0xcc, 0x6a, 0xb3,
+	0xf8, 0xb1, 0x61, 0xb0, 0x56, 0x8c, 0x4d, 0xc0, 0xcc, 0x12, 0x6e, 0xf8, 0x46, 0xe0, 0x44, 0x66,
+	0x96, 0x30, 0x06, 0xb6, 0xcc, 0x72, 0xe4, 0xa6, 0x6f, 0x04, 0x56, 0x44, 0x31, 0xbb, 0x81, 0xb1,
+	0x88, 0x77, 0x5f, 0xc4, 0x5d, 0xe2, 0x23, 0x11, 0xef, 0x3e, 0x54, 0x4a, 0x95, 0xb7, 0x15, 0x72,
+	0x8b, 0x06, 0x50, 0xcc, 0xae, 0x61, 0x10, 0x8b, 0x43, 0x83, 0x7c, 0x40, 0x50, 0x0b, 0x36, 0x83,
+	0x61, 0xbc, 0xcf, 0xb0, 0x90, 0x7c, 0x48, 0xb8, 0x53, 0x8a, 0x37, 0xf1, 0x16, 0x73, 0xc1, 0x6d,
+	0xcd, 0xb5, 0x62, 0x73, 0x18, 0x63, 0x11, 0x97, 0x49, 0x56, 0xa4, 0x7c, 0x4c, 0x99, 0x93, 0x56,
+	0xa7, 0x26, 0x42, 0x0a, 0x3e, 0xf2, 0x8d, 0xc0, 0x8b, 0x28, 0x66, 0xf7, 0x60, 0xe7, 0x28, 0x05,
+	0x07, 0xdf, 0x0a, 0xdc, 0xd5, 0x55, 0x58, 0x6d, 0x42, 0xda, 0x30, 0x7c, 0x47, 0x29, 0xd6, 0x85,
+	0xac, 0xdb, 0x88, 0x0a, 0xd8, 0x2d, 0x38, 0x22, 0x4d, 0x6b, 0x4c, 0x85, 0x44, 0xee, 0xd1, 0xe4,
+	0x33, 0x60, 0x77, 0xe0, 0xed, 0x45, 0x5e, 0x95, 0xb5, 0xd4, 0xfb, 0x5e, 0xf8, 0x46, 0x60, 0x47,
+	0x6e, 0xc7, 0x68, 0x67, 0xe5, 0x58, 0xd6, 0x28, 0x72, 0x3e, 0xe9, 0x1c, 0x93, 0x62, 0x0f, 0x30,
+	0xda, 0xa2, 0x48, 0xb0, 0x6e, 0xf8, 0x25, 0x99, 0x98, 0x9d, 0x4d, 0xbc, 0xea, 0x84, 0xf6, 0xf1,
+	0x57, 0xa6, 0x26, 0xe1, 0x77, 0x95, 0xd5, 0x2d, 0x9f, 0xd2, 0xb5, 0x76, 0x6a, 0xfe, 0x04, 0xce,
+	0xc9, 0x35, 0x9b, 0x82, 0xb5, 0xc3, 0xb6, 0x7b, 0x22, 0x15, 0xaa, 0x0b, 0x3e, 0x8a, 0xfd, 0x41,
+	0x3f, 0x92, 0x13, 0x69, 0xf1, 0x62, 0x3e, 0x1b, 0xf3, 0x37, 0xf0, 0xfa, 0x27, 0xfd, 0xd3, 0xbb,
+	0xec, 0xf7, 0xba, 0xab, 0xa9, 0xb2, 0xa8, 0x5b, 0x3e, 0x15, 0x6e, 0x7a, 0xd3, 0x16, 0x4b, 0xf0,
+	0xfa, 0x29, 0x65, 0x97, 0x92, 0x0d, 0x37, 0x7c, 0x4b, 0x2d, 0xae, 0xd5, 0x66, 0x48, 0x1f, 0xeb,
+	0xf1, 0x77, 0x00, 0x36, 0xb9, 0xab, 0x23, 0x67, 0x02, 0x00, 0x00,
 }
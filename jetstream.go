@@ -0,0 +1,521 @@
+package eda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// ephemeralSubjectPrefix is prepended to the subject of ephemeral events
+// so they are not captured by the JetStream stream of the same name.
+const ephemeralSubjectPrefix = "_EDA.ephemeral."
+
+// sharedConsumerSuffix is appended to the consumer name of subscriptions
+// that are not durable, so they do not share a consumer with durable ones.
+// Escaped names cannot end with it.
+const sharedConsumerSuffix = "_shared"
+
+// jetStreamName returns a valid JetStream stream or consumer name for the
+// stream. Characters that are not allowed in names, and underscores, are
+// escaped as an underscore followed by their hex code so distinct streams
+// have distinct names. The escaped name is also used as the subject so it
+// is a single token without wildcards.
+func jetStreamName(stream string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(stream); i++ {
+		switch ch := stream[i]; {
+		case ch <= ' ', ch == 0x7f, ch == '_', ch == '.', ch == '*', ch == '>', ch == '/', ch == '\\':
+			fmt.Fprintf(&b, "_%02X", ch)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+
+	return b.String()
+}
+
+// jetStreamSubscription closes a JetStream consumer or the core NATS
+// subscription of ephemeral events.
+type jetStreamSubscription struct {
+	stop func()
+
+	// Deletes the durable consumer. Nil for non-durable consumers which
+	// are removed by the server once inactive.
+	remove func() error
+}
+
+func (s *jetStreamSubscription) Close() error {
+	s.stop()
+	return nil
+}
+
+func (s *jetStreamSubscription) Unsubscribe() error {
+	s.stop()
+
+	if s.remove != nil {
+		return s.remove()
+	}
+
+	return nil
+}
+
+// jetStreamConn is an implementation of Conn using NATS JetStream.
+type jetStreamConn struct {
+	baseConn
+
+	nats *nats.Conn
+	js   jetstream.JetStream
+
+	// JetStream streams that are known to exist.
+	streamsMux sync.Mutex
+	streams    map[string]jetstream.Stream
+}
+
+// stream returns the JetStream stream for the stream, creating it if it
+// does not exist.
+func (c *jetStreamConn) stream(ctx context.Context, stream string) (jetstream.Stream, error) {
+	c.streamsMux.Lock()
+	defer c.streamsMux.Unlock()
+
+	if s, ok := c.streams[stream]; ok {
+		return s, nil
+	}
+
+	name := jetStreamName(stream)
+
+	s, err := c.js.Stream(ctx, name)
+	if errors.Is(err, jetstream.ErrStreamNotFound) {
+		s, err = c.js.CreateStream(ctx, jetstream.StreamConfig{
+			Name:     name,
+			Subjects: []string{name},
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.streams[stream] = s
+
+	return s, nil
+}
+
+// Close the underlying connection to the stream backend.
+func (c *jetStreamConn) Close() error {
+	c.nats.Close()
+	return nil
+}
+
+func (c *jetStreamConn) Publish(stream string, evt *Event) (string, error) {
+	if evt == nil {
+		evt = &Event{}
+	}
+
+	id, b, err := c.encode(evt)
+	if err != nil {
+		return "", err
+	}
+
+	// Ephemeral events are published on core NATS outside of the stream.
+	if evt.Ephemeral {
+		if err := c.nats.Publish(ephemeralSubjectPrefix+jetStreamName(stream), b); err != nil {
+			return id, err
+		}
+
+		return id, nil
+	}
+
+	ctx := context.Background()
+
+	if _, err := c.stream(ctx, stream); err != nil {
+		return "", err
+	}
+
+	// The event ID is used for server-side deduplication.
+	if _, err := c.js.Publish(ctx, jetStreamName(stream), b, jetstream.WithMsgID(id)); err != nil {
+		return id, err
+	}
+
+	return id, nil
+}
+
+// PublishAfter publishes the events one at a time, each with the expected
+// last subject sequence set to the sequence of the event before it, so the
+// server rejects it if another event was published in between.
+func (c *jetStreamConn) PublishAfter(stream string, evts []*Event, seq uint64) ([]string, error) {
+	ctx := context.Background()
+
+	if _, err := c.stream(ctx, stream); err != nil {
+		return nil, err
+	}
+
+	return PublishEach(func(stream string, evt *Event) (string, error) {
+		id, b, err := c.encode(evt)
+		if err != nil {
+			return "", err
+		}
+
+		ack, err := c.js.Publish(ctx, jetStreamName(stream), b,
+			jetstream.WithMsgID(id),
+			jetstream.WithExpectLastSequencePerSubject(seq),
+		)
+
+		var aerr *jetstream.APIError
+		if errors.As(err, &aerr) && aerr.ErrorCode == jetstream.JSErrCodeStreamWrongLastSequence {
+			return id, ErrSeqConflict
+		}
+		if err != nil {
+			return id, err
+		}
+
+		seq = ack.Sequence
+
+		return id, nil
+	}, stream, evts)
+}
+
+// PublishBatch publishes the events one at a time, stopping at the first
+// failure.
+func (c *jetStreamConn) PublishBatch(stream string, evts []*Event) ([]string, error) {
+	return PublishEach(c.Publish, stream, evts)
+}
+
+// Request publishes the event and waits for the reply on a NATS inbox.
+func (c *jetStreamConn) Request(ctx context.Context, stream string, evt *Event) (*Reply, error) {
+	inbox := nats.NewInbox()
+	replies := make(chan []byte, 1)
+
+	sub, err := c.nats.Subscribe(inbox, func(m *nats.Msg) {
+		select {
+		case replies <- m.Data:
+		default:
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+
+	return c.request(ctx, stream, evt, inbox, replies)
+}
+
+func (c *jetStreamConn) Subscribe(stream string, handle Handler, opts *SubscriptionOptions) (Subscription, error) {
+	if opts == nil {
+		opts = &SubscriptionOptions{}
+	} else {
+		opts = &(*opts)
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+
+	consumerName := opts.Name
+	if consumerName == "" {
+		consumerName = c.client
+	}
+
+	sub := c.newSubscription(stream, consumerName, opts)
+	msgHandler := c.msgHandler(sub, handle, opts)
+
+	if opts.Ephemeral {
+		nsub, err := c.nats.Subscribe(ephemeralSubjectPrefix+jetStreamName(stream), func(m *nats.Msg) {
+			msgHandler(&rawMsg{
+				subject:   stream,
+				data:      m.Data,
+				timestamp: time.Now().UnixNano(),
+				ephemeral: true,
+				ack:       func() error { return nil },
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		sub.sub = &jetStreamSubscription{
+			stop: func() { nsub.Unsubscribe() },
+		}
+
+		return sub, nil
+	}
+
+	ctx := context.Background()
+
+	s, err := c.stream(ctx, stream)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := jetstream.ConsumerConfig{
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       opts.Timeout,
+		DeliverPolicy: jetstream.DeliverNewPolicy,
+	}
+
+	switch {
+	case opts.StartSeq > 0:
+		cfg.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		cfg.OptStartSeq = opts.StartSeq
+	case opts.StartTime != nil:
+		cfg.DeliverPolicy = jetstream.DeliverByStartTimePolicy
+		cfg.OptStartTime = opts.StartTime
+	case opts.Backfill:
+		cfg.DeliverPolicy = jetstream.DeliverAllPolicy
+	}
+
+	// Force messages to be processed in order, one at a time.
+	if opts.Serial {
+		cfg.MaxAckPending = 1
+	}
+
+	durableName := jetStreamName(consumerName)
+
+	// Subscriptions with the same name share a consumer so events are
+	// split between them like a queue group. Shared consumers that are
+	// not durable are removed by the server once inactive.
+	if opts.Durable {
+		cfg.Durable = durableName
+
+		if opts.Reset {
+			err := s.DeleteConsumer(ctx, durableName)
+			if err != nil && !errors.Is(err, jetstream.ErrConsumerNotFound) {
+				return nil, err
+			}
+		}
+	} else {
+		cfg.Name = durableName + sharedConsumerSuffix
+	}
+
+	cons, err := s.CreateOrUpdateConsumer(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	cc, err := cons.Consume(func(m jetstream.Msg) {
+		var (
+			ts  int64
+			seq uint64
+		)
+		if md, err := m.Metadata(); err == nil {
+			ts = md.Timestamp.UnixNano()
+			seq = md.Sequence.Stream
+		}
+
+		msgHandler(&rawMsg{
+			subject:   stream,
+			seq:       seq,
+			data:      m.Data(),
+			timestamp: ts,
+			ack:       m.Ack,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	jsub := &jetStreamSubscription{stop: cc.Stop}
+
+	if opts.Durable {
+		jsub.remove = func() error {
+			return s.DeleteConsumer(context.Background(), durableName)
+		}
+	}
+
+	sub.sub = jsub
+
+	return sub, nil
+}
+
+// readBatchSize is the number of messages fetched at a time by an
+// iterator.
+const readBatchSize = 64
+
+// Read reads the events in the stream with an ordered consumer which is
+// deleted when the iterator is closed.
+func (c *jetStreamConn) Read(stream string, opts *ReadOptions) (EventIterator, error) {
+	if opts == nil {
+		opts = &ReadOptions{}
+	}
+
+	ctx := context.Background()
+
+	// The stream is not created until the first event is published.
+	s, err := c.js.Stream(ctx, jetStreamName(stream))
+	if errors.Is(err, jetstream.ErrStreamNotFound) {
+		return emptyEventIterator(opts), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	info, err := s.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	last := lastReadSeq(opts, info.State.LastSeq)
+
+	if last == 0 || opts.From.Seq > last {
+		return emptyEventIterator(opts), nil
+	}
+
+	var cfg jetstream.OrderedConsumerConfig
+
+	switch {
+	case opts.From.Seq > 0:
+		cfg.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		cfg.OptStartSeq = opts.From.Seq
+	case !opts.From.Time.IsZero():
+		t := opts.From.Time
+		cfg.DeliverPolicy = jetstream.DeliverByStartTimePolicy
+		cfg.OptStartTime = &t
+	}
+
+	cons, err := s.OrderedConsumer(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		batch jetstream.MessageBatch
+		prev  uint64
+	)
+
+	next := func(ctx context.Context) (*Event, error) {
+		for {
+			if prev >= last {
+				return nil, io.EOF
+			}
+
+			if batch == nil {
+				batch, err = cons.Fetch(readBatchSize, jetstream.FetchMaxWait(time.Second))
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			select {
+			case m, ok := <-batch.Messages():
+				// Fetch the next batch.
+				if !ok {
+					err := batch.Error()
+					batch = nil
+					if err != nil && !errors.Is(err, nats.ErrTimeout) {
+						return nil, err
+					}
+					continue
+				}
+
+				md, err := m.Metadata()
+				if err != nil {
+					return nil, err
+				}
+
+				if md.Sequence.Stream > last {
+					prev = last
+					return nil, io.EOF
+				}
+
+				prev = md.Sequence.Stream
+
+				return c.decode(&rawMsg{
+					subject:   stream,
+					seq:       md.Sequence.Stream,
+					data:      m.Data(),
+					timestamp: md.Timestamp.UnixNano(),
+				})
+
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	remove := func() error {
+		err := s.DeleteConsumer(context.Background(), cons.CachedInfo().Name)
+		if errors.Is(err, jetstream.ErrConsumerNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	return NewEventIterator(next, remove, opts), nil
+}
+
+func (c *jetStreamConn) StreamStats(ctx context.Context, stream string) (*StreamStats, error) {
+	s, err := c.js.Stream(ctx, jetStreamName(stream))
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamStats{
+		FirstSeq:  info.State.FirstSeq,
+		LastSeq:   info.State.LastSeq,
+		MsgCount:  int64(info.State.Msgs),
+		ByteSize:  int64(info.State.Bytes),
+		FirstTime: info.State.FirstTime,
+		LastTime:  info.State.LastTime,
+	}, nil
+}
+
+func (c *jetStreamConn) MultiStreamStats(ctx context.Context, streams []string) (map[string]*StreamStats, error) {
+	m := make(map[string]*StreamStats, len(streams))
+
+	for _, stream := range streams {
+		stats, err := c.StreamStats(ctx, stream)
+		if err != nil {
+			return nil, err
+		}
+
+		m[stream] = stats
+	}
+
+	return m, nil
+}
+
+// connectJetStream establishes a connection to a NATS server with JetStream
+// enabled.
+func connectJetStream(addr string, base baseConn) (Conn, error) {
+	nc, err := nats.Connect(
+		addr,
+		nats.Name(base.client),
+		// Try reconnecting indefinitely.
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	conn := &jetStreamConn{
+		baseConn: base,
+		nats:     nc,
+		js:       js,
+		streams:  make(map[string]jetstream.Stream),
+	}
+
+	conn.publish = conn.Publish
+	conn.respond = nc.Publish
+
+	return conn, nil
+}
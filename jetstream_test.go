@@ -0,0 +1,280 @@
+package eda
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nuid"
+)
+
+var jsAddr string
+
+func init() {
+	flag.StringVar(&jsAddr, "jetstream-addr", "nats://localhost:4223", "NATS JetStream address.")
+}
+
+func TestJetStream(t *testing.T) {
+	conn, err := Connect(jsAddr, "", client, WithJetStream())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	received := make(chan *Event, 1)
+
+	handle := func(ctx context.Context, evt *Event) error {
+		received <- evt
+		return nil
+	}
+
+	sub, err := conn.Subscribe(stream, handle, &SubscriptionOptions{
+		Durable: true,
+		Serial:  true,
+		Reset:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	id, err := conn.Publish(stream, &Event{
+		Type: "foo",
+		Data: String("bar"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case evt := <-received:
+		if evt.ID != id || evt.Type != "foo" || evt.Stream != stream {
+			t.Errorf("unexpected event: %+v", evt)
+		}
+
+		var v string
+		if err := evt.Data.Decode(&v); err != nil || v != "bar" {
+			t.Errorf("unexpected data %q: %v", v, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not received")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stats, err := conn.StreamStats(ctx, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.MsgCount == 0 || stats.LastTime.Before(stats.FirstTime) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestJetStreamName(t *testing.T) {
+	names := map[string]string{
+		"orders":         "orders",
+		"orders.created": "orders_2Ecreated",
+		"orders_created": "orders_5Fcreated",
+		"orders created": "orders_20created",
+		"orders/*":       "orders_2F_2A",
+	}
+
+	for stream, exp := range names {
+		if name := jetStreamName(stream); name != exp {
+			t.Errorf("expected %q for %q, got %q", exp, stream, name)
+		}
+	}
+}
+
+func TestJetStreamSubject(t *testing.T) {
+	conn, err := Connect(jsAddr, "", client, WithJetStream())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	// The subjects would overlap if the stream names were not escaped.
+	base := "subject-" + nuid.Next()
+	streams := []string{base + ".*", base + ".a", base + " a"}
+
+	for _, stream := range streams {
+		if _, err := conn.Publish(stream, &Event{Type: stream}); err != nil {
+			t.Fatalf("%s: %s", stream, err)
+		}
+	}
+
+	for _, stream := range streams {
+		it, err := conn.Read(stream, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var types []string
+		for {
+			evt, err := it.Next(context.Background())
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			types = append(types, evt.Type)
+		}
+		it.Close()
+
+		if len(types) != 1 || types[0] != stream {
+			t.Errorf("%s: expected only its own event, got %v", stream, types)
+		}
+	}
+}
+
+func TestJetStreamShared(t *testing.T) {
+	conn, err := Connect(jsAddr, "", client, WithJetStream())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	received := make(chan string, 10)
+	opts := &SubscriptionOptions{Name: "shared-" + nuid.Next()}
+
+	// Subscriptions with the same name split the events.
+	for i := 0; i < 2; i++ {
+		sub, err := conn.Subscribe(stream, func(ctx context.Context, evt *Event) error {
+			received <- evt.ID
+			return nil
+		}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sub.Close()
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, err := conn.Publish(stream, &Event{Type: "shared"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := make(map[string]bool)
+
+	for i := 0; i < 4; i++ {
+		select {
+		case id := <-received:
+			if seen[id] {
+				t.Errorf("event %s received twice", id)
+			}
+			seen[id] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected 4 events, got %d", len(seen))
+		}
+	}
+
+	select {
+	case id := <-received:
+		t.Errorf("unexpected event %s", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestJetStreamEphemeral(t *testing.T) {
+	conn, err := Connect(jsAddr, "", client, WithJetStream())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	received := make(chan *Event, 1)
+
+	sub, err := conn.Subscribe(stream, func(ctx context.Context, evt *Event) error {
+		received <- evt
+		return nil
+	}, &SubscriptionOptions{
+		Ephemeral: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	if _, err := conn.Publish(stream, &Event{Type: "ping", Ephemeral: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case evt := <-received:
+		if !evt.Ephemeral || evt.Stream != stream {
+			t.Errorf("unexpected event: %+v", evt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not received")
+	}
+}
+
+func TestJetStreamRead(t *testing.T) {
+	conn, err := Connect(jsAddr, "", client, WithJetStream())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	testRead(t, conn)
+}
+
+func TestJetStreamPublishAfter(t *testing.T) {
+	c, err := Connect(jsAddr, "", client, WithJetStream())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer c.Close()
+
+	conn := c.(ConditionalPublisher)
+
+	// The stream must be empty.
+	stream := "publish-after-" + nuid.Next()
+
+	if _, err := conn.PublishAfter(stream, []*Event{{Type: "a"}, {Type: "b"}}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := c.StreamStats(context.Background(), stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.LastSeq != 2 {
+		t.Fatalf("expected last sequence 2, got %d", stats.LastSeq)
+	}
+
+	// The stream is no longer empty.
+	if _, err := conn.PublishAfter(stream, []*Event{{Type: "c"}}, 0); !errors.Is(err, ErrSeqConflict) {
+		t.Errorf("expected ErrSeqConflict, got %v", err)
+	}
+
+	if _, err := conn.PublishAfter(stream, []*Event{{Type: "c"}}, 2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJetStreamRequest(t *testing.T) {
+	conn, err := Connect(jsAddr, "", client, WithJetStream())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	testRequest(t, conn)
+}
@@ -0,0 +1,48 @@
+package eda
+
+import "sync"
+
+// LamportClock is a logical clock used to establish an ordering of events
+// across producers. The clock is incremented when an event is published
+// and advanced when an event with a higher time is received.
+type LamportClock struct {
+	mux  sync.Mutex
+	time uint64
+}
+
+// Time returns the current time of the clock.
+func (c *LamportClock) Time() uint64 {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.time
+}
+
+// Tick increments the clock and returns the new time.
+func (c *LamportClock) Tick() uint64 {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.time++
+	return c.time
+}
+
+// Witness advances the clock to the remote time if it is higher.
+func (c *LamportClock) Witness(t uint64) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if t > c.time {
+		c.time = t
+	}
+}
+
+// LamportLess reports whether event a is ordered before event b by
+// Lamport time, using the client ID to break ties.
+func LamportLess(a, b *Event) bool {
+	if a.LamportTime != b.LamportTime {
+		return a.LamportTime < b.LamportTime
+	}
+
+	return a.Client < b.Client
+}
@@ -0,0 +1,36 @@
+package eda
+
+import "testing"
+
+func TestLamportClock(t *testing.T) {
+	var c LamportClock
+
+	if n := c.Tick(); n != 1 {
+		t.Fatalf("expected 1, got %d", n)
+	}
+
+	// Lower remote times are ignored.
+	c.Witness(0)
+	if n := c.Time(); n != 1 {
+		t.Fatalf("expected 1, got %d", n)
+	}
+
+	c.Witness(5)
+	if n := c.Tick(); n != 6 {
+		t.Fatalf("expected 6, got %d", n)
+	}
+}
+
+func TestLamportLess(t *testing.T) {
+	a := &Event{LamportTime: 1, Client: "b"}
+	b := &Event{LamportTime: 2, Client: "a"}
+	c := &Event{LamportTime: 2, Client: "b"}
+
+	if !LamportLess(a, b) || LamportLess(b, a) {
+		t.Error("expected ordering by time")
+	}
+
+	if !LamportLess(b, c) || LamportLess(c, b) {
+		t.Error("expected ordering by client for equal times")
+	}
+}
@@ -0,0 +1,132 @@
+package eda
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// loggerHandler is a slog.Handler that writes records to a Logger as a
+// message followed by key=value attributes.
+type loggerHandler struct {
+	logger Logger
+	attrs  []slog.Attr
+	prefix string
+}
+
+func (h *loggerHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *loggerHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+
+	b.WriteString(r.Message)
+
+	// Attributes added via WithAttrs already carry the group prefix.
+	for _, a := range h.attrs {
+		fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+	}
+
+	r.Attrs(func(a slog.Attr) bool {
+		fmt.Fprintf(&b, " %s%s=%v", h.prefix, a.Key, a.Value)
+		return true
+	})
+
+	h.logger.Print(b.String())
+
+	return nil
+}
+
+func (h *loggerHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	x := *h
+	x.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	x.attrs = append(x.attrs, h.attrs...)
+
+	for _, a := range attrs {
+		a.Key = h.prefix + a.Key
+		x.attrs = append(x.attrs, a)
+	}
+
+	return &x
+}
+
+func (h *loggerHandler) WithGroup(name string) slog.Handler {
+	x := *h
+	x.prefix = h.prefix + name + "."
+	return &x
+}
+
+// Field is a key-value pair attached to a structured log entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// StructuredLogger is an interface for internal logging with fields. See
+// the log/slog and log/zap packages for adapters.
+type StructuredLogger interface {
+	Info(msg string, fields ...Field)
+	Error(msg string, err error, fields ...Field)
+}
+
+// structuredHandler is a slog.Handler that writes records to a
+// StructuredLogger. Records at the error level are written with Error
+// and the "error" attribute is passed as the error.
+type structuredHandler struct {
+	logger StructuredLogger
+	fields []Field
+	prefix string
+}
+
+func (h *structuredHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *structuredHandler) Handle(_ context.Context, r slog.Record) error {
+	fields := make([]Field, len(h.fields), len(h.fields)+r.NumAttrs())
+	copy(fields, h.fields)
+
+	var err error
+
+	r.Attrs(func(a slog.Attr) bool {
+		if r.Level >= slog.LevelError && a.Key == "error" && h.prefix == "" {
+			if e, ok := a.Value.Any().(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", a.Value)
+			}
+			return true
+		}
+
+		fields = append(fields, Field{Key: h.prefix + a.Key, Value: a.Value.Any()})
+		return true
+	})
+
+	if r.Level >= slog.LevelError {
+		h.logger.Error(r.Message, err, fields...)
+	} else {
+		h.logger.Info(r.Message, fields...)
+	}
+
+	return nil
+}
+
+func (h *structuredHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	x := *h
+	x.fields = make([]Field, 0, len(h.fields)+len(attrs))
+	x.fields = append(x.fields, h.fields...)
+
+	for _, a := range attrs {
+		x.fields = append(x.fields, Field{Key: h.prefix + a.Key, Value: a.Value.Any()})
+	}
+
+	return &x
+}
+
+func (h *structuredHandler) WithGroup(name string) slog.Handler {
+	x := *h
+	x.prefix = h.prefix + name + "."
+	return &x
+}
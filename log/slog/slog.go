@@ -0,0 +1,48 @@
+// Package slog adapts a log/slog logger to eda.StructuredLogger.
+//
+// eda.WithSlogLogger uses a slog logger directly. The adapter is useful
+// for components that accept an eda.StructuredLogger.
+package slog
+
+import (
+	"log/slog"
+
+	"github.com/chop-dbhi/eda"
+)
+
+// SlogAdapter writes log entries to a slog logger.
+type SlogAdapter struct {
+	logger *slog.Logger
+}
+
+func attrs(fields []eda.Field) []any {
+	args := make([]any, len(fields))
+	for i, f := range fields {
+		args[i] = slog.Any(f.Key, f.Value)
+	}
+	return args
+}
+
+// Info logs the message at the info level.
+func (a *SlogAdapter) Info(msg string, fields ...eda.Field) {
+	a.logger.Info(msg, attrs(fields)...)
+}
+
+// Error logs the message at the error level with the error as the
+// "error" attribute.
+func (a *SlogAdapter) Error(msg string, err error, fields ...eda.Field) {
+	args := attrs(fields)
+	if err != nil {
+		args = append(args, slog.Any("error", err))
+	}
+	a.logger.Error(msg, args...)
+}
+
+// NewSlogAdapter returns an adapter for the logger. If the logger is nil,
+// slog.Default is used.
+func NewSlogAdapter(l *slog.Logger) *SlogAdapter {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &SlogAdapter{logger: l}
+}
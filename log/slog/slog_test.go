@@ -0,0 +1,36 @@
+package slog
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/chop-dbhi/eda"
+)
+
+func TestSlogAdapter(t *testing.T) {
+	var buf bytes.Buffer
+
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	var _ eda.StructuredLogger = NewSlogAdapter(l)
+
+	a := NewSlogAdapter(l)
+	a.Info("published", eda.Field{Key: "stream", Value: "orders"})
+	a.Error("handler error", errors.New("failed"), eda.Field{Key: "event_id", Value: "1"})
+
+	exp := "level=INFO msg=published stream=orders\n" +
+		"level=ERROR msg=\"handler error\" event_id=1 error=failed\n"
+
+	if buf.String() != exp {
+		t.Fatalf("expected %q, got %q", exp, buf.String())
+	}
+}
@@ -0,0 +1,40 @@
+// Package zap adapts a zap logger to eda.StructuredLogger.
+package zap
+
+import (
+	"github.com/chop-dbhi/eda"
+	"go.uber.org/zap"
+)
+
+// ZapAdapter writes log entries to a zap logger.
+type ZapAdapter struct {
+	logger *zap.Logger
+}
+
+func zapFields(fields []eda.Field, extra int) []zap.Field {
+	zf := make([]zap.Field, len(fields), len(fields)+extra)
+	for i, f := range fields {
+		zf[i] = zap.Any(f.Key, f.Value)
+	}
+	return zf
+}
+
+// Info logs the message at the info level.
+func (a *ZapAdapter) Info(msg string, fields ...eda.Field) {
+	a.logger.Info(msg, zapFields(fields, 0)...)
+}
+
+// Error logs the message at the error level with the error as the
+// "error" field.
+func (a *ZapAdapter) Error(msg string, err error, fields ...eda.Field) {
+	zf := zapFields(fields, 1)
+	if err != nil {
+		zf = append(zf, zap.Error(err))
+	}
+	a.logger.Error(msg, zf...)
+}
+
+// NewZapAdapter returns an adapter for the logger.
+func NewZapAdapter(l *zap.Logger) *ZapAdapter {
+	return &ZapAdapter{logger: l}
+}
@@ -0,0 +1,40 @@
+package zap
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/chop-dbhi/eda"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestZapAdapter(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	var a eda.StructuredLogger = NewZapAdapter(zap.New(core))
+
+	a.Info("published", eda.Field{Key: "stream", Value: "orders"})
+	a.Error("handler error", errors.New("failed"), eda.Field{Key: "event_id", Value: "1"})
+
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	info := entries[0]
+	if info.Level != zapcore.InfoLevel || info.ContextMap()["stream"] != "orders" {
+		t.Errorf("unexpected info entry: %+v", info)
+	}
+
+	e := entries[1]
+	if e.Level != zapcore.ErrorLevel {
+		t.Errorf("expected error level, got %s", e.Level)
+	}
+
+	fields := e.ContextMap()
+	if fields["event_id"] != "1" || fields["error"] != "failed" {
+		t.Errorf("unexpected error fields: %v", fields)
+	}
+}
@@ -0,0 +1,84 @@
+package eda
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"log/slog"
+	"testing"
+)
+
+func TestLoggerHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	l := slog.New(&loggerHandler{
+		logger: log.New(&buf, "", 0),
+	})
+
+	l.With(slog.String("client", "foo")).Error("handler error", slog.String("stream", "bar"))
+
+	exp := "handler error client=foo stream=bar\n"
+	if buf.String() != exp {
+		t.Fatalf("expected %q, got %q", exp, buf.String())
+	}
+}
+
+func TestLoggerHandlerGroup(t *testing.T) {
+	var buf bytes.Buffer
+
+	l := slog.New(&loggerHandler{
+		logger: log.New(&buf, "", 0),
+	})
+
+	l.WithGroup("g").With(slog.String("a", "1")).Info("msg", slog.String("b", "2"))
+
+	exp := "msg g.a=1 g.b=2\n"
+	if buf.String() != exp {
+		t.Fatalf("expected %q, got %q", exp, buf.String())
+	}
+}
+
+type entry struct {
+	msg    string
+	err    error
+	fields []Field
+}
+
+type recordLogger struct {
+	entries []entry
+}
+
+func (l *recordLogger) Info(msg string, fields ...Field) {
+	l.entries = append(l.entries, entry{msg: msg, fields: fields})
+}
+
+func (l *recordLogger) Error(msg string, err error, fields ...Field) {
+	l.entries = append(l.entries, entry{msg: msg, err: err, fields: fields})
+}
+
+func TestStructuredHandler(t *testing.T) {
+	rl := &recordLogger{}
+
+	l := slog.New(&structuredHandler{logger: rl}).With(slog.String("client", "foo"))
+
+	l.Warn("handler did not ack", slog.String("stream", "bar"))
+	l.Error("handler error", slog.String("stream", "bar"), slog.Any("error", errors.New("failed")))
+
+	if len(rl.entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(rl.entries))
+	}
+
+	info := rl.entries[0]
+	if info.err != nil || len(info.fields) != 2 || info.fields[0] != (Field{"client", "foo"}) || info.fields[1] != (Field{"stream", "bar"}) {
+		t.Errorf("unexpected info entry: %+v", info)
+	}
+
+	e := rl.entries[1]
+	if e.err == nil || e.err.Error() != "failed" {
+		t.Errorf("expected error to be passed, got %v", e.err)
+	}
+
+	if len(e.fields) != 2 {
+		t.Errorf("expected error field to be removed, got %v", e.fields)
+	}
+}
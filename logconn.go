@@ -0,0 +1,129 @@
+package eda
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LoggingConnOptions controls what is logged by a logging connection.
+type LoggingConnOptions struct {
+	// Log published events.
+	LogPublish bool
+
+	// Log subscriptions and received events.
+	LogSubscribe bool
+
+	// Include the event meta and headers in log entries.
+	LogHeaders bool
+
+	// MaxDataLogSize is the maximum number of data bytes included in log
+	// entries. Larger data is truncated. If zero, data is not logged.
+	MaxDataLogSize int
+}
+
+// loggingConn wraps a Conn and logs events flowing through it.
+type loggingConn struct {
+	Conn
+	logger *slog.Logger
+	opts   LoggingConnOptions
+}
+
+// eventAttrs returns the log attributes for the event.
+func (c *loggingConn) eventAttrs(stream string, evt *Event) []any {
+	attrs := []any{
+		slog.String("stream", stream),
+	}
+
+	if evt == nil {
+		return attrs
+	}
+
+	attrs = append(attrs,
+		slog.String("event_id", evt.ID),
+		slog.String("event_type", evt.Type),
+	)
+
+	if evt.Data != nil {
+		attrs = append(attrs, slog.String("encoding", evt.Data.Type()))
+
+		if c.opts.MaxDataLogSize > 0 {
+			if b, err := evt.Data.Encode(); err == nil {
+				if len(b) > c.opts.MaxDataLogSize {
+					b = b[:c.opts.MaxDataLogSize]
+				}
+				attrs = append(attrs, slog.String("data", string(b)))
+			}
+		}
+	}
+
+	if c.opts.LogHeaders {
+		if len(evt.Meta) > 0 {
+			attrs = append(attrs, slog.Any("meta", evt.Meta))
+		}
+		if len(evt.Headers) > 0 {
+			attrs = append(attrs, slog.Any("headers", evt.Headers))
+		}
+	}
+
+	return attrs
+}
+
+func (c *loggingConn) Publish(stream string, evt *Event) (string, error) {
+	id, err := c.Conn.Publish(stream, evt)
+
+	if c.opts.LogPublish {
+		attrs := c.eventAttrs(stream, evt)
+
+		if err != nil {
+			c.logger.Error("publish failed", append(attrs, slog.Any("error", err))...)
+		} else {
+			c.logger.Debug("published event", append(attrs, slog.String("published_id", id))...)
+		}
+	}
+
+	return id, err
+}
+
+// PublishBatch logs and publishes each event in order.
+func (c *loggingConn) PublishBatch(stream string, evts []*Event) ([]string, error) {
+	return PublishEach(c.Publish, stream, evts)
+}
+
+func (c *loggingConn) Subscribe(stream string, handle Handler, opts *SubscriptionOptions) (Subscription, error) {
+	if !c.opts.LogSubscribe {
+		return c.Conn.Subscribe(stream, handle, opts)
+	}
+
+	logged := func(ctx context.Context, evt *Event) error {
+		attrs := c.eventAttrs(stream, evt)
+
+		c.logger.DebugContext(ctx, "received event", attrs...)
+
+		err := handle(ctx, evt)
+		if err != nil {
+			c.logger.ErrorContext(ctx, "handler error", append(attrs, slog.Any("error", err))...)
+		}
+
+		return err
+	}
+
+	sub, err := c.Conn.Subscribe(stream, logged, opts)
+	if err != nil {
+		c.logger.Error("subscribe failed", slog.String("stream", stream), slog.Any("error", err))
+		return nil, err
+	}
+
+	c.logger.Debug("subscribed", slog.String("stream", stream))
+
+	return sub, nil
+}
+
+// NewLoggingConn returns a Conn that logs published and received events.
+// Events are logged at debug level and errors at error level.
+func NewLoggingConn(c Conn, logger *slog.Logger, opts LoggingConnOptions) Conn {
+	return &loggingConn{
+		Conn:   c,
+		logger: logger,
+		opts:   opts,
+	}
+}
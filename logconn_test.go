@@ -0,0 +1,37 @@
+package eda
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLoggingConnEventAttrs(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	}))
+
+	c := NewLoggingConn(nil, logger, LoggingConnOptions{
+		LogHeaders:     true,
+		MaxDataLogSize: 3,
+	}).(*loggingConn)
+
+	logger.Debug("event", c.eventAttrs(stream, &Event{
+		ID:      "1",
+		Type:    "foo",
+		Data:    String("foobar"),
+		Meta:    map[string]string{"bar": "baz"},
+		Headers: map[string][]string{"Trace-Id": {"abc"}},
+	})...)
+
+	out := buf.String()
+
+	for _, s := range []string{"event_id=1", "event_type=foo", "encoding=string", "data=foo ", "meta=map[bar:baz]", "headers=map[Trace-Id:[abc]]"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected %q in %q", s, out)
+		}
+	}
+}
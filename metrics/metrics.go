@@ -0,0 +1,133 @@
+/*
+Package metrics records Prometheus metrics for publishing and handling
+events.
+
+The connection wrapper records publishes:
+
+	conn = metrics.WrapConnWithMetrics(conn, prometheus.DefaultRegisterer)
+
+The middleware records handler durations and errors:
+
+	handle = eda.Chain(metrics.NewPrometheusMiddleware(reg))(handle)
+*/
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/chop-dbhi/eda"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// register registers the collector or returns the one already registered
+// with the same descriptor, so wrapping multiple connections or handlers
+// with the same registerer shares the metrics.
+func register[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+
+	return c
+}
+
+type metricsConn struct {
+	eda.Conn
+
+	total    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func (c *metricsConn) Publish(stream string, evt *eda.Event) (string, error) {
+	var typ string
+	if evt != nil {
+		typ = evt.Type
+	}
+
+	t0 := time.Now()
+	id, err := c.Conn.Publish(stream, evt)
+	c.duration.WithLabelValues(stream).Observe(time.Since(t0).Seconds())
+
+	if err == nil {
+		c.total.WithLabelValues(stream, typ).Inc()
+	}
+
+	return id, err
+}
+
+// PublishBatch records the batch as a single publish duration and counts
+// each published event.
+func (c *metricsConn) PublishBatch(stream string, evts []*eda.Event) ([]string, error) {
+	t0 := time.Now()
+	ids, err := c.Conn.PublishBatch(stream, evts)
+	c.duration.WithLabelValues(stream).Observe(time.Since(t0).Seconds())
+
+	for _, evt := range evts[:len(ids)] {
+		var typ string
+		if evt != nil {
+			typ = evt.Type
+		}
+		c.total.WithLabelValues(stream, typ).Inc()
+	}
+
+	return ids, err
+}
+
+// WrapConnWithMetrics wraps the connection to record the number of events
+// published by stream and type and the publish duration by stream. The
+// metrics are registered with reg.
+func WrapConnWithMetrics(conn eda.Conn, reg prometheus.Registerer) eda.Conn {
+	total := register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "eda_publish_total",
+		Help: "Number of events published.",
+	}, []string{"stream", "type"}))
+
+	duration := register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "eda_publish_duration_seconds",
+		Help:    "Time taken to publish events.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"stream"}))
+
+	return &metricsConn{
+		Conn:     conn,
+		total:    total,
+		duration: duration,
+	}
+}
+
+// NewPrometheusMiddleware returns middleware that records the handler
+// duration and number of handler errors by stream and event type. The
+// metrics are registered with reg.
+func NewPrometheusMiddleware(reg prometheus.Registerer) eda.MiddlewareFunc {
+	duration := register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "eda_handler_duration_seconds",
+		Help:    "Time taken to handle events.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"stream", "type"}))
+
+	errs := register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "eda_handler_errors_total",
+		Help: "Number of events the handler returned an error for.",
+	}, []string{"stream", "type"}))
+
+	return func(next eda.Handler) eda.Handler {
+		return func(ctx context.Context, evt *eda.Event) error {
+			t0 := time.Now()
+			err := next(ctx, evt)
+			duration.WithLabelValues(evt.Stream, evt.Type).Observe(time.Since(t0).Seconds())
+
+			if err != nil {
+				errs.WithLabelValues(evt.Stream, evt.Type).Inc()
+			}
+
+			return err
+		}
+	}
+}
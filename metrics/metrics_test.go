@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/chop-dbhi/eda"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type nopConn struct {
+	eda.Conn
+}
+
+func (c *nopConn) Publish(stream string, evt *eda.Event) (string, error) {
+	if evt.Type == "fail" {
+		return "", errors.New("publish failed")
+	}
+	return "1", nil
+}
+
+func (c *nopConn) PublishBatch(stream string, evts []*eda.Event) ([]string, error) {
+	return eda.PublishEach(c.Publish, stream, evts)
+}
+
+func TestWrapConnWithMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	conn := WrapConnWithMetrics(&nopConn{}, reg)
+
+	conn.Publish("orders", &eda.Event{Type: "order-placed"})
+	conn.Publish("orders", &eda.Event{Type: "fail"})
+	conn.PublishBatch("orders", []*eda.Event{
+		{Type: "order-placed"},
+		{Type: "order-shipped"},
+		{Type: "fail"},
+	})
+
+	// A second wrapper shares the registered metrics.
+	WrapConnWithMetrics(&nopConn{}, reg).Publish("orders", &eda.Event{Type: "order-placed"})
+
+	total := conn.(*metricsConn).total
+
+	if n := testutil.ToFloat64(total.WithLabelValues("orders", "order-placed")); n != 3 {
+		t.Errorf("expected 3 order-placed publishes, got %v", n)
+	}
+
+	if n := testutil.ToFloat64(total.WithLabelValues("orders", "order-shipped")); n != 1 {
+		t.Errorf("expected 1 order-shipped publish, got %v", n)
+	}
+
+	if n := testutil.CollectAndCount(total, "eda_publish_total"); n != 2 {
+		t.Errorf("expected 2 series, got %d", n)
+	}
+
+	if n := testutil.CollectAndCount(reg, "eda_publish_duration_seconds"); n != 1 {
+		t.Errorf("expected 1 duration series, got %d", n)
+	}
+}
+
+func TestPrometheusMiddleware(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	handle := eda.Chain(NewPrometheusMiddleware(reg))(func(ctx context.Context, evt *eda.Event) error {
+		if evt.Type == "fail" {
+			return errors.New("handler failed")
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	handle(ctx, &eda.Event{Stream: "orders", Type: "order-placed"})
+	handle(ctx, &eda.Event{Stream: "orders", Type: "fail"})
+	handle(ctx, &eda.Event{Stream: "orders", Type: "fail"})
+
+	if n := testutil.CollectAndCount(reg, "eda_handler_duration_seconds"); n != 2 {
+		t.Errorf("expected 2 duration series, got %d", n)
+	}
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mf := range mfs {
+		if mf.GetName() != "eda_handler_errors_total" {
+			continue
+		}
+
+		ms := mf.GetMetric()
+		if len(ms) != 1 {
+			t.Fatalf("expected 1 error series, got %d", len(ms))
+		}
+
+		if v := ms[0].GetCounter().GetValue(); v != 2 {
+			t.Errorf("expected 2 errors, got %v", v)
+		}
+
+		return
+	}
+
+	t.Error("eda_handler_errors_total not registered")
+}
@@ -0,0 +1,20 @@
+package eda
+
+// MiddlewareFunc wraps a handler to add behavior such as logging, metrics,
+// or retries.
+type MiddlewareFunc func(Handler) Handler
+
+// Chain composes the middleware left-to-right so the first middleware is
+// the outermost and sees the event first.
+//
+//	handle = eda.Chain(logging, timing, recovery)(handle)
+//	sub, err := conn.Subscribe("orders", handle, nil)
+func Chain(middlewares ...MiddlewareFunc) MiddlewareFunc {
+	return func(h Handler) Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			h = middlewares[i](h)
+		}
+
+		return h
+	}
+}
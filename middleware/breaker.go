@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/chop-dbhi/eda"
+)
+
+// ErrCircuitOpen is returned by CircuitBreakerMiddleware without calling the
+// handler while the circuit is open, which leaves the event to be
+// redelivered.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// errHandlerPanic is recorded as the result of a handler that panicked.
+var errHandlerPanic = errors.New("handler panicked")
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// Closed calls the handler and counts consecutive failures.
+	Closed CircuitState = iota
+
+	// Open fails events without calling the handler.
+	Open
+
+	// HalfOpen calls the handler for a single event to test whether it
+	// has recovered.
+	HalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+// CircuitBreakerConfig configures CircuitBreakerMiddleware.
+type CircuitBreakerConfig struct {
+	// MaxFailures is the number of consecutive handler errors that open
+	// the circuit. Defaults to 5.
+	MaxFailures int
+
+	// Timeout is the time the circuit stays open before an event is let
+	// through to test the handler. Defaults to 30 seconds.
+	Timeout time.Duration
+
+	// OnStateChange is called after the state of the circuit changes.
+	OnStateChange func(from, to CircuitState)
+}
+
+// breaker is the state of a circuit breaker shared by the events handled
+// by the middleware.
+type breaker struct {
+	cfg CircuitBreakerConfig
+	now func() time.Time
+
+	mux      sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+
+	// True while the event testing a half-open circuit is handled.
+	testing bool
+}
+
+// transition sets the state and returns a func that notifies of the
+// change, which is called once the lock is released.
+func (b *breaker) transition(to CircuitState) func() {
+	from := b.state
+	b.state = to
+
+	if to == Open {
+		b.openedAt = b.now()
+	}
+
+	if b.cfg.OnStateChange == nil || from == to {
+		return func() {}
+	}
+
+	return func() { b.cfg.OnStateChange(from, to) }
+}
+
+// allow returns true if the handler can be called.
+func (b *breaker) allow() bool {
+	notify := func() {}
+	defer func() { notify() }()
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	switch b.state {
+	case Open:
+		if b.now().Sub(b.openedAt) < b.cfg.Timeout {
+			return false
+		}
+		notify = b.transition(HalfOpen)
+	case HalfOpen:
+		if b.testing {
+			return false
+		}
+	default:
+		return true
+	}
+
+	b.testing = true
+
+	return true
+}
+
+// done records the result of calling the handler.
+func (b *breaker) done(err error) {
+	notify := func() {}
+	defer func() { notify() }()
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	switch b.state {
+	case Closed:
+		if err == nil {
+			b.failures = 0
+			return
+		}
+
+		b.failures++
+		if b.failures >= b.cfg.MaxFailures {
+			notify = b.transition(Open)
+		}
+	case HalfOpen:
+		b.testing = false
+		b.failures = 0
+
+		if err != nil {
+			notify = b.transition(Open)
+		} else {
+			notify = b.transition(Closed)
+		}
+	}
+}
+
+// CircuitBreakerMiddleware stops calling the handler once it fails
+// MaxFailures times in a row, returning ErrCircuitOpen instead so the
+// downstream systems the handler depends on can recover. After the timeout
+// a single event is handled to test the handler, which closes the circuit
+// if it succeeds or reopens it if it fails. A handler that panics counts as
+// a failure. The state is shared by all handlers wrapped by the returned
+// middleware.
+func CircuitBreakerMiddleware(cfg CircuitBreakerConfig) eda.MiddlewareFunc {
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = 5
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+
+	b := &breaker{
+		cfg: cfg,
+		now: time.Now,
+	}
+
+	return b.middleware
+}
+
+func (b *breaker) middleware(next eda.Handler) eda.Handler {
+	return func(ctx context.Context, evt *eda.Event) (err error) {
+		if !b.allow() {
+			return ErrCircuitOpen
+		}
+
+		// The result is recorded even if the handler panics, which counts as
+		// a failure, so a half-open circuit is not left testing.
+		panicked := true
+		defer func() {
+			if panicked {
+				err = errHandlerPanic
+			}
+			b.done(err)
+		}()
+
+		err = next(ctx, evt)
+		panicked = false
+
+		return err
+	}
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chop-dbhi/eda"
+)
+
+func TestCircuitBreakerMiddleware(t *testing.T) {
+	var changes []string
+
+	now := time.Now()
+
+	b := &breaker{
+		cfg: CircuitBreakerConfig{
+			MaxFailures: 2,
+			Timeout:     time.Minute,
+			OnStateChange: func(from, to CircuitState) {
+				changes = append(changes, from.String()+">"+to.String())
+			},
+		},
+		now: func() time.Time { return now },
+	}
+
+	var calls int
+	fail := true
+
+	handle := b.middleware(func(ctx context.Context, evt *eda.Event) error {
+		calls++
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	evt := &eda.Event{}
+
+	for i := 0; i < 3; i++ {
+		handle(ctx, evt)
+	}
+
+	if calls != 2 {
+		t.Errorf("expected 2 calls before opening, got %d", calls)
+	}
+
+	if err := handle(ctx, evt); err != ErrCircuitOpen {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	// The failed test reopens the circuit.
+	now = now.Add(time.Minute)
+	handle(ctx, evt)
+
+	if err := handle(ctx, evt); err != ErrCircuitOpen {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	// The successful test closes the circuit.
+	now = now.Add(time.Minute)
+	fail = false
+
+	for i := 0; i < 2; i++ {
+		if err := handle(ctx, evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if calls != 5 {
+		t.Errorf("expected 5 calls, got %d", calls)
+	}
+
+	exp := []string{
+		"closed>open",
+		"open>half-open",
+		"half-open>open",
+		"open>half-open",
+		"half-open>closed",
+	}
+
+	if len(changes) != len(exp) {
+		t.Fatalf("expected changes %v, got %v", exp, changes)
+	}
+
+	for i, c := range exp {
+		if changes[i] != c {
+			t.Errorf("expected changes %v, got %v", exp, changes)
+			break
+		}
+	}
+}
+
+func TestCircuitBreakerMiddlewarePanic(t *testing.T) {
+	now := time.Now()
+
+	b := &breaker{
+		cfg: CircuitBreakerConfig{
+			MaxFailures: 1,
+			Timeout:     time.Minute,
+		},
+		now: func() time.Time { return now },
+	}
+
+	handle := b.middleware(func(ctx context.Context, evt *eda.Event) error {
+		panic("failed")
+	})
+
+	call := func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic to propagate")
+			}
+		}()
+		handle(context.Background(), &eda.Event{})
+	}
+
+	// The panic opens the circuit.
+	call()
+
+	if b.state != Open {
+		t.Fatalf("expected open circuit, got %s", b.state)
+	}
+
+	// The panic while testing reopens the circuit.
+	now = now.Add(time.Minute)
+	call()
+
+	if b.state != Open || b.testing {
+		t.Fatalf("expected open circuit that is not testing, got %s", b.state)
+	}
+}
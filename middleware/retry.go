@@ -0,0 +1,61 @@
+/*
+Package middleware provides common handler middleware for use with
+eda.Chain.
+*/
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/chop-dbhi/eda"
+)
+
+// BackoffFunc returns the duration to wait after the failed attempt.
+// Attempts start at 1.
+type BackoffFunc func(attempt int) time.Duration
+
+// ExponentialBackoff returns a BackoffFunc that doubles the base duration
+// after each attempt.
+func ExponentialBackoff(base time.Duration) BackoffFunc {
+	return func(attempt int) time.Duration {
+		if attempt < 1 {
+			attempt = 1
+		}
+
+		// Cap the shift to prevent overflow.
+		if attempt > 32 {
+			attempt = 32
+		}
+
+		return base << uint(attempt-1)
+	}
+}
+
+// RetryMiddleware retries the handler inline up to maxAttempts times,
+// waiting between attempts. The last error is returned once the attempts
+// are exhausted, which leaves the event to be redelivered. Retrying stops
+// if the context is done.
+func RetryMiddleware(maxAttempts int, backoff BackoffFunc) eda.MiddlewareFunc {
+	return func(next eda.Handler) eda.Handler {
+		return func(ctx context.Context, evt *eda.Event) error {
+			var err error
+
+			for attempt := 1; ; attempt++ {
+				if err = next(ctx, evt); err == nil {
+					return nil
+				}
+
+				if attempt >= maxAttempts {
+					return err
+				}
+
+				select {
+				case <-ctx.Done():
+					return err
+				case <-time.After(backoff(attempt)):
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chop-dbhi/eda"
+)
+
+func TestRetryMiddleware(t *testing.T) {
+	var calls int
+
+	handle := RetryMiddleware(3, ExponentialBackoff(time.Millisecond))(func(ctx context.Context, evt *eda.Event) error {
+		calls++
+		if calls < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+
+	if err := handle(context.Background(), &eda.Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestRetryMiddlewareExhausted(t *testing.T) {
+	var calls int
+
+	handle := RetryMiddleware(2, ExponentialBackoff(time.Millisecond))(func(ctx context.Context, evt *eda.Event) error {
+		calls++
+		return errors.New("failed")
+	})
+
+	if err := handle(context.Background(), &eda.Event{}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestRetryMiddlewareContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var calls int
+
+	handle := RetryMiddleware(5, ExponentialBackoff(time.Hour))(func(ctx context.Context, evt *eda.Event) error {
+		calls++
+		return errors.New("failed")
+	})
+
+	if err := handle(ctx, &eda.Event{}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff(time.Second)
+
+	for attempt, d := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second} {
+		if got := b(attempt); got != d {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, d, got)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package eda
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	var calls []string
+
+	mw := func(name string) MiddlewareFunc {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, evt *Event) error {
+				calls = append(calls, name)
+				return next(ctx, evt)
+			}
+		}
+	}
+
+	handle := Chain(mw("a"), mw("b"), mw("c"))(func(ctx context.Context, evt *Event) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+
+	if err := handle(context.Background(), &Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.Join(calls, ","); s != "a,b,c,handler" {
+		t.Errorf("unexpected call order: %s", s)
+	}
+
+	// An empty chain returns the handler.
+	calls = nil
+	Chain()(func(ctx context.Context, evt *Event) error {
+		calls = append(calls, "handler")
+		return nil
+	})(context.Background(), &Event{})
+
+	if len(calls) != 1 {
+		t.Errorf("expected handler to be called, got %v", calls)
+	}
+}
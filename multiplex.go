@@ -0,0 +1,488 @@
+package eda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// TenantMetaKey is the event meta key used to route events to a tenant.
+const TenantMetaKey = "tenant_id"
+
+// ErrUnknownTenant is returned when publishing an event for a tenant
+// without a connection.
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// muxSubscription is a subscription created on the multiplexer. It
+// contains the subscriptions on each tenant connection.
+type muxSubscription struct {
+	mux    *Multiplexer
+	stream string
+	handle Handler
+	opts   *SubscriptionOptions
+	subs   map[string]Subscription
+	paused bool
+}
+
+// tenantSubs returns the subscriptions on the tenants. The caller must
+// hold the multiplexer lock.
+func (s *muxSubscription) tenantSubs() []Subscription {
+	subs := make([]Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+// closeSubs closes or unsubscribes the subscriptions. This must be called
+// without the multiplexer lock held, since closing waits for running
+// handlers, which may publish with the multiplexer.
+func closeSubs(subs []Subscription, unsubscribe bool) error {
+	var err error
+	for _, sub := range subs {
+		var e error
+		if unsubscribe {
+			e = sub.Unsubscribe()
+		} else {
+			e = sub.Close()
+		}
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+func (s *muxSubscription) close(unsubscribe bool) error {
+	s.mux.mux.Lock()
+	delete(s.mux.subs, s)
+	subs := s.tenantSubs()
+	s.subs = make(map[string]Subscription)
+	s.mux.mux.Unlock()
+
+	return closeSubs(subs, unsubscribe)
+}
+
+func (s *muxSubscription) Close() error {
+	return s.close(false)
+}
+
+func (s *muxSubscription) Unsubscribe() error {
+	return s.close(true)
+}
+
+// Pause pauses the subscription on each of the tenants, including
+// tenants added while paused.
+func (s *muxSubscription) Pause() error {
+	s.mux.mux.Lock()
+	s.paused = true
+	subs := s.tenantSubs()
+	s.mux.mux.Unlock()
+
+	var err error
+	for _, sub := range subs {
+		if e := sub.Pause(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// Resume resumes the subscription on each of the tenants. The lock is not
+// held while resuming since buffered events are handled synchronously.
+func (s *muxSubscription) Resume() error {
+	s.mux.mux.Lock()
+	s.paused = false
+	subs := s.tenantSubs()
+	s.mux.mux.Unlock()
+
+	var err error
+	for _, sub := range subs {
+		if e := sub.Resume(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+func (s *muxSubscription) IsPaused() bool {
+	s.mux.mux.RLock()
+	defer s.mux.mux.RUnlock()
+
+	return s.paused
+}
+
+// Expired returns the number of expired events across the current
+// tenants.
+func (s *muxSubscription) Expired() uint64 {
+	s.mux.mux.RLock()
+	defer s.mux.mux.RUnlock()
+
+	var n uint64
+	for _, sub := range s.subs {
+		n += sub.Expired()
+	}
+
+	return n
+}
+
+// GetLatest returns the most recent event for the key across tenants.
+func (s *muxSubscription) GetLatest(key string) (*Event, bool) {
+	s.mux.mux.RLock()
+	defer s.mux.mux.RUnlock()
+
+	var latest *Event
+
+	for _, sub := range s.subs {
+		if evt, ok := sub.GetLatest(key); ok {
+			if latest == nil || evt.Time.After(latest.Time) {
+				latest = evt
+			}
+		}
+	}
+
+	return latest, latest != nil
+}
+
+// Multiplexer is a Conn that routes events to per-tenant connections,
+// for example to isolate tenants on separate clusters. Published events
+// are routed by the tenant_id meta value and subscriptions receive events
+// from all tenants.
+type Multiplexer struct {
+	mux    sync.RWMutex
+	routes map[string]Conn
+	subs   map[*muxSubscription]struct{}
+}
+
+// AddTenant adds or replaces the connection for a tenant. Existing
+// subscriptions are extended to the connection. If a subscription fails,
+// the subscriptions on the connection are closed and the previous
+// connection, if any, is restored.
+func (m *Multiplexer) AddTenant(id string, conn Conn) error {
+	m.mux.Lock()
+
+	prev, replaced := m.routes[id]
+
+	// The subscriptions on the previous connection, which are replaced if
+	// subscribing succeeds.
+	old := make(map[*muxSubscription]Subscription)
+	for s := range m.subs {
+		if sub, ok := s.subs[id]; ok {
+			old[s] = sub
+		}
+	}
+
+	m.routes[id] = conn
+
+	var (
+		subs []Subscription
+		err  error
+	)
+
+	for s := range m.subs {
+		var sub Subscription
+		if sub, err = conn.Subscribe(s.stream, s.handle, s.opts); err != nil {
+			err = fmt.Errorf("tenant %s: %w", id, err)
+			break
+		}
+
+		subs = append(subs, sub)
+		s.subs[id] = sub
+
+		if s.paused {
+			if err = sub.Pause(); err != nil {
+				err = fmt.Errorf("tenant %s: %w", id, err)
+				break
+			}
+		}
+	}
+
+	var closing []Subscription
+
+	if err != nil {
+		closing = subs
+
+		for s := range m.subs {
+			delete(s.subs, id)
+		}
+		for s, sub := range old {
+			s.subs[id] = sub
+		}
+
+		if replaced {
+			m.routes[id] = prev
+		} else {
+			delete(m.routes, id)
+		}
+	} else {
+		for _, sub := range old {
+			closing = append(closing, sub)
+		}
+	}
+
+	m.mux.Unlock()
+
+	// Close the replaced or rolled back subscriptions without the lock held.
+	if e := closeSubs(closing, false); e != nil && err == nil {
+		err = e
+	}
+
+	return err
+}
+
+// RemoveTenant closes the subscriptions on the tenant connection and
+// removes the route. The connection itself is not closed.
+func (m *Multiplexer) RemoveTenant(id string) error {
+	m.mux.Lock()
+
+	if _, ok := m.routes[id]; !ok {
+		m.mux.Unlock()
+		return ErrUnknownTenant
+	}
+
+	subs := m.removeTenant(id)
+	m.mux.Unlock()
+
+	return closeSubs(subs, false)
+}
+
+// removeTenant removes the route and returns the subscriptions on the
+// tenant for the caller to close once the lock is released.
+func (m *Multiplexer) removeTenant(id string) []Subscription {
+	var subs []Subscription
+
+	for s := range m.subs {
+		if sub, ok := s.subs[id]; ok {
+			subs = append(subs, sub)
+			delete(s.subs, id)
+		}
+	}
+
+	delete(m.routes, id)
+
+	return subs
+}
+
+// Publish publishes the event on the connection of the tenant set in
+// the event meta.
+func (m *Multiplexer) Publish(stream string, evt *Event) (string, error) {
+	var tenant string
+	if evt != nil {
+		tenant = evt.Meta[TenantMetaKey]
+	}
+
+	m.mux.RLock()
+	conn, ok := m.routes[tenant]
+	m.mux.RUnlock()
+
+	if !ok {
+		return "", ErrUnknownTenant
+	}
+
+	return conn.Publish(stream, evt)
+}
+
+// Request sends the request on the connection of the tenant set in the
+// event meta.
+func (m *Multiplexer) Request(ctx context.Context, stream string, evt *Event) (*Reply, error) {
+	var tenant string
+	if evt != nil {
+		tenant = evt.Meta[TenantMetaKey]
+	}
+
+	m.mux.RLock()
+	conn, ok := m.routes[tenant]
+	m.mux.RUnlock()
+
+	if !ok {
+		return nil, ErrUnknownTenant
+	}
+
+	return conn.Request(ctx, stream, evt)
+}
+
+// PublishBatch routes each event to the connection of its tenant.
+func (m *Multiplexer) PublishBatch(stream string, evts []*Event) ([]string, error) {
+	return PublishEach(m.Publish, stream, evts)
+}
+
+// Subscribe subscribes to the stream on all tenant connections and calls
+// the handler for events from any of them.
+func (m *Multiplexer) Subscribe(stream string, handle Handler, opts *SubscriptionOptions) (Subscription, error) {
+	m.mux.Lock()
+
+	s := &muxSubscription{
+		mux:    m,
+		stream: stream,
+		handle: handle,
+		opts:   opts,
+		subs:   make(map[string]Subscription, len(m.routes)),
+	}
+
+	for id, conn := range m.routes {
+		sub, err := conn.Subscribe(stream, handle, opts)
+		if err != nil {
+			subs := s.tenantSubs()
+			m.mux.Unlock()
+
+			// Close the subscriptions already created without the lock held.
+			closeSubs(subs, false)
+
+			return nil, fmt.Errorf("tenant %s: %w", id, err)
+		}
+
+		s.subs[id] = sub
+	}
+
+	m.subs[s] = struct{}{}
+	m.mux.Unlock()
+
+	return s, nil
+}
+
+// Read reads the stream from each tenant connection in turn, ordered by
+// tenant ID. Sequences in the options apply to each connection, while
+// MaxEvents applies to the events read across tenants.
+func (m *Multiplexer) Read(stream string, opts *ReadOptions) (EventIterator, error) {
+	if opts == nil {
+		opts = &ReadOptions{}
+	}
+
+	m.mux.RLock()
+	ids := make([]string, 0, len(m.routes))
+	conns := make(map[string]Conn, len(m.routes))
+	for id, conn := range m.routes {
+		ids = append(ids, id)
+		conns[id] = conn
+	}
+	m.mux.RUnlock()
+
+	sort.Strings(ids)
+
+	topts := *opts
+	topts.MaxEvents = 0
+
+	var cur EventIterator
+
+	next := func(ctx context.Context) (*Event, error) {
+		for {
+			if cur == nil {
+				if len(ids) == 0 {
+					return nil, io.EOF
+				}
+
+				id := ids[0]
+				ids = ids[1:]
+
+				it, err := conns[id].Read(stream, &topts)
+				if err != nil {
+					return nil, fmt.Errorf("tenant %s: %w", id, err)
+				}
+
+				cur = it
+			}
+
+			evt, err := cur.Next(ctx)
+			if err == io.EOF {
+				cur.Close()
+				cur = nil
+				continue
+			}
+
+			return evt, err
+		}
+	}
+
+	closeFn := func() error {
+		if cur != nil {
+			return cur.Close()
+		}
+		return nil
+	}
+
+	return NewEventIterator(next, closeFn, opts), nil
+}
+
+// StreamStats returns the combined stats of the stream across tenants.
+// Sequences are specific to each connection so they are not set.
+func (m *Multiplexer) StreamStats(ctx context.Context, stream string) (*StreamStats, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	total := &StreamStats{}
+
+	for id, conn := range m.routes {
+		stats, err := conn.StreamStats(ctx, stream)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", id, err)
+		}
+
+		total.MsgCount += stats.MsgCount
+		total.ByteSize += stats.ByteSize
+
+		if total.FirstTime.IsZero() || (!stats.FirstTime.IsZero() && stats.FirstTime.Before(total.FirstTime)) {
+			total.FirstTime = stats.FirstTime
+		}
+
+		if stats.LastTime.After(total.LastTime) {
+			total.LastTime = stats.LastTime
+		}
+	}
+
+	return total, nil
+}
+
+func (m *Multiplexer) MultiStreamStats(ctx context.Context, streams []string) (map[string]*StreamStats, error) {
+	s := make(map[string]*StreamStats, len(streams))
+
+	for _, stream := range streams {
+		stats, err := m.StreamStats(ctx, stream)
+		if err != nil {
+			return nil, err
+		}
+
+		s[stream] = stats
+	}
+
+	return s, nil
+}
+
+// Close closes all tenant connections. The lock is not held while closing
+// since connections wait for running handlers.
+func (m *Multiplexer) Close() error {
+	m.mux.RLock()
+	conns := make([]Conn, 0, len(m.routes))
+	for _, conn := range m.routes {
+		conns = append(conns, conn)
+	}
+	m.mux.RUnlock()
+
+	var err error
+	for _, conn := range conns {
+		if e := conn.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// NewMultiplexer returns a multiplexer with connections keyed by tenant ID.
+func NewMultiplexer(routes map[string]Conn) *Multiplexer {
+	m := &Multiplexer{
+		routes: make(map[string]Conn, len(routes)),
+		subs:   make(map[*muxSubscription]struct{}),
+	}
+
+	for id, conn := range routes {
+		m.routes[id] = conn
+	}
+
+	return m
+}
@@ -0,0 +1,272 @@
+package eda
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMultiplexer(t *testing.T) {
+	a, err := Connect(addr, cluster, client+"-a")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	b, err := Connect(addr, cluster, client+"-b")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	m := NewMultiplexer(map[string]Conn{
+		"a": a,
+		"b": b,
+	})
+	defer m.Close()
+
+	received := make(chan *Event, 2)
+
+	handle := func(ctx context.Context, evt *Event) error {
+		received <- evt
+		return nil
+	}
+
+	sub, err := m.Subscribe(stream, handle, &SubscriptionOptions{
+		Name: "multiplexer",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	if _, err := m.Publish(stream, &Event{Type: "foo"}); err != ErrUnknownTenant {
+		t.Fatalf("expected unknown tenant error, got %v", err)
+	}
+
+	if _, err := m.Publish(stream, &Event{
+		Type: "foo",
+		Meta: map[string]string{
+			TenantMetaKey: "b",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case evt := <-received:
+		if evt.Client != client+"-b" {
+			t.Fatalf("expected event from tenant b connection, got %s", evt.Client)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	if err := m.RemoveTenant("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.RemoveTenant("b"); err != ErrUnknownTenant {
+		t.Fatalf("expected unknown tenant error, got %v", err)
+	}
+
+	b.Close()
+}
+
+// republishConn is a tenant connection whose subscriptions call the handler
+// synchronously on Resume and Close, like a subscription handling buffered
+// or in-flight events.
+type republishConn struct {
+	Conn
+	published chan string
+}
+
+func (c *republishConn) Publish(stream string, evt *Event) (string, error) {
+	c.published <- evt.Type
+	return evt.Type, nil
+}
+
+func (c *republishConn) Subscribe(stream string, handle Handler, opts *SubscriptionOptions) (Subscription, error) {
+	return &republishSub{handle: handle}, nil
+}
+
+type republishSub struct {
+	Subscription
+	handle Handler
+}
+
+func (s *republishSub) Pause() error { return nil }
+
+func (s *republishSub) Resume() error {
+	return s.handle(context.Background(), &Event{Type: "resume"})
+}
+
+func (s *republishSub) Close() error {
+	return s.handle(context.Background(), &Event{Type: "close"})
+}
+
+func TestMultiplexerHandlerPublish(t *testing.T) {
+	conn := &republishConn{published: make(chan string, 2)}
+
+	m := NewMultiplexer(map[string]Conn{"a": conn})
+
+	// The handler republishes through the multiplexer.
+	sub, err := m.Subscribe(stream, func(ctx context.Context, evt *Event) error {
+		_, err := m.Publish(stream, &Event{
+			Type: evt.Type,
+			Meta: map[string]string{TenantMetaKey: "a"},
+		})
+		return err
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+
+	go func() {
+		if err := sub.Pause(); err != nil {
+			done <- err
+			return
+		}
+		if err := sub.Resume(); err != nil {
+			done <- err
+			return
+		}
+		done <- sub.Close()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("deadlock publishing from handler during resume or close")
+	}
+
+	if typ := <-conn.published; typ != "resume" {
+		t.Errorf("expected resume event, got %s", typ)
+	}
+	if typ := <-conn.published; typ != "close" {
+		t.Errorf("expected close event, got %s", typ)
+	}
+}
+
+// failConn is a tenant connection whose subscriptions fail after the
+// first.
+type failConn struct {
+	republishConn
+	subscribed int
+}
+
+var errSubscribe = errors.New("subscribe failed")
+
+func (c *failConn) Subscribe(stream string, handle Handler, opts *SubscriptionOptions) (Subscription, error) {
+	if c.subscribed > 0 {
+		return nil, errSubscribe
+	}
+
+	c.subscribed++
+
+	return c.republishConn.Subscribe(stream, handle, opts)
+}
+
+func TestMultiplexerSubscribeError(t *testing.T) {
+	conn := &failConn{republishConn: republishConn{published: make(chan string, 1)}}
+
+	// The second tenant subscription fails.
+	m := NewMultiplexer(map[string]Conn{"a": conn, "b": conn})
+
+	done := make(chan error)
+
+	go func() {
+		// The handler publishes through the multiplexer when the first
+		// subscription is closed.
+		_, err := m.Subscribe(stream, func(ctx context.Context, evt *Event) error {
+			_, err := m.Publish(stream, &Event{
+				Type: evt.Type,
+				Meta: map[string]string{TenantMetaKey: "a"},
+			})
+			return err
+		}, nil)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, errSubscribe) {
+			t.Fatalf("expected subscribe error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("deadlock publishing from handler while closing subscriptions")
+	}
+
+	if typ := <-conn.published; typ != "close" {
+		t.Errorf("expected close event, got %s", typ)
+	}
+}
+
+func TestMultiplexerAddTenantError(t *testing.T) {
+	conn := &republishConn{published: make(chan string, 1)}
+
+	m := NewMultiplexer(map[string]Conn{"a": conn})
+
+	closed := make(chan string, 4)
+
+	handle := func(ctx context.Context, evt *Event) error {
+		closed <- evt.Type
+		return nil
+	}
+
+	// The second subscription on an added tenant fails.
+	var subs []Subscription
+	for i := 0; i < 2; i++ {
+		sub, err := m.Subscribe(stream, handle, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+
+	// The added tenant is removed.
+	if err := m.AddTenant("b", &failConn{}); !errors.Is(err, errSubscribe) {
+		t.Fatalf("expected subscribe error, got %v", err)
+	}
+
+	if typ := <-closed; typ != "close" {
+		t.Errorf("expected the created subscription to be closed, got %s", typ)
+	}
+
+	if _, err := m.Publish(stream, &Event{Meta: map[string]string{TenantMetaKey: "b"}}); err != ErrUnknownTenant {
+		t.Errorf("expected unknown tenant error, got %v", err)
+	}
+
+	// The replaced tenant is restored.
+	if err := m.AddTenant("a", &failConn{}); !errors.Is(err, errSubscribe) {
+		t.Fatalf("expected subscribe error, got %v", err)
+	}
+
+	if typ := <-closed; typ != "close" {
+		t.Errorf("expected the created subscription to be closed, got %s", typ)
+	}
+
+	if _, err := m.Publish(stream, &Event{Type: "foo", Meta: map[string]string{TenantMetaKey: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if typ := <-conn.published; typ != "foo" {
+		t.Errorf("expected event published on the restored connection, got %s", typ)
+	}
+
+	for _, sub := range subs {
+		if err := sub.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := len(closed); n != 2 {
+		t.Errorf("expected the restored subscriptions to be closed, got %d", n)
+	}
+}
@@ -0,0 +1,69 @@
+package eda
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidStreamName is returned when a stream name does not match the
+// naming convention.
+var ErrInvalidStreamName = errors.New("invalid stream name")
+
+// StreamNamer is a naming convention for the streams of aggregates.
+type StreamNamer interface {
+	// StreamName returns the stream name for the aggregate.
+	StreamName(aggregateType, aggregateID string) string
+
+	// ParseStreamName returns the aggregate type and ID of the stream.
+	ParseStreamName(stream string) (aggregateType, aggregateID string, err error)
+}
+
+// DefaultStreamNamer is the naming convention used if none is specified.
+var DefaultStreamNamer StreamNamer = HyphenatedNamer{}
+
+// splitStreamName splits the stream on the first occurrence of the
+// separator. Both parts must be non-empty.
+func splitStreamName(stream, sep string) (string, string, error) {
+	i := strings.Index(stream, sep)
+	if i <= 0 || i+len(sep) == len(stream) {
+		return "", "", ErrInvalidStreamName
+	}
+
+	return stream[:i], stream[i+len(sep):], nil
+}
+
+// HyphenatedNamer names streams as <type>-events-<id>, such as
+// "order-events-123".
+type HyphenatedNamer struct{}
+
+func (HyphenatedNamer) StreamName(aggregateType, aggregateID string) string {
+	return aggregateType + "-events-" + aggregateID
+}
+
+func (HyphenatedNamer) ParseStreamName(stream string) (string, string, error) {
+	return splitStreamName(stream, "-events-")
+}
+
+// SlashNamer names streams as <type>/<id>, such as "orders/123".
+type SlashNamer struct{}
+
+func (SlashNamer) StreamName(aggregateType, aggregateID string) string {
+	return aggregateType + "/" + aggregateID
+}
+
+func (SlashNamer) ParseStreamName(stream string) (string, string, error) {
+	return splitStreamName(stream, "/")
+}
+
+// DotNamer names streams as <type>.<id>, such as "orders.123". Since NATS
+// uses dots to separate subject tokens, aggregate types should not contain
+// dots.
+type DotNamer struct{}
+
+func (DotNamer) StreamName(aggregateType, aggregateID string) string {
+	return aggregateType + "." + aggregateID
+}
+
+func (DotNamer) ParseStreamName(stream string) (string, string, error) {
+	return splitStreamName(stream, ".")
+}
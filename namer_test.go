@@ -0,0 +1,37 @@
+package eda
+
+import "testing"
+
+func TestStreamNamers(t *testing.T) {
+	tests := []struct {
+		namer  StreamNamer
+		typ    string
+		stream string
+	}{
+		{HyphenatedNamer{}, "order", "order-events-123"},
+		{SlashNamer{}, "orders", "orders/123"},
+		{DotNamer{}, "orders", "orders.123"},
+	}
+
+	for _, test := range tests {
+		stream := test.namer.StreamName(test.typ, "123")
+		if stream != test.stream {
+			t.Errorf("expected %s, got %s", test.stream, stream)
+		}
+
+		typ, id, err := test.namer.ParseStreamName(stream)
+		if err != nil {
+			t.Errorf("%s: %s", stream, err)
+		}
+
+		if typ != test.typ || id != "123" {
+			t.Errorf("%s: unexpected type %q and id %q", stream, typ, id)
+		}
+
+		for _, bad := range []string{"", "orders", "/123", "orders.", "order-events-"} {
+			if _, _, err := test.namer.ParseStreamName(bad); err != ErrInvalidStreamName {
+				t.Errorf("%s: expected error parsing %q", stream, bad)
+			}
+		}
+	}
+}
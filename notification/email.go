@@ -0,0 +1,89 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/chop-dbhi/eda"
+)
+
+// sendMail is replaced in tests.
+var sendMail = smtp.SendMail
+
+// SMTPConfig configures the email notifier.
+type SMTPConfig struct {
+	// Addr is the host:port of the SMTP server.
+	Addr string
+
+	// Username and Password for plain authentication. Authentication is
+	// skipped if the username is empty.
+	Username string
+	Password string
+
+	// From is the sender address.
+	From string
+
+	// To are the recipient addresses.
+	To []string
+
+	// Format returns the subject and body of the email for the event.
+	// Defaults to a summary of the event.
+	Format func(evt *eda.Event) (subject, body string)
+}
+
+// formatEmail summarizes the event.
+func formatEmail(evt *eda.Event) (string, string) {
+	var body bytes.Buffer
+
+	fmt.Fprintf(&body, "Type: %s\n", evt.Type)
+	fmt.Fprintf(&body, "ID: %s\n", evt.ID)
+	fmt.Fprintf(&body, "Stream: %s\n", evt.Stream)
+	fmt.Fprintf(&body, "Time: %s\n", evt.Time)
+
+	if evt.Aggregate != "" {
+		fmt.Fprintf(&body, "Aggregate: %s\n", evt.Aggregate)
+	}
+
+	return "Event: " + evt.Type, body.String()
+}
+
+type emailNotifier struct {
+	cfg  SMTPConfig
+	auth smtp.Auth
+}
+
+func (n *emailNotifier) Notify(ctx context.Context, evt *eda.Event) error {
+	subject, body := n.cfg.Format(evt)
+
+	var msg bytes.Buffer
+
+	fmt.Fprintf(&msg, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "\r\n%s", body)
+
+	return sendMail(n.cfg.Addr, n.auth, n.cfg.From, n.cfg.To, msg.Bytes())
+}
+
+// NewEmailNotifier returns a notifier that sends an email for the event
+// using the SMTP server.
+func NewEmailNotifier(cfg SMTPConfig) Notifier {
+	if cfg.Format == nil {
+		cfg.Format = formatEmail
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		host, _, _ := net.SplitHostPort(cfg.Addr)
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+
+	return &emailNotifier{
+		cfg:  cfg,
+		auth: auth,
+	}
+}
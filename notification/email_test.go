@@ -0,0 +1,46 @@
+package notification
+
+import (
+	"context"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/chop-dbhi/eda"
+)
+
+func TestEmailNotifier(t *testing.T) {
+	var (
+		addr string
+		to   []string
+		msg  string
+	)
+
+	sendMail = func(a string, auth smtp.Auth, from string, t []string, m []byte) error {
+		addr = a
+		to = t
+		msg = string(m)
+		return nil
+	}
+	defer func() { sendMail = smtp.SendMail }()
+
+	n := NewEmailNotifier(SMTPConfig{
+		Addr: "localhost:25",
+		From: "eda@example.com",
+		To:   []string{"ops@example.com"},
+	})
+
+	if err := n.Notify(context.Background(), &eda.Event{ID: "1", Type: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if addr != "localhost:25" || len(to) != 1 || to[0] != "ops@example.com" {
+		t.Errorf("unexpected send: %s %v", addr, to)
+	}
+
+	for _, s := range []string{"Subject: Event: foo", "ID: 1"} {
+		if !strings.Contains(msg, s) {
+			t.Errorf("expected %q in message %q", s, msg)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Package notification dispatches events to notifiers, such as webhooks and
+email, after they are handled.
+
+	d := notification.NewDispatcher(notification.DispatcherOptions{
+		RetryPolicy: []time.Duration{time.Second, 5 * time.Second},
+	})
+
+	d.RegisterNotifier("PatientTestRecorded", notification.NewWebhookNotifier(url, nil))
+
+	sub, err := conn.Subscribe("patients", d.Handler(handle), nil)
+*/
+package notification
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/chop-dbhi/eda"
+)
+
+// Notifier sends a notification for an event.
+type Notifier interface {
+	Notify(ctx context.Context, evt *eda.Event) error
+}
+
+// NotifierFunc adapts a function to a Notifier.
+type NotifierFunc func(ctx context.Context, evt *eda.Event) error
+
+func (f NotifierFunc) Notify(ctx context.Context, evt *eda.Event) error {
+	return f(ctx, evt)
+}
+
+// DispatcherOptions are options for NewDispatcher.
+type DispatcherOptions struct {
+	// RetryPolicy is the sequence of durations to wait before retrying a
+	// failed notification. The number of retries is the length of the
+	// policy. If empty, notifications are not retried.
+	RetryPolicy []time.Duration
+}
+
+// Dispatcher routes events to the notifiers registered for their type.
+type Dispatcher struct {
+	opts DispatcherOptions
+
+	mux       sync.RWMutex
+	notifiers map[string][]Notifier
+}
+
+// RegisterNotifier adds a notifier for the event type.
+func (d *Dispatcher) RegisterNotifier(eventType string, n Notifier) {
+	d.mux.Lock()
+	d.notifiers[eventType] = append(d.notifiers[eventType], n)
+	d.mux.Unlock()
+}
+
+// notify calls the notifier, retrying on error according to the policy.
+func (d *Dispatcher) notify(ctx context.Context, n Notifier, evt *eda.Event) error {
+	err := n.Notify(ctx, evt)
+
+	for _, wait := range d.opts.RetryPolicy {
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+
+		err = n.Notify(ctx, evt)
+	}
+
+	return err
+}
+
+// Handle sends notifications for the event. The first error after retries
+// is returned once all notifiers have been called.
+func (d *Dispatcher) Handle(ctx context.Context, evt *eda.Event) error {
+	d.mux.RLock()
+	notifiers := d.notifiers[evt.Type]
+	d.mux.RUnlock()
+
+	var err error
+	for _, n := range notifiers {
+		if e := d.notify(ctx, n, evt); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// Handler returns a handler that calls the next handler and then sends
+// notifications for the event if it succeeded. The next handler may be nil.
+func (d *Dispatcher) Handler(next eda.Handler) eda.Handler {
+	return func(ctx context.Context, evt *eda.Event) error {
+		if next != nil {
+			if err := next(ctx, evt); err != nil {
+				return err
+			}
+		}
+
+		return d.Handle(ctx, evt)
+	}
+}
+
+// NewDispatcher returns a dispatcher without any notifiers.
+func NewDispatcher(opts DispatcherOptions) *Dispatcher {
+	return &Dispatcher{
+		opts:      opts,
+		notifiers: make(map[string][]Notifier),
+	}
+}
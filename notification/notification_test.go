@@ -0,0 +1,75 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chop-dbhi/eda"
+)
+
+func TestDispatcher(t *testing.T) {
+	d := NewDispatcher(DispatcherOptions{
+		RetryPolicy: []time.Duration{time.Millisecond, time.Millisecond},
+	})
+
+	var calls int
+
+	d.RegisterNotifier("PatientTestRecorded", NotifierFunc(func(ctx context.Context, evt *eda.Event) error {
+		calls++
+		if calls < 3 {
+			return errors.New("unavailable")
+		}
+		return nil
+	}))
+
+	var handled bool
+
+	handle := d.Handler(func(ctx context.Context, evt *eda.Event) error {
+		handled = true
+		return nil
+	})
+
+	if err := handle(context.Background(), &eda.Event{Type: "PatientTestRecorded"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !handled {
+		t.Error("expected next handler to be called")
+	}
+
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+
+	// No notifiers for the type.
+	if err := handle(context.Background(), &eda.Event{Type: "other"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestDispatcherRetriesExhausted(t *testing.T) {
+	d := NewDispatcher(DispatcherOptions{
+		RetryPolicy: []time.Duration{time.Millisecond},
+	})
+
+	var calls int
+
+	d.RegisterNotifier("foo", NotifierFunc(func(ctx context.Context, evt *eda.Event) error {
+		calls++
+		return errors.New("unavailable")
+	}))
+
+	if err := d.Handle(context.Background(), &eda.Event{Type: "foo"}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+}
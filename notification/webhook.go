@@ -0,0 +1,94 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chop-dbhi/eda"
+)
+
+// webhookPayload is the JSON body posted by the webhook notifier. JSON data
+// is embedded as is, other encodings are base64-encoded bytes.
+type webhookPayload struct {
+	ID        string            `json:"id"`
+	Stream    string            `json:"stream"`
+	Type      string            `json:"type"`
+	Time      time.Time         `json:"time"`
+	Cause     string            `json:"cause,omitempty"`
+	Aggregate string            `json:"aggregate,omitempty"`
+	Meta      map[string]string `json:"meta,omitempty"`
+	Encoding  string            `json:"encoding,omitempty"`
+	Data      interface{}       `json:"data,omitempty"`
+}
+
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, evt *eda.Event) error {
+	p := webhookPayload{
+		ID:        evt.ID,
+		Stream:    evt.Stream,
+		Type:      evt.Type,
+		Time:      evt.Time,
+		Cause:     evt.Cause,
+		Aggregate: evt.Aggregate,
+		Meta:      evt.Meta,
+	}
+
+	if evt.Data != nil {
+		b, err := evt.Data.Encode()
+		if err != nil {
+			return err
+		}
+
+		p.Encoding = evt.Data.Type()
+		if p.Encoding == "json" {
+			p.Data = json.RawMessage(b)
+		} else {
+			p.Data = b
+		}
+	}
+
+	body, err := json.Marshal(&p)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// NewWebhookNotifier returns a notifier that posts the event as JSON to the
+// URL. A non-2xx response is an error. If the client is nil,
+// http.DefaultClient is used.
+func NewWebhookNotifier(url string, client *http.Client) Notifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &webhookNotifier{
+		url:    url,
+		client: client,
+	}
+}
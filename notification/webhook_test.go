@@ -0,0 +1,53 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chop-dbhi/eda"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var body map[string]interface{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer ts.Close()
+
+	n := NewWebhookNotifier(ts.URL, nil)
+
+	err := n.Notify(context.Background(), &eda.Event{
+		ID:   "1",
+		Type: "foo",
+		Data: eda.JSON(map[string]string{"bar": "baz"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body["id"] != "1" || body["type"] != "foo" {
+		t.Errorf("unexpected body: %v", body)
+	}
+
+	data, _ := body["data"].(map[string]interface{})
+	if data["bar"] != "baz" {
+		t.Errorf("expected embedded JSON data, got %v", body["data"])
+	}
+}
+
+func TestWebhookNotifierStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	n := NewWebhookNotifier(ts.URL, nil)
+
+	if err := n.Notify(context.Background(), &eda.Event{}); err == nil {
+		t.Error("expected error for status 502")
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Package oteleda propagates OpenTelemetry trace context across streams by
+storing it in the meta of events. It is a separate package so the core
+library does not depend on OpenTelemetry.
+
+Publishers start a producer span and inject its context into the event:
+
+	id, err := oteleda.Publish(ctx, conn, "orders", evt)
+
+Subscribers extract the context and start a consumer span as a child of
+the producer span:
+
+	handle = eda.Chain(oteleda.SubscribeMiddleware())(handle)
+*/
+package oteleda
+
+import (
+	"context"
+
+	"github.com/chop-dbhi/eda"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/chop-dbhi/eda/oteleda"
+
+type config struct {
+	provider   trace.TracerProvider
+	propagator propagation.TextMapPropagator
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		provider:   otel.GetTracerProvider(),
+		propagator: otel.GetTextMapPropagator(),
+	}
+
+	for _, f := range opts {
+		f(c)
+	}
+
+	return c
+}
+
+func (c *config) tracer() trace.Tracer {
+	return c.provider.Tracer(instrumentationName)
+}
+
+// Option configures the tracer provider and propagator. The global ones are
+// used by default.
+type Option func(c *config)
+
+// WithTracerProvider sets the tracer provider used to start spans.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.provider = tp
+	}
+}
+
+// WithPropagator sets the propagator used to inject and extract the trace
+// context.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = p
+	}
+}
+
+// metaCarrier adapts event meta to a propagation.TextMapCarrier.
+type metaCarrier map[string]string
+
+func (c metaCarrier) Get(key string) string {
+	return c[key]
+}
+
+func (c metaCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c metaCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Inject stores the trace context of the context in the event meta.
+func Inject(ctx context.Context, evt *eda.Event, opts ...Option) {
+	if evt.Meta == nil {
+		evt.Meta = make(map[string]string)
+	}
+
+	newConfig(opts).propagator.Inject(ctx, metaCarrier(evt.Meta))
+}
+
+// Extract returns a context with the trace context stored in the event meta.
+func Extract(ctx context.Context, evt *eda.Event, opts ...Option) context.Context {
+	return newConfig(opts).propagator.Extract(ctx, metaCarrier(evt.Meta))
+}
+
+func attrs(stream string, evt *eda.Event) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "eda"),
+		attribute.String("messaging.destination.name", stream),
+		attribute.String("eda.event.type", evt.Type),
+	}
+}
+
+// Publish starts a producer span, injects its context into the event, and
+// publishes the event with the connection.
+func Publish(ctx context.Context, conn eda.Conn, stream string, evt *eda.Event, opts ...Option) (string, error) {
+	if evt == nil {
+		evt = &eda.Event{}
+	}
+
+	cfg := newConfig(opts)
+
+	ctx, span := cfg.tracer().Start(ctx, "publish "+stream,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attrs(stream, evt)...),
+	)
+	defer span.End()
+
+	if evt.Meta == nil {
+		evt.Meta = make(map[string]string)
+	}
+
+	cfg.propagator.Inject(ctx, metaCarrier(evt.Meta))
+
+	id, err := conn.Publish(stream, evt)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.SetAttributes(attribute.String("messaging.message.id", id))
+
+	return id, err
+}
+
+// SubscribeMiddleware extracts the trace context from received events and
+// starts a consumer span around the handler.
+func SubscribeMiddleware(opts ...Option) eda.MiddlewareFunc {
+	cfg := newConfig(opts)
+
+	return func(next eda.Handler) eda.Handler {
+		return func(ctx context.Context, evt *eda.Event) error {
+			ctx = cfg.propagator.Extract(ctx, metaCarrier(evt.Meta))
+
+			ctx, span := cfg.tracer().Start(ctx, "process "+evt.Stream,
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(attrs(evt.Stream, evt)...),
+				trace.WithAttributes(attribute.String("messaging.message.id", evt.ID)),
+			)
+			defer span.End()
+
+			err := next(ctx, evt)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+
+			return err
+		}
+	}
+}
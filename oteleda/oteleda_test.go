@@ -0,0 +1,80 @@
+package oteleda
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/chop-dbhi/eda"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type recordConn struct {
+	eda.Conn
+
+	published *eda.Event
+}
+
+func (c *recordConn) Publish(stream string, evt *eda.Event) (string, error) {
+	evt.Stream = stream
+	c.published = evt
+	return "1", nil
+}
+
+func TestPropagation(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+
+	opts := []Option{
+		WithTracerProvider(tp),
+		WithPropagator(propagation.TraceContext{}),
+	}
+
+	conn := &recordConn{}
+
+	if _, err := Publish(context.Background(), conn, "orders", &eda.Event{Type: "order-placed"}, opts...); err != nil {
+		t.Fatal(err)
+	}
+
+	if conn.published.Meta["traceparent"] == "" {
+		t.Fatalf("expected traceparent in meta: %v", conn.published.Meta)
+	}
+
+	var handlerSpan trace.SpanContext
+
+	handle := SubscribeMiddleware(opts...)(func(ctx context.Context, evt *eda.Event) error {
+		handlerSpan = trace.SpanContextFromContext(ctx)
+		return errors.New("failed")
+	})
+
+	if err := handle(context.Background(), conn.published); err == nil {
+		t.Fatal("expected handler error")
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	producer, consumer := spans[0], spans[1]
+
+	if consumer.SpanContext.TraceID() != producer.SpanContext.TraceID() {
+		t.Error("expected consumer span in the producer trace")
+	}
+
+	if consumer.Parent.SpanID() != producer.SpanContext.SpanID() {
+		t.Error("expected consumer span to be a child of the producer span")
+	}
+
+	if handlerSpan.SpanID() != consumer.SpanContext.SpanID() {
+		t.Error("expected handler context to contain the consumer span")
+	}
+
+	if consumer.Status.Code != codes.Error {
+		t.Errorf("expected error status, got %v", consumer.Status)
+	}
+}
@@ -0,0 +1,103 @@
+package projection
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Checkpoint persists the sequence of the last event projected by name.
+type Checkpoint interface {
+	// Get returns the sequence for the name or zero if none is set.
+	Get(ctx context.Context, name string) (uint64, error)
+
+	// Set sets the sequence for the name.
+	Set(ctx context.Context, name string, seq uint64) error
+}
+
+// MemCheckpoint stores sequences in memory.
+type MemCheckpoint struct {
+	mux  sync.RWMutex
+	seqs map[string]uint64
+}
+
+func (c *MemCheckpoint) Get(ctx context.Context, name string) (uint64, error) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	return c.seqs[name], nil
+}
+
+func (c *MemCheckpoint) Set(ctx context.Context, name string, seq uint64) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.seqs[name] = seq
+
+	return nil
+}
+
+// NewMemCheckpoint returns an empty in-memory checkpoint.
+func NewMemCheckpoint() *MemCheckpoint {
+	return &MemCheckpoint{
+		seqs: make(map[string]uint64),
+	}
+}
+
+// FileCheckpoint stores each sequence in a file in a directory.
+type FileCheckpoint struct {
+	dir string
+}
+
+func (c *FileCheckpoint) path(name string) string {
+	return filepath.Join(c.dir, url.PathEscape(name)+".checkpoint")
+}
+
+func (c *FileCheckpoint) Get(ctx context.Context, name string) (uint64, error) {
+	b, err := os.ReadFile(c.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// Set writes the sequence to a temporary file which replaces the previous
+// file, so a failed write does not lose the previous sequence.
+func (c *FileCheckpoint) Set(ctx context.Context, name string, seq uint64) error {
+	f, err := os.CreateTemp(c.dir, ".checkpoint-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(strconv.FormatUint(seq, 10)); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), c.path(name))
+}
+
+// NewFileCheckpoint returns a checkpoint that stores files in the
+// directory, which is created if it does not exist.
+func NewFileCheckpoint(dir string) (*FileCheckpoint, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileCheckpoint{dir: dir}, nil
+}
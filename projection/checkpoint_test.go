@@ -0,0 +1,51 @@
+package projection
+
+import (
+	"context"
+	"testing"
+)
+
+func testCheckpoint(t *testing.T, cp Checkpoint) {
+	ctx := context.Background()
+
+	seq, err := cp.Get(ctx, "orders")
+	if err != nil || seq != 0 {
+		t.Fatalf("expected zero sequence, got %d: %v", seq, err)
+	}
+
+	if err := cp.Set(ctx, "orders", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cp.Set(ctx, "orders", 12); err != nil {
+		t.Fatal(err)
+	}
+
+	seq, err = cp.Get(ctx, "orders")
+	if err != nil || seq != 12 {
+		t.Fatalf("expected sequence 12, got %d: %v", seq, err)
+	}
+}
+
+func TestMemCheckpoint(t *testing.T) {
+	testCheckpoint(t, NewMemCheckpoint())
+}
+
+func TestFileCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+
+	cp, err := NewFileCheckpoint(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCheckpoint(t, cp)
+
+	// A new checkpoint in the same directory resumes.
+	cp, _ = NewFileCheckpoint(dir)
+
+	seq, err := cp.Get(context.Background(), "orders")
+	if err != nil || seq != 12 {
+		t.Fatalf("expected sequence 12, got %d: %v", seq, err)
+	}
+}
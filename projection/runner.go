@@ -0,0 +1,176 @@
+/*
+Package projection maintains read models by reading the events of a
+stream into a projector and saving the progress in a checkpoint, so a
+restarted runner resumes from the last checkpoint.
+
+	runner := projection.NewRunner(conn, "orders", projector, checkpoint, projection.RunnerOptions{
+		Name: "order-summary",
+	})
+
+	err := runner.Run(ctx)
+
+The projectors in the sqlprojection package implement Projector.
+*/
+package projection
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/chop-dbhi/eda"
+)
+
+// Projector updates a read model from events.
+type Projector interface {
+	Handle(ctx context.Context, evt *eda.Event) error
+}
+
+// ProjectorFunc adapts a function to a Projector.
+type ProjectorFunc func(ctx context.Context, evt *eda.Event) error
+
+func (f ProjectorFunc) Handle(ctx context.Context, evt *eda.Event) error {
+	return f(ctx, evt)
+}
+
+// RunnerOptions are options for NewRunner.
+type RunnerOptions struct {
+	// Name of the checkpoint. Defaults to the stream name.
+	Name string
+
+	// CheckpointEvery is the number of events after which the checkpoint
+	// is saved. Defaults to 100.
+	CheckpointEvery int
+
+	// FlushInterval is the maximum time between saving the checkpoint
+	// while events are projected. Defaults to one second.
+	FlushInterval time.Duration
+
+	// PollInterval is the time Run waits to read new events once all events
+	// have been read. Defaults to one second.
+	PollInterval time.Duration
+}
+
+// Runner reads the events of a stream into a projector.
+type Runner struct {
+	conn       eda.Conn
+	stream     string
+	projector  Projector
+	checkpoint Checkpoint
+	opts       RunnerOptions
+}
+
+// RunOnce projects the events after the checkpoint up to the last event
+// in the stream and saves the checkpoint. If the projector returns an
+// error, the checkpoint is saved up to the previous event and the error
+// is returned.
+func (r *Runner) RunOnce(ctx context.Context) error {
+	seq, err := r.checkpoint.Get(ctx, r.opts.Name)
+	if err != nil {
+		return err
+	}
+
+	it, err := r.conn.Read(r.stream, &eda.ReadOptions{
+		From: eda.AtSeq(seq + 1),
+	})
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	var (
+		saved   = seq
+		flushed = time.Now()
+		pending int
+	)
+
+	flush := func() error {
+		if seq == saved {
+			return nil
+		}
+
+		// Use a fresh context so progress is saved on cancelation.
+		if err := r.checkpoint.Set(context.Background(), r.opts.Name, seq); err != nil {
+			return err
+		}
+
+		saved = seq
+		flushed = time.Now()
+		pending = 0
+
+		return nil
+	}
+
+	for {
+		evt, err := it.Next(ctx)
+		if err == io.EOF {
+			return flush()
+		}
+		if err != nil {
+			return errors.Join(err, flush())
+		}
+
+		if err := r.projector.Handle(ctx, evt); err != nil {
+			return errors.Join(err, flush())
+		}
+
+		seq = evt.Seq
+		pending++
+
+		if pending >= r.opts.CheckpointEvery || time.Since(flushed) >= r.opts.FlushInterval {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Run projects events until the context is done, reading new events
+// every poll interval. It returns nil once the context is done, or the
+// first error of the projector or checkpoint.
+func (r *Runner) Run(ctx context.Context) error {
+	for {
+		err := r.RunOnce(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.opts.PollInterval):
+		}
+	}
+}
+
+// NewRunner returns a runner that projects the events of the stream and
+// saves its progress in the checkpoint.
+func NewRunner(conn eda.Conn, stream string, p Projector, cp Checkpoint, opts RunnerOptions) *Runner {
+	if opts.Name == "" {
+		opts.Name = stream
+	}
+
+	if opts.CheckpointEvery <= 0 {
+		opts.CheckpointEvery = 100
+	}
+
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+
+	return &Runner{
+		conn:       conn,
+		stream:     stream,
+		projector:  p,
+		checkpoint: cp,
+		opts:       opts,
+	}
+}
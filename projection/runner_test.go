@@ -0,0 +1,104 @@
+package projection
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chop-dbhi/eda"
+	"github.com/chop-dbhi/eda/edatest"
+)
+
+// publish publishes an event of each type to conn.
+func publish(conn *edatest.MemConn, types ...string) {
+	for _, typ := range types {
+		conn.Publish("orders", &eda.Event{Type: typ})
+	}
+}
+
+func TestRunner(t *testing.T) {
+	conn := &edatest.MemConn{}
+	publish(conn, "a", "b", "fail", "c")
+
+	var projected string
+
+	p := ProjectorFunc(func(ctx context.Context, evt *eda.Event) error {
+		if evt.Type == "fail" {
+			return errors.New("projection failed")
+		}
+		projected += evt.Type
+		return nil
+	})
+
+	cp := NewMemCheckpoint()
+	ctx := context.Background()
+
+	runner := NewRunner(conn, "orders", p, cp, RunnerOptions{})
+
+	if err := runner.RunOnce(ctx); err == nil {
+		t.Fatal("expected projection error")
+	}
+
+	if seq, _ := cp.Get(ctx, "orders"); seq != 2 {
+		t.Fatalf("expected checkpoint at 2, got %d", seq)
+	}
+
+	// Skip the failed event.
+	conn.Events[2].Type = "skip"
+	publish(conn, "d")
+
+	if err := runner.RunOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if projected != "abskipcd" {
+		t.Errorf("unexpected events projected: %s", projected)
+	}
+
+	if seq, _ := cp.Get(ctx, "orders"); seq != 5 {
+		t.Errorf("expected checkpoint at 5, got %d", seq)
+	}
+}
+
+// countingCheckpoint counts the calls to Set.
+type countingCheckpoint struct {
+	*MemCheckpoint
+	sets int
+}
+
+func (c *countingCheckpoint) Set(ctx context.Context, name string, seq uint64) error {
+	c.sets++
+	return c.MemCheckpoint.Set(ctx, name, seq)
+}
+
+func TestRunnerCheckpointEvery(t *testing.T) {
+	conn := &edatest.MemConn{}
+	publish(conn, "a", "b", "c", "d", "e")
+
+	cp := &countingCheckpoint{MemCheckpoint: NewMemCheckpoint()}
+
+	runner := NewRunner(conn, "orders", ProjectorFunc(func(context.Context, *eda.Event) error {
+		return nil
+	}), cp, RunnerOptions{
+		Name:            "summary",
+		CheckpointEvery: 2,
+		FlushInterval:   time.Hour,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := runner.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Saved after 2 and 4 events and at the end of the stream.
+	if cp.sets != 3 {
+		t.Errorf("expected 3 checkpoint saves, got %d", cp.sets)
+	}
+
+	if seq, _ := cp.Get(context.Background(), "summary"); seq != 5 {
+		t.Errorf("expected checkpoint at 5, got %d", seq)
+	}
+}
@@ -0,0 +1,150 @@
+package eda
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrReadNotSupported is returned by Read for backends that cannot read
+// the events stored in a stream.
+var ErrReadNotSupported = errors.New("reading stored events not supported")
+
+// ErrSeqNotSupported is returned by Read and Subscribe for backends whose
+// events do not have sequences, if a sequence is passed.
+var ErrSeqNotSupported = errors.New("sequences are not supported")
+
+// ReadPosition is a position in a stream given by a sequence or a time.
+// If both are set, the sequence is used.
+type ReadPosition struct {
+	Seq  uint64
+	Time time.Time
+}
+
+// IsZero returns true if neither the sequence nor the time is set.
+func (p ReadPosition) IsZero() bool {
+	return p.Seq == 0 && p.Time.IsZero()
+}
+
+// AtSeq returns the position of the event with the sequence.
+func AtSeq(seq uint64) ReadPosition {
+	return ReadPosition{Seq: seq}
+}
+
+// AtTime returns the position of the first event acknowledged by the
+// server at or after the time.
+func AtTime(t time.Time) ReadPosition {
+	return ReadPosition{Time: t}
+}
+
+// ReadOptions are options for Conn.Read.
+type ReadOptions struct {
+	// From is the position of the first event to read. Defaults to the
+	// start of the stream.
+	From ReadPosition
+
+	// To is the position of the last event to read, inclusive. Defaults
+	// to the last event in the stream at the time of the read.
+	To ReadPosition
+
+	// MaxEvents is the maximum number of events to read. Zero means no
+	// limit.
+	MaxEvents int
+
+	// Types limits the events returned to these types. Events of other
+	// types are skipped and do not count towards MaxEvents.
+	Types []string
+}
+
+// EventIterator reads events from a stream in order.
+type EventIterator interface {
+	// Next returns the next event. It returns io.EOF once the events have
+	// been read.
+	Next(ctx context.Context) (*Event, error)
+
+	// Close releases the resources of the iterator.
+	Close() error
+}
+
+type eventIterator struct {
+	next  func(ctx context.Context) (*Event, error)
+	close func() error
+	opts  ReadOptions
+	n     int
+	done  bool
+}
+
+func (i *eventIterator) Next(ctx context.Context) (*Event, error) {
+	for {
+		if i.done || (i.opts.MaxEvents > 0 && i.n >= i.opts.MaxEvents) {
+			return nil, io.EOF
+		}
+
+		evt, err := i.next(ctx)
+		if err == io.EOF {
+			i.done = true
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !i.opts.To.Time.IsZero() && i.opts.To.Seq == 0 && evt.AckTime.After(i.opts.To.Time) {
+			i.done = true
+			return nil, io.EOF
+		}
+
+		if len(i.opts.Types) > 0 && !evt.Is(i.opts.Types...) {
+			continue
+		}
+
+		i.n++
+
+		return evt, nil
+	}
+}
+
+func (i *eventIterator) Close() error {
+	i.done = true
+
+	if i.close != nil {
+		return i.close()
+	}
+
+	return nil
+}
+
+// NewEventIterator returns an EventIterator that applies the To time,
+// MaxEvents, and Types options to the events returned by next, which
+// returns io.EOF after the last event in the range. Backends implement
+// the From and To sequence options. This can be used to implement
+// Conn.Read for other backends.
+func NewEventIterator(next func(ctx context.Context) (*Event, error), close func() error, opts *ReadOptions) EventIterator {
+	it := &eventIterator{
+		next:  next,
+		close: close,
+	}
+
+	if opts != nil {
+		it.opts = *opts
+	}
+
+	return it
+}
+
+// lastReadSeq returns the sequence of the last event to read given the
+// last sequence in the stream.
+func lastReadSeq(opts *ReadOptions, last uint64) uint64 {
+	if opts.To.Seq > 0 && opts.To.Seq < last {
+		return opts.To.Seq
+	}
+
+	return last
+}
+
+// emptyEventIterator returns an EventIterator without events.
+func emptyEventIterator(opts *ReadOptions) EventIterator {
+	return NewEventIterator(func(context.Context) (*Event, error) {
+		return nil, io.EOF
+	}, nil, opts)
+}
@@ -0,0 +1,173 @@
+package eda
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nuid"
+)
+
+func TestEventIterator(t *testing.T) {
+	evts := []*Event{
+		{Type: "a"},
+		{Type: "b"},
+		{Type: "a"},
+		{Type: "a"},
+	}
+
+	var closed bool
+
+	it := NewEventIterator(func(ctx context.Context) (*Event, error) {
+		if len(evts) == 0 {
+			return nil, io.EOF
+		}
+		evt := evts[0]
+		evts = evts[1:]
+		return evt, nil
+	}, func() error {
+		closed = true
+		return nil
+	}, &ReadOptions{
+		MaxEvents: 2,
+		Types:     []string{"a"},
+	})
+
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		evt, err := it.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if evt.Type != "a" {
+			t.Errorf("expected type a, got %s", evt.Type)
+		}
+	}
+
+	if _, err := it.Next(ctx); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	if len(evts) != 1 {
+		t.Errorf("expected 1 unread event, got %d", len(evts))
+	}
+
+	it.Close()
+
+	if !closed {
+		t.Error("expected close to be called")
+	}
+}
+
+// testRead publishes events to a new stream and reads ranges of them.
+func testRead(t *testing.T, conn Conn) {
+	stream := "read-" + nuid.Next()
+
+	for _, typ := range []string{"a", "b", "a", "c", "a"} {
+		if _, err := conn.Publish(stream, &Event{Type: typ}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	readAll := func(opts *ReadOptions) []string {
+		it, err := conn.Read(stream, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer it.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var types []string
+		for {
+			evt, err := it.Next(ctx)
+			if err == io.EOF {
+				return types
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if evt.Stream != stream {
+				t.Errorf("expected stream %s, got %s", stream, evt.Stream)
+			}
+			if evt.Seq == 0 {
+				t.Error("expected sequence to be set")
+			}
+			types = append(types, evt.Type)
+		}
+	}
+
+	tests := map[string]struct {
+		opts *ReadOptions
+		exp  string
+	}{
+		"all":   {nil, "abaca"},
+		"range": {&ReadOptions{From: AtSeq(2), To: AtSeq(4)}, "bac"},
+		"max":   {&ReadOptions{MaxEvents: 2}, "ab"},
+		"types": {&ReadOptions{Types: []string{"a"}}, "aaa"},
+		"past":  {&ReadOptions{From: AtSeq(10)}, ""},
+	}
+
+	for name, test := range tests {
+		var got string
+		for _, typ := range readAll(test.opts) {
+			got += typ
+		}
+
+		if got != test.exp {
+			t.Errorf("%s: expected %q, got %q", name, test.exp, got)
+		}
+	}
+
+	// Events published after the read started are not read.
+	it, err := conn.Read(stream, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	if _, err := conn.Publish(stream, &Event{Type: "d"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	for {
+		_, err := it.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+
+	if n != 5 {
+		t.Errorf("expected 5 events, got %d", n)
+	}
+
+	// A stream without events reads as empty.
+	it, err = conn.Read("read-"+nuid.Next(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	if _, err := it.Next(context.Background()); err != io.EOF {
+		t.Errorf("expected EOF for unpublished stream, got %v", err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	conn, err := Connect(addr, cluster, client, WithMonitorAddr(monitor))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	testRead(t, conn)
+}
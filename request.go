@@ -0,0 +1,162 @@
+package eda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ReplyMetaKey is the event meta key of the subject replies to a request
+// are sent to.
+const ReplyMetaKey = "reply_to"
+
+const (
+	replyCodeMetaKey    = "reply.code"
+	replyMessageMetaKey = "reply.message"
+)
+
+var (
+	// ErrNoReplyExpected is returned by Event.Reply if the event was not
+	// published with Request.
+	ErrNoReplyExpected = errors.New("event does not expect a reply")
+
+	// ErrRequestNotSupported is returned by Request for backends without
+	// request-reply support.
+	ErrRequestNotSupported = errors.New("request-reply not supported")
+)
+
+// Code is the status of a reply.
+type Code int
+
+const (
+	CodeOK Code = iota
+	CodeError
+	CodeInvalid
+	CodeNotFound
+	CodeUnavailable
+)
+
+var codeNames = map[Code]string{
+	CodeOK:          "ok",
+	CodeError:       "error",
+	CodeInvalid:     "invalid",
+	CodeNotFound:    "not found",
+	CodeUnavailable: "unavailable",
+}
+
+func (c Code) String() string {
+	if s, ok := codeNames[c]; ok {
+		return s
+	}
+
+	return "code " + strconv.Itoa(int(c))
+}
+
+// Reply is the reply to a request.
+type Reply struct {
+	Code Code
+
+	// Message describes the reason for a code other than CodeOK.
+	Message string
+
+	// Data is the reply data.
+	Data Data
+}
+
+// Err returns an *ErrReply if the code is not CodeOK.
+func (r *Reply) Err() error {
+	if r.Code == CodeOK {
+		return nil
+	}
+
+	return &ErrReply{
+		Code:    r.Code,
+		Message: r.Message,
+	}
+}
+
+// ErrReply is the error of a reply with a code other than CodeOK.
+type ErrReply struct {
+	Code    Code
+	Message string
+}
+
+func (e *ErrReply) Error() string {
+	if e.Message == "" {
+		return e.Code.String()
+	}
+
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Reply sends the reply to the requester of the event. It returns
+// ErrNoReplyExpected if the event was not published with Request.
+func (e *Event) Reply(r *Reply) error {
+	if e.reply == nil {
+		return ErrNoReplyExpected
+	}
+
+	return e.reply(r)
+}
+
+// encodeReply encodes the reply with the event envelope.
+func encodeReply(r *Reply) ([]byte, error) {
+	meta := map[string]string{
+		replyCodeMetaKey: strconv.Itoa(int(r.Code)),
+	}
+
+	if r.Message != "" {
+		meta[replyMessageMetaKey] = r.Message
+	}
+
+	return MarshalEvent(&Event{
+		Data: r.Data,
+		Meta: meta,
+	})
+}
+
+func decodeReply(b []byte) (*Reply, error) {
+	evt, err := UnmarshalEvent(b)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := strconv.Atoi(evt.Meta[replyCodeMetaKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid reply code: %w", err)
+	}
+
+	return &Reply{
+		Code:    Code(code),
+		Message: evt.Meta[replyMessageMetaKey],
+		Data:    evt.Data,
+	}, nil
+}
+
+// request publishes the event with the inbox as the reply subject and
+// waits for the reply on the channel or until the context is done.
+func (c *baseConn) request(ctx context.Context, stream string, evt *Event, inbox string, replies <-chan []byte) (*Reply, error) {
+	if evt == nil {
+		evt = &Event{}
+	}
+
+	// Copy to not modify the caller's event.
+	e := *evt
+	e.Meta = make(map[string]string, len(evt.Meta)+1)
+	for k, v := range evt.Meta {
+		e.Meta[k] = v
+	}
+	e.Meta[ReplyMetaKey] = inbox
+
+	if _, err := c.publish(stream, &e); err != nil {
+		return nil, err
+	}
+
+	select {
+	case b := <-replies:
+		return decodeReply(b)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,113 @@
+package eda
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// replyHandler replies to "echo" requests with the data, and to other
+// requests with an invalid reply.
+func replyHandler(ctx context.Context, evt *Event) error {
+	if evt.Type != "echo" {
+		return evt.Reply(&Reply{
+			Code:    CodeInvalid,
+			Message: "unknown command " + evt.Type,
+		})
+	}
+
+	var v string
+	if err := evt.Data.Decode(&v); err != nil {
+		return err
+	}
+
+	return evt.Reply(&Reply{Data: String(v)})
+}
+
+// testRequest tests the reply paths of Request on the connection.
+func testRequest(t *testing.T, conn Conn) {
+	stream := stream + "-request"
+
+	sub, err := conn.Subscribe(stream, replyHandler, &SubscriptionOptions{
+		Name: "replier",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r, err := conn.Request(ctx, stream, &Event{Type: "echo", Data: String("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v string
+	if err := r.Data.Decode(&v); err != nil || v != "hello" {
+		t.Errorf("expected hello, got %q: %v", v, err)
+	}
+
+	if r.Err() != nil {
+		t.Errorf("expected no error, got %s", r.Err())
+	}
+
+	r, err = conn.Request(ctx, stream, &Event{Type: "unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rerr *ErrReply
+	if !errors.As(r.Err(), &rerr) || rerr.Code != CodeInvalid || rerr.Message != "unknown command unknown" {
+		t.Errorf("unexpected reply error: %v", r.Err())
+	}
+
+	// No handler on the stream.
+	tctx, tcancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer tcancel()
+
+	if _, err := conn.Request(tctx, stream+"-none", &Event{Type: "echo"}); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestRequest(t *testing.T) {
+	conn, err := Connect(addr, cluster, client)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	testRequest(t, conn)
+}
+
+func TestReplyNotExpected(t *testing.T) {
+	evt := &Event{}
+
+	if err := evt.Reply(&Reply{}); err != ErrNoReplyExpected {
+		t.Errorf("expected ErrNoReplyExpected, got %v", err)
+	}
+}
+
+func TestReplyEncoding(t *testing.T) {
+	b, err := encodeReply(&Reply{Code: CodeNotFound, Message: "missing", Data: String("x")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := decodeReply(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Code != CodeNotFound || r.Message != "missing" {
+		t.Errorf("unexpected reply: %+v", r)
+	}
+
+	if s := r.Err().Error(); s != "not found: missing" {
+		t.Errorf("unexpected error message: %s", s)
+	}
+}
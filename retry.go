@@ -0,0 +1,386 @@
+package eda
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nuid"
+)
+
+// RetryStore persists events that failed to publish so they can be retried.
+type RetryStore interface {
+	// Enqueue adds the event to the end of the queue. The event ID is
+	// used as the key for acknowledging the event.
+	Enqueue(evt *Event) error
+
+	// Dequeue returns up to n events from the front of the queue. Events
+	// remain in the store until they are acknowledged.
+	Dequeue(n int) ([]*Event, error)
+
+	// Ack removes the event from the queue.
+	Ack(id string) error
+}
+
+// RetryOptions are options for NewRetryingConn.
+type RetryOptions struct {
+	// Interval is the time between checks of the store for events to retry.
+	// Defaults to one second.
+	Interval time.Duration
+
+	// BatchSize is the maximum number of events retried per check.
+	// Defaults to 100.
+	BatchSize int
+
+	// RetryPolicy is the sequence of durations to back off after
+	// consecutive failed retries. The last duration is used for all
+	// subsequent failures. If empty, the interval is used.
+	RetryPolicy []time.Duration
+
+	// MaxAttempts is the number of failed retries after which an event is
+	// removed from the queue, publishing it to DeadLetterStream if set. If
+	// zero, events are retried until they are published. Attempts are
+	// counted by the connection, so they restart with a new connection.
+	MaxAttempts int
+
+	// DeadLetterStream is the stream events are published to when they are
+	// removed after MaxAttempts or fail with an error that will not succeed
+	// on retry. If empty, those events are dropped and logged.
+	DeadLetterStream string
+
+	// Logger for retry failures. Defaults to slog.Default.
+	Logger *slog.Logger
+}
+
+// errNilConn is returned when publishing with a retrying connection that
+// wraps a nil connection.
+var errNilConn = errors.New("retry: nil connection")
+
+// permanentError returns true if publishing failed for a reason that will
+// not change on retry, such as an invalid event.
+func permanentError(err error) bool {
+	var (
+		mf *ErrMissingField
+		ee *EncodeError
+	)
+
+	return errors.As(err, &mf) || errors.As(err, &ee) || errors.Is(err, errNilConn)
+}
+
+type retryingConn struct {
+	Conn
+
+	store  RetryStore
+	opts   RetryOptions
+	logger *slog.Logger
+
+	close chan struct{}
+	done  chan struct{}
+	once  sync.Once
+
+	// Number of queued events by stream. New events on a stream with
+	// queued events are queued behind them so they are not reordered.
+	mux     sync.Mutex
+	pending map[string]int
+
+	// Failed retries by event ID. Only accessed by the retry loop.
+	attempts map[string]int
+}
+
+// Publish publishes the event to the underlying connection. If that fails
+// with a transport error, or earlier events on the stream are still queued,
+// the event is added to the retry store and no error is returned. Since the
+// event ID is assigned when the event is finally published, an empty ID is
+// returned in this case. Errors that will not succeed on retry, such as a
+// missing required field or data that fails to encode, are returned as is.
+func (c *retryingConn) Publish(stream string, evt *Event) (string, error) {
+	if c.Conn == nil {
+		return "", errNilConn
+	}
+
+	c.mux.Lock()
+	queued := c.pending[stream] > 0
+	c.mux.Unlock()
+
+	var err error
+
+	if !queued {
+		var id string
+		if id, err = c.Conn.Publish(stream, evt); err == nil || permanentError(err) {
+			return id, err
+		}
+	}
+
+	// Copy to not modify the caller's event.
+	var e Event
+	if evt != nil {
+		e = *evt
+	}
+
+	e.Stream = stream
+	if e.ID == "" {
+		e.ID = nuid.Next()
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if serr := c.store.Enqueue(&e); serr != nil {
+		c.logger.Error("retry enqueue failed",
+			slog.String("stream", stream),
+			slog.Any("publish_error", err),
+			slog.Any("error", serr),
+		)
+		if err == nil {
+			err = serr
+		}
+		return "", err
+	}
+
+	c.pending[stream]++
+
+	if err != nil {
+		c.logger.Warn("publish failed, queued for retry",
+			slog.String("stream", stream),
+			slog.String("retry_id", e.ID),
+			slog.Any("error", err),
+		)
+	}
+
+	return "", nil
+}
+
+// PublishBatch publishes each event with Publish, so failed events are
+// queued for retry rather than returned in a BatchPublishError.
+func (c *retryingConn) PublishBatch(stream string, evts []*Event) ([]string, error) {
+	return PublishEach(c.Publish, stream, evts)
+}
+
+// dequeued removes the event from the pending count of its stream once it
+// is acked.
+func (c *retryingConn) dequeued(evt *Event) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.pending[evt.Stream]--; c.pending[evt.Stream] <= 0 {
+		delete(c.pending, evt.Stream)
+	}
+	delete(c.attempts, evt.ID)
+}
+
+// discard publishes the event that will not be retried to the dead letter
+// stream, or logs it if there is none.
+func (c *retryingConn) discard(evt *Event, err error, attempts int) error {
+	attrs := []any{
+		slog.String("stream", evt.Stream),
+		slog.String("retry_id", evt.ID),
+		slog.Int("attempts", attempts),
+		slog.Any("error", err),
+	}
+
+	if c.opts.DeadLetterStream == "" {
+		c.logger.Error("retry dropped event", attrs...)
+		return nil
+	}
+
+	meta := make(map[string]string, len(evt.Meta)+3)
+	for k, v := range evt.Meta {
+		meta[k] = v
+	}
+
+	meta["dlq.original_stream"] = evt.Stream
+	meta["dlq.error"] = err.Error()
+	meta["dlq.attempt_count"] = strconv.Itoa(attempts)
+
+	e := *evt
+	e.Meta = meta
+	e.Stream = ""
+
+	if _, derr := c.Conn.Publish(c.opts.DeadLetterStream, &e); derr != nil {
+		return derr
+	}
+
+	c.logger.Warn("retry dead lettered event", attrs...)
+
+	return nil
+}
+
+// retry publishes queued events in order, stopping at the first failure
+// so events on a stream are not reordered. Events that fail with an error
+// that will not succeed on retry, or that reach MaxAttempts, are discarded.
+// It returns false if an event failed to publish.
+func (c *retryingConn) retry() bool {
+	evts, err := c.store.Dequeue(c.opts.BatchSize)
+	if err != nil {
+		c.logger.Error("retry dequeue failed", slog.Any("error", err))
+		return false
+	}
+
+	for _, evt := range evts {
+		key := evt.ID
+
+		// The connection assigns a new ID on publish.
+		if _, err := c.Conn.Publish(evt.Stream, evt); err != nil {
+			c.attempts[key]++
+			n := c.attempts[key]
+
+			if !permanentError(err) && (c.opts.MaxAttempts <= 0 || n < c.opts.MaxAttempts) {
+				c.logger.Warn("retry publish failed",
+					slog.String("stream", evt.Stream),
+					slog.String("retry_id", key),
+					slog.Any("error", err),
+				)
+				return false
+			}
+
+			if derr := c.discard(evt, err, n); derr != nil {
+				c.logger.Error("retry dead letter publish failed",
+					slog.String("stream", evt.Stream),
+					slog.String("retry_id", key),
+					slog.Any("error", derr),
+				)
+				return false
+			}
+		}
+
+		if err := c.store.Ack(key); err != nil {
+			c.logger.Error("retry ack failed",
+				slog.String("stream", evt.Stream),
+				slog.String("retry_id", key),
+				slog.Any("error", err),
+			)
+			return false
+		}
+
+		c.dequeued(evt)
+	}
+
+	return true
+}
+
+func (c *retryingConn) run() {
+	defer close(c.done)
+
+	var failures int
+
+	for {
+		wait := c.opts.Interval
+		if failures > 0 && len(c.opts.RetryPolicy) > 0 {
+			wait = backoff(c.opts.RetryPolicy, failures)
+		}
+
+		select {
+		case <-c.close:
+			return
+		case <-time.After(wait):
+		}
+
+		if c.retry() {
+			failures = 0
+		} else {
+			failures++
+		}
+	}
+}
+
+// Close stops retrying and closes the underlying connection. Events
+// remaining in the store are retried by the next connection using it.
+func (c *retryingConn) Close() error {
+	c.once.Do(func() {
+		close(c.close)
+	})
+	<-c.done
+
+	return c.Conn.Close()
+}
+
+// NewRetryingConn wraps a connection so events that fail to publish are
+// persisted to the store and retried in the background rather than lost.
+func NewRetryingConn(c Conn, store RetryStore, opts RetryOptions) Conn {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	rc := &retryingConn{
+		Conn:     c,
+		store:    store,
+		opts:     opts,
+		logger:   logger,
+		close:    make(chan struct{}),
+		done:     make(chan struct{}),
+		pending:  make(map[string]int),
+		attempts: make(map[string]int),
+	}
+
+	// Count the events queued by previous connections so new events on
+	// those streams are queued behind them.
+	evts, err := store.Dequeue(math.MaxInt32)
+	if err != nil {
+		logger.Error("retry dequeue failed", slog.Any("error", err))
+	}
+	for _, evt := range evts {
+		rc.pending[evt.Stream]++
+	}
+
+	go rc.run()
+
+	return rc
+}
+
+type memRetryStore struct {
+	mux  sync.Mutex
+	evts []*Event
+}
+
+func (s *memRetryStore) Enqueue(evt *Event) error {
+	s.mux.Lock()
+	s.evts = append(s.evts, evt)
+	s.mux.Unlock()
+	return nil
+}
+
+func (s *memRetryStore) Dequeue(n int) ([]*Event, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if n > len(s.evts) {
+		n = len(s.evts)
+	}
+
+	evts := make([]*Event, n)
+	copy(evts, s.evts)
+
+	return evts, nil
+}
+
+func (s *memRetryStore) Ack(id string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for i, evt := range s.evts {
+		if evt.ID == id {
+			s.evts = append(s.evts[:i], s.evts[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// NewMemRetryStore returns an in-memory retry store. Queued events are
+// lost if the process exits.
+func NewMemRetryStore() RetryStore {
+	return &memRetryStore{}
+}
@@ -0,0 +1,231 @@
+package eda
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyConn fails to publish while down is set, on the rejected stream,
+// or with err if set.
+type flakyConn struct {
+	Conn
+
+	mux          sync.Mutex
+	down         bool
+	rejectStream string
+	err          error
+	published    []*Event
+}
+
+func (c *flakyConn) setDown(down bool) {
+	c.mux.Lock()
+	c.down = down
+	c.mux.Unlock()
+}
+
+func (c *flakyConn) Publish(stream string, evt *Event) (string, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.err != nil {
+		return "", c.err
+	}
+
+	if c.down || stream == c.rejectStream {
+		return "", errors.New("connection lost")
+	}
+
+	c.published = append(c.published, evt)
+	return "ok", nil
+}
+
+func (c *flakyConn) Close() error {
+	return nil
+}
+
+func TestRetryingConn(t *testing.T) {
+	fc := &flakyConn{down: true}
+	store := NewMemRetryStore()
+
+	c := NewRetryingConn(fc, store, RetryOptions{
+		Interval: 5 * time.Millisecond,
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	defer c.Close()
+
+	for _, typ := range []string{"a", "b"} {
+		id, err := c.Publish(stream, &Event{Type: typ})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != "" {
+			t.Errorf("expected empty id, got %q", id)
+		}
+	}
+
+	evts, _ := store.Dequeue(10)
+	if len(evts) != 2 {
+		t.Fatalf("expected 2 queued events, got %d", len(evts))
+	}
+
+	fc.setDown(false)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		evts, _ = store.Dequeue(10)
+		if len(evts) == 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if len(evts) != 0 {
+		t.Fatalf("expected empty store, got %d events", len(evts))
+	}
+
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+
+	if len(fc.published) != 2 {
+		t.Fatalf("expected 2 published events, got %d", len(fc.published))
+	}
+
+	for i, typ := range []string{"a", "b"} {
+		evt := fc.published[i]
+		if evt.Type != typ || evt.Stream != stream {
+			t.Errorf("unexpected event %d: %s on %s", i, evt.Type, evt.Stream)
+		}
+	}
+}
+
+func TestMemRetryStore(t *testing.T) {
+	s := NewMemRetryStore()
+
+	s.Enqueue(&Event{ID: "1"})
+	s.Enqueue(&Event{ID: "2"})
+	s.Enqueue(&Event{ID: "3"})
+
+	evts, _ := s.Dequeue(2)
+	if len(evts) != 2 || evts[0].ID != "1" || evts[1].ID != "2" {
+		t.Fatalf("unexpected dequeue: %v", evts)
+	}
+
+	s.Ack("1")
+
+	evts, _ = s.Dequeue(5)
+	if len(evts) != 2 || evts[0].ID != "2" || evts[1].ID != "3" {
+		t.Fatalf("unexpected dequeue after ack: %v", evts)
+	}
+}
+
+func TestRetryingConnPermanentError(t *testing.T) {
+	fc := &flakyConn{}
+	store := NewMemRetryStore()
+
+	c := NewRetryingConn(fc, store, RetryOptions{
+		Interval: time.Hour,
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}).(*retryingConn)
+	defer c.Close()
+
+	encodeErr := &EncodeError{Err: errors.New("bad data")}
+
+	for _, err := range []error{&ErrMissingField{Field: "type"}, encodeErr} {
+		fc.err = err
+
+		if _, perr := c.Publish(stream, &Event{Type: "a"}); !errors.Is(perr, err) {
+			t.Errorf("expected %v, got %v", err, perr)
+		}
+	}
+
+	if evts, _ := store.Dequeue(10); len(evts) != 0 {
+		t.Errorf("expected invalid events not to be queued, got %d", len(evts))
+	}
+
+	if _, err := NewRetryingConn(nil, store, RetryOptions{}).Publish(stream, &Event{}); err != errNilConn {
+		t.Errorf("expected errNilConn, got %v", err)
+	}
+}
+
+func TestRetryingConnOrder(t *testing.T) {
+	fc := &flakyConn{down: true}
+	store := NewMemRetryStore()
+
+	c := NewRetryingConn(fc, store, RetryOptions{
+		Interval: time.Hour,
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}).(*retryingConn)
+	defer c.Close()
+
+	c.Publish(stream, &Event{Type: "a"})
+
+	fc.setDown(false)
+
+	// Events on a stream with queued events are queued behind them, while
+	// other streams are published directly.
+	if id, err := c.Publish(stream, &Event{Type: "b"}); id != "" || err != nil {
+		t.Fatalf("expected b to be queued, got %q: %v", id, err)
+	}
+
+	if id, err := c.Publish("other", &Event{Type: "c"}); id != "ok" || err != nil {
+		t.Fatalf("expected c to be published, got %q: %v", id, err)
+	}
+
+	if !c.retry() {
+		t.Fatal("expected retry to succeed")
+	}
+
+	var types []string
+	for _, evt := range fc.published {
+		types = append(types, evt.Type)
+	}
+
+	if len(types) != 3 || types[0] != "c" || types[1] != "a" || types[2] != "b" {
+		t.Errorf("unexpected publish order: %v", types)
+	}
+
+	if id, _ := c.Publish(stream, &Event{Type: "d"}); id != "ok" {
+		t.Errorf("expected d to be published once the queue is empty, got %q", id)
+	}
+}
+
+func TestRetryingConnMaxAttempts(t *testing.T) {
+	fc := &flakyConn{down: true}
+	store := NewMemRetryStore()
+
+	c := NewRetryingConn(fc, store, RetryOptions{
+		Interval:         time.Hour,
+		MaxAttempts:      2,
+		DeadLetterStream: "dead",
+		Logger:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}).(*retryingConn)
+	defer c.Close()
+
+	c.Publish(stream, &Event{Type: "a"})
+
+	if c.retry() {
+		t.Fatal("expected first retry to fail")
+	}
+
+	// The event is dead lettered once the stream accepts it.
+	fc.mux.Lock()
+	fc.down = false
+	fc.rejectStream = stream
+	fc.mux.Unlock()
+
+	if !c.retry() {
+		t.Fatal("expected event to be dead lettered")
+	}
+
+	if evts, _ := store.Dequeue(10); len(evts) != 0 {
+		t.Errorf("expected empty store, got %d events", len(evts))
+	}
+
+	if len(fc.published) != 1 || fc.published[0].Meta["dlq.original_stream"] != stream || fc.published[0].Meta["dlq.attempt_count"] != "2" {
+		t.Errorf("unexpected dead letter events: %v", fc.published)
+	}
+}
@@ -0,0 +1,96 @@
+package eda
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// EventRouter dispatches events to handlers by event type.
+//
+//	r := eda.NewEventRouter().
+//		On("patient.enrolled", handleEnrolled).
+//		On("patient.*", handlePatient).
+//		Default(handleOther)
+//
+//	sub, err := conn.Subscribe("patients", r.Handler(), nil)
+type EventRouter struct {
+	mux      sync.RWMutex
+	exact    map[string]Handler
+	prefixes map[string]Handler
+	fallback Handler
+}
+
+// On registers the handler for the event type. A type ending in ".*"
+// matches any type with the preceding prefix, such as "patient.*" for
+// "patient.enrolled". Exact types take precedence over patterns and
+// longer patterns over shorter ones.
+func (r *EventRouter) On(eventType string, h Handler) *EventRouter {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if strings.HasSuffix(eventType, ".*") {
+		r.prefixes[strings.TrimSuffix(eventType, "*")] = h
+	} else {
+		r.exact[eventType] = h
+	}
+
+	return r
+}
+
+// Default registers the handler for events that do not match a type.
+// Without one, unmatched events are ignored.
+func (r *EventRouter) Default(h Handler) *EventRouter {
+	r.mux.Lock()
+	r.fallback = h
+	r.mux.Unlock()
+	return r
+}
+
+// route returns the handler for the event type or nil.
+func (r *EventRouter) route(eventType string) Handler {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	if h, ok := r.exact[eventType]; ok {
+		return h
+	}
+
+	var (
+		h       Handler
+		longest int
+	)
+
+	for prefix, ph := range r.prefixes {
+		if len(prefix) > longest && strings.HasPrefix(eventType, prefix) {
+			h = ph
+			longest = len(prefix)
+		}
+	}
+
+	if h != nil {
+		return h
+	}
+
+	return r.fallback
+}
+
+// Handler returns a handler that dispatches events to the registered
+// handlers.
+func (r *EventRouter) Handler() Handler {
+	return func(ctx context.Context, evt *Event) error {
+		if h := r.route(evt.Type); h != nil {
+			return h(ctx, evt)
+		}
+
+		return nil
+	}
+}
+
+// NewEventRouter returns a router without any handlers.
+func NewEventRouter() *EventRouter {
+	return &EventRouter{
+		exact:    make(map[string]Handler),
+		prefixes: make(map[string]Handler),
+	}
+}
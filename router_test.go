@@ -0,0 +1,51 @@
+package eda
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEventRouter(t *testing.T) {
+	var routed string
+
+	handler := func(name string) Handler {
+		return func(ctx context.Context, evt *Event) error {
+			routed = name
+			return nil
+		}
+	}
+
+	r := NewEventRouter().
+		On("patient.enrolled", handler("enrolled")).
+		On("patient.*", handler("patient")).
+		On("patient.visit.*", handler("visit"))
+
+	handle := r.Handler()
+
+	tests := map[string]string{
+		"patient.enrolled":        "enrolled",
+		"patient.withdrawn":       "patient",
+		"patient.visit.scheduled": "visit",
+		"study.created":           "",
+	}
+
+	for typ, expected := range tests {
+		routed = ""
+		if err := handle(context.Background(), &Event{Type: typ}); err != nil {
+			t.Fatal(err)
+		}
+
+		if routed != expected {
+			t.Errorf("%s: expected %q, got %q", typ, expected, routed)
+		}
+	}
+
+	r.Default(handler("default"))
+
+	routed = ""
+	handle(context.Background(), &Event{Type: "study.created"})
+
+	if routed != "default" {
+		t.Errorf("expected default handler, got %q", routed)
+	}
+}
@@ -0,0 +1,211 @@
+/*
+Package saga implements sagas, either choreographed with policies or
+orchestrated with a runner.
+
+Choreography-based sagas have policies that react to events by publishing
+new events, without a central coordinator.
+
+	r := saga.NewPolicyRegistry(conn)
+
+	r.Register("order-placed", reservePayment, "payment-reserved")
+	r.Register("payment-reserved", shipOrder, "order-shipped")
+
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	sub, err := conn.Subscribe("orders", saga.PolicyHandler(r), nil)
+
+Orchestrated sagas have a runner that runs the steps of a saga and runs
+the compensations of the completed steps in reverse if a step fails.
+
+	runner := saga.NewRunner(conn, saga.NewMemStateStore(), saga.RunnerOptions{
+		Stream: "sagas",
+	})
+
+	sub, err := conn.Subscribe("orders", runner.Handler(orderSaga), nil)
+*/
+package saga
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/chop-dbhi/eda"
+)
+
+// CorrelationMetaKey is the meta key of the ID that groups the events of
+// a saga.
+const CorrelationMetaKey = eda.CorrelationMetaKey
+
+// CorrelationID returns the correlation ID of the event. An event without
+// one starts a new saga and its own ID is used.
+func CorrelationID(evt *eda.Event) string {
+	if id := evt.Meta[CorrelationMetaKey]; id != "" {
+		return id
+	}
+
+	return evt.ID
+}
+
+// Policy reacts to an event, typically by publishing new events to the
+// connection. Events published to the connection are correlated with the
+// event being handled.
+type Policy func(ctx context.Context, evt *eda.Event, conn eda.Conn) error
+
+type policy struct {
+	fn    Policy
+	emits []string
+}
+
+// ErrPolicyLoop is returned by Validate if policies react to events in
+// a cycle.
+type ErrPolicyLoop struct {
+	Types []string
+}
+
+func (e *ErrPolicyLoop) Error() string {
+	return "policy loop: " + strings.Join(e.Types, " -> ")
+}
+
+// PolicyRegistry maps event types to the policies that react to them.
+type PolicyRegistry struct {
+	conn     eda.Conn
+	policies map[string][]*policy
+}
+
+// Register adds a policy for the event type. The event types the policy
+// may publish are declared so Validate can detect loops.
+func (r *PolicyRegistry) Register(eventType string, fn Policy, emits ...string) {
+	r.policies[eventType] = append(r.policies[eventType], &policy{
+		fn:    fn,
+		emits: emits,
+	})
+}
+
+// Validate returns an ErrPolicyLoop if an event can cause, through a chain
+// of policies, an event of the same type to be published.
+func (r *PolicyRegistry) Validate() error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	state := make(map[string]int)
+
+	var visit func(path []string) error
+
+	visit = func(path []string) error {
+		typ := path[len(path)-1]
+
+		switch state[typ] {
+		case visited:
+			return nil
+		case visiting:
+			for i, t := range path {
+				if t == typ {
+					return &ErrPolicyLoop{Types: path[i:]}
+				}
+			}
+		}
+
+		state[typ] = visiting
+
+		for _, p := range r.policies[typ] {
+			for _, e := range p.emits {
+				if err := visit(append(path, e)); err != nil {
+					return err
+				}
+			}
+		}
+
+		state[typ] = visited
+		return nil
+	}
+
+	// Sort for a deterministic error.
+	types := make([]string, 0, len(r.policies))
+	for t := range r.policies {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	for _, t := range types {
+		if err := visit([]string{t}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewPolicyRegistry returns an empty registry. Policies publish to the
+// connection.
+func NewPolicyRegistry(conn eda.Conn) *PolicyRegistry {
+	return &PolicyRegistry{
+		conn:     conn,
+		policies: make(map[string][]*policy),
+	}
+}
+
+// correlatedConn sets the correlation ID and cause of published events
+// to those of the event being handled.
+type correlatedConn struct {
+	eda.Conn
+
+	cause *eda.Event
+}
+
+func (c *correlatedConn) Publish(stream string, evt *eda.Event) (string, error) {
+	if evt == nil {
+		evt = &eda.Event{}
+	}
+
+	meta := make(map[string]string, len(evt.Meta)+1)
+	for k, v := range evt.Meta {
+		meta[k] = v
+	}
+
+	if meta[CorrelationMetaKey] == "" {
+		meta[CorrelationMetaKey] = CorrelationID(c.cause)
+	}
+
+	e := *evt
+	e.Meta = meta
+
+	if e.Cause == "" {
+		e.Cause = c.cause.ID
+	}
+
+	return c.Conn.Publish(stream, &e)
+}
+
+// PublishBatch correlates and publishes each event in order.
+func (c *correlatedConn) PublishBatch(stream string, evts []*eda.Event) ([]string, error) {
+	return eda.PublishEach(c.Publish, stream, evts)
+}
+
+// PolicyHandler returns a handler that dispatches events to the policies
+// registered for their type. Events without policies are ignored.
+func PolicyHandler(registry *PolicyRegistry) eda.Handler {
+	return func(ctx context.Context, evt *eda.Event) error {
+		policies := registry.policies[evt.Type]
+		if len(policies) == 0 {
+			return nil
+		}
+
+		conn := &correlatedConn{
+			Conn:  registry.conn,
+			cause: evt,
+		}
+
+		for _, p := range policies {
+			if err := p.fn(ctx, evt, conn); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
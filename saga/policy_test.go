@@ -0,0 +1,79 @@
+package saga
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chop-dbhi/eda"
+)
+
+type recordConn struct {
+	eda.Conn
+
+	published []*eda.Event
+}
+
+func (c *recordConn) Publish(stream string, evt *eda.Event) (string, error) {
+	c.published = append(c.published, evt)
+	return "2", nil
+}
+
+func TestPolicyHandler(t *testing.T) {
+	conn := &recordConn{}
+	r := NewPolicyRegistry(conn)
+
+	r.Register("order-placed", func(ctx context.Context, evt *eda.Event, conn eda.Conn) error {
+		_, err := conn.Publish("payments", &eda.Event{Type: "payment-reserved"})
+		return err
+	}, "payment-reserved")
+
+	if err := r.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	handle := PolicyHandler(r)
+
+	if err := handle(context.Background(), &eda.Event{ID: "1", Type: "order-placed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := handle(context.Background(), &eda.Event{ID: "3", Type: "unknown"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conn.published) != 1 {
+		t.Fatalf("expected 1 published event, got %d", len(conn.published))
+	}
+
+	evt := conn.published[0]
+	if evt.Cause != "1" {
+		t.Errorf("expected cause 1, got %q", evt.Cause)
+	}
+
+	if CorrelationID(evt) != "1" {
+		t.Errorf("expected correlation id 1, got %q", CorrelationID(evt))
+	}
+}
+
+func TestPolicyRegistryValidate(t *testing.T) {
+	noop := func(ctx context.Context, evt *eda.Event, conn eda.Conn) error {
+		return nil
+	}
+
+	r := NewPolicyRegistry(nil)
+
+	r.Register("a", noop, "b")
+	r.Register("b", noop, "c")
+	r.Register("c", noop, "a")
+
+	err := r.Validate()
+
+	loop, ok := err.(*ErrPolicyLoop)
+	if !ok {
+		t.Fatalf("expected policy loop, got %v", err)
+	}
+
+	if loop.Error() != "policy loop: a -> b -> c -> a" {
+		t.Errorf("unexpected error: %s", loop)
+	}
+}
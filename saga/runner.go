@@ -0,0 +1,248 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/chop-dbhi/eda"
+)
+
+// Event types published by the runner.
+const (
+	StartedEventType   = "saga.started"
+	CompletedEventType = "saga.completed"
+	FailedEventType    = "saga.failed"
+)
+
+// Meta keys set on the events published by the runner.
+const (
+	NameMetaKey = "saga.name"
+	IDMetaKey   = "saga.id"
+
+	// The failed step and its error are set on failed events.
+	StepMetaKey  = "saga.step"
+	ErrorMetaKey = "saga.error"
+)
+
+// Action is a step action or compensation. Events published to the
+// connection are correlated with the event that started the saga.
+type Action func(ctx context.Context, evt *eda.Event) error
+
+// Step is a step of a saga with an action and an optional compensation
+// that reverses the action if a later step fails.
+type Step struct {
+	Name       string
+	Action     Action
+	Compensate Action
+}
+
+// Saga is a process of steps started by an event.
+type Saga interface {
+	// Name identifies the saga. It must be unique across the sagas using
+	// the same state store.
+	Name() string
+
+	// Steps returns the steps to run in order.
+	Steps() []Step
+}
+
+// ErrSagaFailed is returned by Runner.Run if a step failed and the
+// completed steps were compensated.
+type ErrSagaFailed struct {
+	Saga string
+	Step string
+	Err  error
+}
+
+func (e *ErrSagaFailed) Error() string {
+	return fmt.Sprintf("saga %s failed at step %s: %s", e.Saga, e.Step, e.Err)
+}
+
+func (e *ErrSagaFailed) Unwrap() error {
+	return e.Err
+}
+
+// RunnerOptions are options for NewRunner.
+type RunnerOptions struct {
+	// Stream is the stream the saga events are published to. If empty,
+	// no events are published.
+	Stream string
+}
+
+// Runner runs the steps of sagas and compensates the completed steps if
+// a step fails. The state is saved after each step so a saga resumes
+// where it left off if it is run again for the same event.
+type Runner struct {
+	conn  eda.Conn
+	store StateStore
+	opts  RunnerOptions
+}
+
+func (r *Runner) publish(ctx context.Context, typ string, evt *eda.Event, state *State) error {
+	if r.opts.Stream == "" {
+		return nil
+	}
+
+	meta := map[string]string{
+		NameMetaKey: state.Saga,
+		IDMetaKey:   state.ID,
+	}
+
+	if state.FailedStep != "" {
+		meta[StepMetaKey] = state.FailedStep
+	}
+
+	if state.Error != "" {
+		meta[ErrorMetaKey] = state.Error
+	}
+
+	conn := &correlatedConn{Conn: r.conn, cause: evt}
+
+	_, err := conn.Publish(r.opts.Stream, &eda.Event{
+		Type: typ,
+		Meta: meta,
+	})
+
+	return err
+}
+
+// compensate compensates the completed steps in reverse order.
+func (r *Runner) compensate(ctx context.Context, steps []Step, evt *eda.Event, state *State) error {
+	for state.Completed > 0 {
+		step := steps[state.Completed-1]
+
+		if step.Compensate != nil {
+			if err := step.Compensate(ctx, evt); err != nil {
+				return fmt.Errorf("compensate step %s: %w", step.Name, err)
+			}
+		}
+
+		state.Completed--
+
+		if err := r.store.Save(ctx, state); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run runs the saga for the event. If a step fails, the completed steps
+// are compensated and an *ErrSagaFailed is returned. Any other error,
+// such as a failed compensation, leaves the state saved so running the
+// saga again for the event resumes it.
+func (r *Runner) Run(ctx context.Context, s Saga, evt *eda.Event) error {
+	id := s.Name() + "/" + evt.ID
+	steps := s.Steps()
+
+	state, err := r.store.Load(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if state == nil {
+		state = &State{
+			ID:     id,
+			Saga:   s.Name(),
+			Status: StatusRunning,
+		}
+
+		if err := r.store.Save(ctx, state); err != nil {
+			return err
+		}
+
+		if err := r.publish(ctx, StartedEventType, evt, state); err != nil {
+			return err
+		}
+	}
+
+	// Wrap the event so published events are correlated.
+	conn := &correlatedConn{Conn: r.conn, cause: evt}
+	ctx = context.WithValue(ctx, connContextKey, eda.Conn(conn))
+
+	for state.Status == StatusRunning && state.Completed < len(steps) {
+		step := steps[state.Completed]
+
+		if err := step.Action(ctx, evt); err != nil {
+			state.Status = StatusCompensating
+			state.FailedStep = step.Name
+			state.Error = err.Error()
+
+			if serr := r.store.Save(ctx, state); serr != nil {
+				return serr
+			}
+
+			break
+		}
+
+		state.Completed++
+
+		if err := r.store.Save(ctx, state); err != nil {
+			return err
+		}
+	}
+
+	if state.Status == StatusCompensating {
+		if err := r.compensate(ctx, steps, evt, state); err != nil {
+			return err
+		}
+
+		if err := r.publish(ctx, FailedEventType, evt, state); err != nil {
+			return err
+		}
+
+		if err := r.store.Delete(ctx, id); err != nil {
+			return err
+		}
+
+		return &ErrSagaFailed{
+			Saga: s.Name(),
+			Step: state.FailedStep,
+			Err:  errors.New(state.Error),
+		}
+	}
+
+	if err := r.publish(ctx, CompletedEventType, evt, state); err != nil {
+		return err
+	}
+
+	return r.store.Delete(ctx, id)
+}
+
+// Handler returns a handler that runs the saga for each event. A saga that
+// failed and was compensated is considered handled, so only other errors
+// are returned to have the event redelivered.
+func (r *Runner) Handler(s Saga) eda.Handler {
+	return func(ctx context.Context, evt *eda.Event) error {
+		err := r.Run(ctx, s, evt)
+
+		var ferr *ErrSagaFailed
+		if errors.As(err, &ferr) {
+			return nil
+		}
+
+		return err
+	}
+}
+
+type contextKey int
+
+const connContextKey contextKey = iota
+
+// ConnFromContext returns the connection to publish events from a step.
+// Published events are correlated with the event that started the saga.
+func ConnFromContext(ctx context.Context) eda.Conn {
+	conn, _ := ctx.Value(connContextKey).(eda.Conn)
+	return conn
+}
+
+// NewRunner returns a runner that saves the state of sagas in the store
+// and publishes to the connection.
+func NewRunner(conn eda.Conn, store StateStore, opts RunnerOptions) *Runner {
+	return &Runner{
+		conn:  conn,
+		store: store,
+		opts:  opts,
+	}
+}
@@ -0,0 +1,129 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/chop-dbhi/eda"
+)
+
+type testSaga struct {
+	steps []Step
+}
+
+func (s *testSaga) Name() string {
+	return "order"
+}
+
+func (s *testSaga) Steps() []Step {
+	return s.steps
+}
+
+func TestRunner(t *testing.T) {
+	var calls []string
+
+	step := func(name string, fail *bool) Step {
+		return Step{
+			Name: name,
+			Action: func(ctx context.Context, evt *eda.Event) error {
+				if fail != nil && *fail {
+					return errors.New("unavailable")
+				}
+				calls = append(calls, name)
+				return nil
+			},
+			Compensate: func(ctx context.Context, evt *eda.Event) error {
+				calls = append(calls, "undo-"+name)
+				return nil
+			},
+		}
+	}
+
+	failShip := true
+
+	s := &testSaga{
+		steps: []Step{
+			step("reserve", nil),
+			step("charge", nil),
+			step("ship", &failShip),
+		},
+	}
+
+	conn := &recordConn{}
+	store := NewMemStateStore()
+	runner := NewRunner(conn, store, RunnerOptions{Stream: "sagas"})
+
+	ctx := context.Background()
+	evt := &eda.Event{ID: "1", Type: "order-placed"}
+
+	err := runner.Run(ctx, s, evt)
+
+	var ferr *ErrSagaFailed
+	if !errors.As(err, &ferr) || ferr.Step != "ship" {
+		t.Fatalf("expected saga to fail at ship, got %v", err)
+	}
+
+	exp := "reserve charge undo-charge undo-reserve"
+	if got := strings.Join(calls, " "); got != exp {
+		t.Errorf("expected %q, got %q", exp, got)
+	}
+
+	if len(conn.published) != 2 || conn.published[0].Type != StartedEventType || conn.published[1].Type != FailedEventType {
+		t.Fatalf("expected started and failed events, got %v", conn.published)
+	}
+
+	failed := conn.published[1]
+	if failed.Meta[StepMetaKey] != "ship" || failed.Meta[ErrorMetaKey] != "unavailable" || failed.Cause != "1" {
+		t.Errorf("unexpected failed event: %+v", failed)
+	}
+
+	if state, _ := store.Load(ctx, "order/1"); state != nil {
+		t.Errorf("expected state to be deleted, got %+v", state)
+	}
+
+	// The handler treats the compensated saga as handled.
+	calls = nil
+	conn.published = nil
+	failShip = false
+
+	if err := runner.Handler(s)(ctx, &eda.Event{ID: "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(calls, " "); got != "reserve charge ship" {
+		t.Errorf("unexpected calls %q", got)
+	}
+
+	if len(conn.published) != 2 || conn.published[1].Type != CompletedEventType {
+		t.Fatalf("expected started and completed events, got %v", conn.published)
+	}
+}
+
+func TestRunnerResume(t *testing.T) {
+	var calls int
+
+	s := &testSaga{
+		steps: []Step{
+			{Name: "a", Action: func(context.Context, *eda.Event) error { calls++; return nil }},
+			{Name: "b", Action: func(context.Context, *eda.Event) error { calls++; return nil }},
+		},
+	}
+
+	store := NewMemStateStore()
+	ctx := context.Background()
+
+	// The first step completed before the process exited.
+	store.Save(ctx, &State{ID: "order/1", Saga: "order", Status: StatusRunning, Completed: 1})
+
+	runner := NewRunner(&recordConn{}, store, RunnerOptions{})
+
+	if err := runner.Run(ctx, s, &eda.Event{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected only the second step to run, got %d calls", calls)
+	}
+}
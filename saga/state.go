@@ -0,0 +1,86 @@
+package saga
+
+import (
+	"context"
+	"sync"
+)
+
+// Statuses of a saga.
+const (
+	StatusRunning      = "running"
+	StatusCompensating = "compensating"
+)
+
+// State is the progress of a saga started by an event.
+type State struct {
+	// ID of the saga instance.
+	ID string `json:"id"`
+
+	// Saga is the name of the saga.
+	Saga string `json:"saga"`
+
+	// Status is StatusRunning or StatusCompensating.
+	Status string `json:"status"`
+
+	// Completed is the number of steps whose action completed and which
+	// have not been compensated.
+	Completed int `json:"completed"`
+
+	// FailedStep is the name of the step that failed.
+	FailedStep string `json:"failed_step,omitempty"`
+
+	// Error is the error of the step that failed.
+	Error string `json:"error,omitempty"`
+}
+
+// StateStore persists the state of running sagas so they resume where
+// they left off when the event is redelivered.
+type StateStore interface {
+	// Save saves the state.
+	Save(ctx context.Context, s *State) error
+
+	// Load returns the state of the saga or nil if there is none.
+	Load(ctx context.Context, id string) (*State, error)
+
+	// Delete deletes the state once the saga has completed or failed.
+	Delete(ctx context.Context, id string) error
+}
+
+type memStateStore struct {
+	mux    sync.Mutex
+	states map[string]State
+}
+
+func (s *memStateStore) Save(ctx context.Context, state *State) error {
+	s.mux.Lock()
+	s.states[state.ID] = *state
+	s.mux.Unlock()
+	return nil
+}
+
+func (s *memStateStore) Load(ctx context.Context, id string) (*State, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	state, ok := s.states[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return &state, nil
+}
+
+func (s *memStateStore) Delete(ctx context.Context, id string) error {
+	s.mux.Lock()
+	delete(s.states, id)
+	s.mux.Unlock()
+	return nil
+}
+
+// NewMemStateStore returns an in-memory state store. Sagas that are
+// running when the process exits are not resumed.
+func NewMemStateStore() StateStore {
+	return &memStateStore{
+		states: make(map[string]State),
+	}
+}
@@ -0,0 +1,242 @@
+package eda
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrKeyNotFound is returned by a KeyStore if there is no key for the
+	// entity.
+	ErrKeyNotFound = errors.New("key not found")
+
+	// ErrKeyDestroyed is returned when decoding encrypted data whose key
+	// has been shredded.
+	ErrKeyDestroyed = errors.New("encryption key destroyed")
+)
+
+// KeyStore stores encryption keys per entity.
+type KeyStore interface {
+	// Key returns the key for the entity or ErrKeyNotFound.
+	Key(entityID string) ([]byte, error)
+
+	// SetKey sets the key for the entity.
+	SetKey(entityID string, key []byte) error
+
+	// DeleteKey deletes the key for the entity.
+	DeleteKey(entityID string) error
+}
+
+type memKeyStore struct {
+	mux  sync.RWMutex
+	keys map[string][]byte
+}
+
+func (s *memKeyStore) Key(entityID string) ([]byte, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	key, ok := s.keys[entityID]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return key, nil
+}
+
+func (s *memKeyStore) SetKey(entityID string, key []byte) error {
+	s.mux.Lock()
+	s.keys[entityID] = key
+	s.mux.Unlock()
+	return nil
+}
+
+func (s *memKeyStore) DeleteKey(entityID string) error {
+	s.mux.Lock()
+	delete(s.keys, entityID)
+	s.mux.Unlock()
+	return nil
+}
+
+// NewMemKeyStore returns an in-memory key store.
+func NewMemKeyStore() KeyStore {
+	return &memKeyStore{
+		keys: make(map[string][]byte),
+	}
+}
+
+// sealed is the encoded form of encrypted data.
+type sealed struct {
+	EntityID string `json:"entity_id"`
+	Encoding string `json:"encoding"`
+	Nonce    []byte `json:"nonce"`
+	Data     []byte `json:"data"`
+}
+
+// CryptoShredder encrypts event data with per-entity keys. Deleting the key
+// of an entity makes all data encrypted for it permanently unreadable,
+// which allows erasing personal data from append-only streams.
+type CryptoShredder struct {
+	keys KeyStore
+
+	// Serializes key creation.
+	mux sync.Mutex
+}
+
+// key returns the key for the entity, creating one if it does not exist.
+func (s *CryptoShredder) key(entityID string) ([]byte, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	key, err := s.keys.Key(entityID)
+	if err == nil {
+		return key, nil
+	}
+
+	if err != ErrKeyNotFound {
+		return nil, err
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	if err := s.keys.SetKey(entityID, key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// Encrypt returns Data that encrypts the data with the key of the entity
+// when encoded. A key is created for the entity if it does not exist.
+func (s *CryptoShredder) Encrypt(entityID string, d Data) Data {
+	return &decodable{
+		t: "encrypted",
+		v: d,
+		enc: &cryptoEncoder{
+			shredder: s,
+			entityID: entityID,
+		},
+	}
+}
+
+// Decrypt returns Data that decrypts the received encrypted data when
+// decoded. Decode returns ErrKeyDestroyed if the entity has been shredded.
+func (s *CryptoShredder) Decrypt(d Data) Data {
+	b, err := d.Encode()
+
+	return &decodable{
+		t: d.Type(),
+		b: b,
+		e: true,
+		enc: &cryptoEncoder{
+			shredder: s,
+			err:      err,
+		},
+	}
+}
+
+// Shred deletes the key of the entity.
+func (s *CryptoShredder) Shred(entityID string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.keys.DeleteKey(entityID)
+}
+
+// NewCryptoShredder returns a shredder using the key store.
+func NewCryptoShredder(keys KeyStore) *CryptoShredder {
+	return &CryptoShredder{
+		keys: keys,
+	}
+}
+
+type cryptoEncoder struct {
+	shredder *CryptoShredder
+	entityID string
+	err      error
+}
+
+func (e *cryptoEncoder) Encode(v interface{}) ([]byte, error) {
+	d, ok := v.(Data)
+	if !ok {
+		return nil, errors.New("data required")
+	}
+
+	b, err := d.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := e.shredder.key(e.entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&sealed{
+		EntityID: e.entityID,
+		Encoding: d.Type(),
+		Nonce:    nonce,
+		Data:     gcm.Seal(nil, nonce, b, []byte(e.entityID)),
+	})
+}
+
+func (e *cryptoEncoder) Decode(b []byte, v interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	var s sealed
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	key, err := e.shredder.keys.Key(s.EntityID)
+	if err == ErrKeyNotFound {
+		return ErrKeyDestroyed
+	} else if err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	p, err := gcm.Open(nil, s.Nonce, s.Data, []byte(s.EntityID))
+	if err != nil {
+		return err
+	}
+
+	enc, ok := encMap[s.Encoding]
+	if !ok {
+		return errors.New("unknown encoding: " + s.Encoding)
+	}
+
+	return enc.Decode(p, v)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,42 @@
+package eda
+
+import "testing"
+
+func TestCryptoShredder(t *testing.T) {
+	s := NewCryptoShredder(NewMemKeyStore())
+
+	d := s.Encrypt("user-1", JSON(map[string]string{"name": "Jane"}))
+
+	if d.Type() != "encrypted" {
+		t.Errorf("expected encrypted type, got %s", d.Type())
+	}
+
+	b, err := d.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Data as received from a stream.
+	received := &decodable{
+		b: b,
+		t: "encrypted",
+		e: true,
+	}
+
+	var v map[string]string
+	if err := s.Decrypt(received).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v["name"] != "Jane" {
+		t.Errorf("unexpected decoded value: %v", v)
+	}
+
+	if err := s.Shred("user-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Decrypt(received).Decode(&v); err != ErrKeyDestroyed {
+		t.Errorf("expected key destroyed error, got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Package sqlprojection provides a projector that maintains a read model in
+a SQL table by upserting a row for each event.
+*/
+package sqlprojection
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/chop-dbhi/eda"
+)
+
+// DefaultKeyColumn is the name of the primary key column.
+const DefaultKeyColumn = "key"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+type column struct {
+	name  string
+	index int
+	typ   reflect.Type
+}
+
+// Projector upserts a row built from each event into a table. The key
+// returned by the key function is written to the key column and is used
+// as the conflict target. The remaining columns are taken from the fields
+// of T with a `db` tag.
+type Projector[T any] struct {
+	// KeyColumn is the name of the primary key column. This must be set
+	// before the projector is used.
+	KeyColumn string
+
+	db      *sql.DB
+	table   string
+	keyFn   func(*eda.Event) string
+	rowFn   func(*eda.Event) (T, error)
+	columns []column
+}
+
+// New returns a projector for the table. T must be a struct type with
+// `db` tags on the fields to be stored.
+func New[T any](db *sql.DB, table string, keyFn func(*eda.Event) string, rowFn func(*eda.Event) (T, error)) (*Projector[T], error) {
+	var zero T
+
+	t := reflect.TypeOf(zero)
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("struct type required: %v", t)
+	}
+
+	var cols []column
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		name := strings.Split(f.Tag.Get("db"), ",")[0]
+		if name == "" || name == "-" || f.PkgPath != "" {
+			continue
+		}
+
+		cols = append(cols, column{
+			name:  name,
+			index: i,
+			typ:   f.Type,
+		})
+	}
+
+	if len(cols) == 0 {
+		return nil, errors.New("no fields with db tags")
+	}
+
+	return &Projector[T]{
+		KeyColumn: DefaultKeyColumn,
+		db:        db,
+		table:     table,
+		keyFn:     keyFn,
+		rowFn:     rowFn,
+		columns:   cols,
+	}, nil
+}
+
+// Up creates the table if it does not exist.
+func (p *Projector[T]) Up(ctx context.Context) error {
+	_, err := p.db.ExecContext(ctx, p.createStmt())
+	return err
+}
+
+// Handle upserts the row for the event. This can be passed as the handler
+// of a subscription. Events with an empty key are ignored.
+func (p *Projector[T]) Handle(ctx context.Context, evt *eda.Event) error {
+	key := p.keyFn(evt)
+	if key == "" {
+		return nil
+	}
+
+	row, err := p.rowFn(evt)
+	if err != nil {
+		return err
+	}
+
+	args, err := p.values(key, row)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.db.ExecContext(ctx, p.upsertStmt(), args...)
+	return err
+}
+
+func (p *Projector[T]) values(key string, row T) ([]interface{}, error) {
+	v := reflect.ValueOf(row)
+
+	args := []interface{}{key}
+
+	for _, c := range p.columns {
+		x := v.Field(c.index).Interface()
+
+		// Types without a column mapping are stored as JSON.
+		if columnType(c.typ) == "jsonb" {
+			b, err := json.Marshal(x)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %s", c.name, err)
+			}
+			x = string(b)
+		}
+
+		args = append(args, x)
+	}
+
+	return args, nil
+}
+
+func (p *Projector[T]) createStmt() string {
+	defs := []string{
+		fmt.Sprintf("%s text primary key", quote(p.KeyColumn)),
+	}
+
+	for _, c := range p.columns {
+		defs = append(defs, fmt.Sprintf("%s %s", quote(c.name), columnType(c.typ)))
+	}
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quote(p.table), strings.Join(defs, ", "))
+}
+
+func (p *Projector[T]) upsertStmt() string {
+	names := []string{quote(p.KeyColumn)}
+	params := []string{"$1"}
+	sets := make([]string, len(p.columns))
+
+	for i, c := range p.columns {
+		names = append(names, quote(c.name))
+		params = append(params, fmt.Sprintf("$%d", i+2))
+		sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", quote(c.name), quote(c.name))
+	}
+
+	return fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+		quote(p.table),
+		strings.Join(names, ", "),
+		strings.Join(params, ", "),
+		quote(p.KeyColumn),
+		strings.Join(sets, ", "),
+	)
+}
+
+// columnType maps a Go type to a column type.
+func columnType(t reflect.Type) string {
+	if t == timeType {
+		return "timestamptz"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "text"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return "bigint"
+	case reflect.Float32, reflect.Float64:
+		return "double precision"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "bytea"
+		}
+	}
+
+	return "jsonb"
+}
+
+// quote quotes an identifier.
+func quote(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
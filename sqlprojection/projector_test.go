@@ -0,0 +1,66 @@
+package sqlprojection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chop-dbhi/eda"
+)
+
+type subject struct {
+	Name     string            `db:"name"`
+	Enrolled time.Time         `db:"enrolled"`
+	Visits   int               `db:"visits"`
+	Tags     map[string]string `db:"tags"`
+	Ignored  string
+}
+
+func newProjector(t *testing.T) *Projector[subject] {
+	p, err := New(nil, "subjects",
+		func(evt *eda.Event) string { return evt.Aggregate },
+		func(evt *eda.Event) (subject, error) { return subject{}, nil },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestCreateStmt(t *testing.T) {
+	p := newProjector(t)
+
+	exp := `CREATE TABLE IF NOT EXISTS "subjects" ("key" text primary key, "name" text, "enrolled" timestamptz, "visits" bigint, "tags" jsonb)`
+	if s := p.createStmt(); s != exp {
+		t.Fatalf("expected %s, got %s", exp, s)
+	}
+}
+
+func TestUpsertStmt(t *testing.T) {
+	p := newProjector(t)
+
+	exp := `INSERT INTO "subjects" ("key", "name", "enrolled", "visits", "tags") VALUES ($1, $2, $3, $4, $5) ON CONFLICT ("key") DO UPDATE SET "name" = EXCLUDED."name", "enrolled" = EXCLUDED."enrolled", "visits" = EXCLUDED."visits", "tags" = EXCLUDED."tags"`
+	if s := p.upsertStmt(); s != exp {
+		t.Fatalf("expected %s, got %s", exp, s)
+	}
+}
+
+func TestValues(t *testing.T) {
+	p := newProjector(t)
+
+	args, err := p.values("1", subject{
+		Name: "foo",
+		Tags: map[string]string{"a": "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(args) != 5 {
+		t.Fatalf("expected 5 args, got %d", len(args))
+	}
+
+	if args[4] != `{"a":"b"}` {
+		t.Fatalf("expected JSON encoded tags, got %v", args[4])
+	}
+}
@@ -2,17 +2,22 @@ package eda
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
-	"github.com/chop-dbhi/eda/internal/pb"
-	"github.com/golang/protobuf/proto"
 	"github.com/nats-io/go-nats"
 	stan "github.com/nats-io/go-nats-streaming"
 	stanpb "github.com/nats-io/go-nats-streaming/pb"
-	"github.com/nats-io/nuid"
 )
 
 // resetDurable resets a durable subscription by name.
@@ -27,27 +32,23 @@ func resetDurable(conn stan.Conn, stream, queueName, durableName string) error {
 	return sub.Unsubscribe()
 }
 
-type stanSubscription struct {
-	channel  string
-	consumer string
-	durable  bool
-	conn     *stanConn
-	sub      stan.Subscription
+// natsSubscription adapts a core NATS subscription which has no offset
+// to retain on close.
+type natsSubscription struct {
+	*nats.Subscription
 }
 
-func (s *stanSubscription) Close() error {
-	return s.sub.Close()
-}
-
-func (s *stanSubscription) Unsubscribe() error {
-	return s.sub.Unsubscribe()
+func (s *natsSubscription) Close() error {
+	return s.Unsubscribe()
 }
 
 // stanConn is an implementation of Conn.
 type stanConn struct {
-	logger Logger
+	baseConn
+
+	// Base URL of the server monitoring endpoint.
+	monitor string
 
-	client  string
 	cluster string
 
 	nats *nats.Conn
@@ -57,7 +58,7 @@ type stanConn struct {
 // Close the underlying connection to the stream backend.
 func (c *stanConn) Close() error {
 	if err := c.stan.Close(); err != nil {
-		c.logger.Printf("[%s] connection close error: %s", c.client, err)
+		c.logger.Error("connection close error", slog.Any("error", err))
 	}
 
 	// No returned error.
@@ -67,56 +68,50 @@ func (c *stanConn) Close() error {
 }
 
 func (c *stanConn) Publish(stream string, evt *Event) (string, error) {
-	var (
-		err      error
-		datab    []byte
-		encoding string
-	)
-
 	if evt == nil {
 		evt = &Event{}
 	}
 
-	// Add event time if not set.
-	if evt.Time.IsZero() {
-		evt.Time = time.Now()
+	id, b, err := c.encode(evt)
+	if err != nil {
+		return "", err
 	}
 
-	if evt.Data == nil {
-		encoding = "nil"
+	// Publish event. Ephemeral events are not persisted.
+	if evt.Ephemeral {
+		err = c.nats.Publish(stream, b)
 	} else {
-		encoding = evt.Data.Type()
-		datab, err = evt.Data.Encode()
+		err = c.stan.Publish(stream, b)
 	}
-
 	if err != nil {
-		return "", err
+		return id, err
 	}
 
-	id := nuid.Next()
+	return id, nil
+}
 
-	b, err := proto.Marshal(&pb.Event{
-		Id:        id,
-		Type:      evt.Type,
-		Cause:     evt.Cause,
-		Time:      evt.Time.UnixNano(),
-		Client:    c.client,
-		Data:      datab,
-		Encoding:  encoding,
-		Meta:      evt.Meta,
-		Aggregate: evt.Aggregate,
-	})
-	if err != nil {
-		return "", err
-	}
+// PublishBatch publishes the events in order, stopping at the first failure.
+func (c *stanConn) PublishBatch(stream string, evts []*Event) ([]string, error) {
+	return PublishEach(c.Publish, stream, evts)
+}
 
-	// Publish event.
-	err = c.stan.Publish(stream, b)
+// Request publishes the event and waits for the reply on a NATS inbox.
+func (c *stanConn) Request(ctx context.Context, stream string, evt *Event) (*Reply, error) {
+	inbox := nats.NewInbox()
+	replies := make(chan []byte, 1)
+
+	sub, err := c.nats.Subscribe(inbox, func(m *nats.Msg) {
+		select {
+		case replies <- m.Data:
+		default:
+		}
+	})
 	if err != nil {
-		return id, err
+		return nil, err
 	}
+	defer sub.Unsubscribe()
 
-	return id, nil
+	return c.request(ctx, stream, evt, inbox, replies)
 }
 
 func (c *stanConn) Subscribe(stream string, handle Handler, opts *SubscriptionOptions) (Subscription, error) {
@@ -126,6 +121,10 @@ func (c *stanConn) Subscribe(stream string, handle Handler, opts *SubscriptionOp
 		opts = &(*opts)
 	}
 
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
 	if opts.Timeout == 0 {
 		opts.Timeout = stan.DefaultAckWait
 	}
@@ -143,85 +142,47 @@ func (c *stanConn) Subscribe(stream string, handle Handler, opts *SubscriptionOp
 		}
 	}
 
-	// Handler for the raw message.
-	msgHandler := func(msg *stan.Msg) {
-		var e pb.Event
-
-		// Message sent on stream that is not a protobuf format.
-		err := proto.Unmarshal(msg.Data, &e)
+	sub := c.newSubscription(stream, consumerName, opts)
+	msgHandler := c.msgHandler(sub, handle, opts)
+
+	// Ephemeral events are received on a plain NATS subscription which has
+	// no queue group, offset, or acks.
+	if opts.Ephemeral {
+		nsub, err := c.nats.Subscribe(stream, func(m *nats.Msg) {
+			msgHandler(&rawMsg{
+				subject:   m.Subject,
+				data:      m.Data,
+				timestamp: time.Now().UnixNano(),
+				ephemeral: true,
+				ack:       func() error { return nil },
+			})
+		})
 		if err != nil {
-			c.logger.Printf("[%s] proto unmarshal failed: %s", c.client, err)
-			return
-		}
-
-		dec := decodable{
-			b:   e.Data,
-			t:   e.Encoding,
-			e:   true,
-			enc: encMap[e.Encoding],
-		}
-
-		evt := &Event{
-			Stream:    msg.Subject,
-			ID:        e.Id,
-			Time:      time.Unix(0, e.Time),
-			Type:      e.Type,
-			Cause:     e.Cause,
-			Client:    e.Client,
-			Data:      &dec,
-			Meta:      e.Meta,
-			Aggregate: e.Aggregate,
-			msg:       msg,
-		}
-
-		// Use stored ack time if set.
-		if e.AckTime > 0 {
-			evt.AckTime = time.Unix(0, e.AckTime)
-		} else {
-			evt.AckTime = time.Unix(0, msg.Timestamp)
-		}
-
-		// Use ack timeout as max context timeout to signal handler components.
-		ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
-		defer cancel()
-
-		// Recover and log handler panic.
-		defer func() {
-			if err := recover(); err != nil {
-				c.logger.Printf("[%s] recovered handler panic: %s", c.client, err)
-			}
-		}()
-
-		// Handler error implies a timeout or implementation issue.
-		if err := handle(ctx, evt); err != nil {
-			c.logger.Printf("[%s] handler error: %s", c.client, err)
-			return
-		}
-
-		// Couldn't acknowledge the event has been handled.
-		// Bad subscription or bad connection.
-		if err := msg.Ack(); err != nil {
-			c.logger.Printf("[%s] ack failed: %s", c.client, err)
+			return nil, err
 		}
-	}
 
-	// Map start position.
-	var startPos stanpb.StartPosition
+		sub.sub = &natsSubscription{nsub}
 
-	if opts.Backfill {
-		startPos = stanpb.StartPosition_First
-	} else {
-		startPos = stanpb.StartPosition_NewOnly
+		return sub, nil
 	}
 
 	subOpts := []stan.SubscriptionOption{
-		// Set the initial start position.
-		stan.StartAt(startPos),
-
 		// Use manual acks to manage errors.
 		stan.SetManualAckMode(),
 	}
 
+	// Set the initial start position.
+	switch {
+	case opts.StartSeq > 0:
+		subOpts = append(subOpts, stan.StartAtSequence(opts.StartSeq))
+	case opts.StartTime != nil:
+		subOpts = append(subOpts, stan.StartAtTime(*opts.StartTime))
+	case opts.Backfill:
+		subOpts = append(subOpts, stan.StartAt(stanpb.StartPosition_First))
+	default:
+		subOpts = append(subOpts, stan.StartAt(stanpb.StartPosition_NewOnly))
+	}
+
 	// Length of time to wait before the server resends the message.
 	if opts.Timeout > 0 {
 		subOpts = append(subOpts, stan.AckWait(opts.Timeout))
@@ -239,24 +200,241 @@ func (c *stanConn) Subscribe(stream string, handle Handler, opts *SubscriptionOp
 	qsub, err := c.stan.QueueSubscribe(
 		stream,
 		consumerName,
-		msgHandler,
+		func(m *stan.Msg) {
+			msgHandler(&rawMsg{
+				subject:   m.Subject,
+				seq:       m.Sequence,
+				data:      m.Data,
+				timestamp: m.Timestamp,
+				ack:       m.Ack,
+				stan:      m,
+			})
+		},
 		subOpts...,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	sub := &stanSubscription{
-		channel:  stream,
-		consumer: consumerName,
-		conn:     c,
-		sub:      qsub,
-		durable:  opts.Durable,
-	}
+	sub.sub = qsub
 
 	return sub, nil
 }
 
+// channelz is the channel info returned by the monitoring endpoint.
+type channelz struct {
+	Msgs     int64  `json:"msgs"`
+	Bytes    int64  `json:"bytes"`
+	FirstSeq uint64 `json:"first_seq"`
+	LastSeq  uint64 `json:"last_seq"`
+}
+
+// errChannelNotFound is returned by channelInfo if the channel does not exist.
+var errChannelNotFound = errors.New("channel not found")
+
+// channelInfo returns the info of the channel from the monitoring endpoint.
+func (c *stanConn) channelInfo(ctx context.Context, stream string) (*channelz, error) {
+	u := fmt.Sprintf("%s/streaming/channelsz?channel=%s", c.monitor, url.QueryEscape(stream))
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("stream stats for %s: %w", stream, errChannelNotFound)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("stream stats for %s: %s", stream, resp.Status)
+	}
+
+	var cz channelz
+	if err := json.NewDecoder(resp.Body).Decode(&cz); err != nil {
+		return nil, err
+	}
+
+	return &cz, nil
+}
+
+func (c *stanConn) StreamStats(ctx context.Context, stream string) (*StreamStats, error) {
+	if c.monitor == "" {
+		return nil, errors.New("monitor address required for stream stats")
+	}
+
+	cz, err := c.channelInfo(ctx, stream)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &StreamStats{
+		FirstSeq: cz.FirstSeq,
+		LastSeq:  cz.LastSeq,
+		MsgCount: cz.Msgs,
+		ByteSize: cz.Bytes,
+	}
+
+	// Times are not provided by the monitoring endpoint so peek at the
+	// first and last events.
+	if cz.Msgs > 0 {
+		if stats.FirstTime, err = c.peekTime(ctx, stream, cz.FirstSeq); err != nil {
+			return nil, err
+		}
+
+		if stats.LastTime, err = c.peekTime(ctx, stream, cz.LastSeq); err != nil {
+			return nil, err
+		}
+	}
+
+	return stats, nil
+}
+
+func (c *stanConn) MultiStreamStats(ctx context.Context, streams []string) (map[string]*StreamStats, error) {
+	m := make(map[string]*StreamStats, len(streams))
+
+	for _, stream := range streams {
+		stats, err := c.StreamStats(ctx, stream)
+		if err != nil {
+			return nil, err
+		}
+
+		m[stream] = stats
+	}
+
+	return m, nil
+}
+
+// readBuffer is the number of unread messages buffered by an iterator.
+const readBuffer = 64
+
+// Read reads the events in the stream with a short-lived subscription.
+// The monitor address is required to get the last sequence of the stream
+// unless a To sequence is set.
+func (c *stanConn) Read(stream string, opts *ReadOptions) (EventIterator, error) {
+	if opts == nil {
+		opts = &ReadOptions{}
+	}
+
+	last := opts.To.Seq
+
+	if c.monitor != "" {
+		cz, err := c.channelInfo(context.Background(), stream)
+		if errors.Is(err, errChannelNotFound) {
+			// The channel is not created until the first event is published.
+			return emptyEventIterator(opts), nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		last = lastReadSeq(opts, cz.LastSeq)
+	} else if last == 0 {
+		return nil, errors.New("monitor address or To sequence required for read")
+	}
+
+	if last == 0 || opts.From.Seq > last {
+		return emptyEventIterator(opts), nil
+	}
+
+	ch := make(chan *stan.Msg, readBuffer)
+
+	subOpts := []stan.SubscriptionOption{
+		stan.SetManualAckMode(),
+		stan.MaxInflight(readBuffer),
+	}
+
+	switch {
+	case opts.From.Seq > 0:
+		subOpts = append(subOpts, stan.StartAtSequence(opts.From.Seq))
+	case !opts.From.Time.IsZero():
+		subOpts = append(subOpts, stan.StartAtTime(opts.From.Time))
+	default:
+		subOpts = append(subOpts, stan.DeliverAllAvailable())
+	}
+
+	sub, err := c.stan.Subscribe(stream, func(m *stan.Msg) {
+		// Messages that do not fit are redelivered.
+		select {
+		case ch <- m:
+		default:
+		}
+	}, subOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var prev uint64
+
+	next := func(ctx context.Context) (*Event, error) {
+		for {
+			if prev >= last {
+				return nil, io.EOF
+			}
+
+			select {
+			case m := <-ch:
+				// Messages are acked once read so they are not redelivered
+				// while buffered.
+				m.Ack()
+
+				// Skip redelivered messages.
+				if m.Sequence <= prev {
+					continue
+				}
+
+				if m.Sequence > last {
+					prev = last
+					return nil, io.EOF
+				}
+
+				prev = m.Sequence
+
+				return c.decode(&rawMsg{
+					subject:   m.Subject,
+					seq:       m.Sequence,
+					data:      m.Data,
+					timestamp: m.Timestamp,
+					stan:      m,
+				})
+
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	return NewEventIterator(next, sub.Unsubscribe, opts), nil
+}
+
+// peekTime returns the server timestamp of the message at the sequence
+// using a short-lived subscription.
+func (c *stanConn) peekTime(ctx context.Context, stream string, seq uint64) (time.Time, error) {
+	ch := make(chan int64, 1)
+
+	sub, err := c.stan.Subscribe(stream, func(msg *stan.Msg) {
+		select {
+		case ch <- msg.Timestamp:
+		default:
+		}
+	}, stan.StartAtSequence(seq))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer sub.Unsubscribe()
+
+	select {
+	case ts := <-ch:
+		return time.Unix(0, ts), nil
+	case <-ctx.Done():
+		return time.Time{}, ctx.Err()
+	}
+}
+
 // Logger is a minimal interface required for internal logging.
 // This is compatible with the stdlib log.Logger type.
 type Logger interface {
@@ -266,6 +444,46 @@ type Logger interface {
 
 type ConnectOptions struct {
 	Logger Logger
+
+	// SlogLogger is a structured logger for internal logging. This takes
+	// precedence over Logger.
+	SlogLogger *slog.Logger
+
+	// StructuredLogger is a structured logger for internal logging. This
+	// takes precedence over Logger, but not SlogLogger.
+	StructuredLogger StructuredLogger
+
+	// MonitorAddr is the base URL of the server's monitoring endpoint,
+	// e.g. http://localhost:8222. This is required for stream stats.
+	MonitorAddr string
+
+	// EnvelopeCodec is the name of the format used to encode the event
+	// envelope. Supported formats are "proto" (default) and "json".
+	EnvelopeCodec string
+
+	// LamportClock is a logical clock used to stamp published events and which
+	// is advanced by received events.
+	LamportClock *LamportClock
+
+	// Clock returns the time set on published events without a time.
+	// This defaults to time.Now.
+	Clock func() time.Time
+
+	// NodeID enables a vector clock for the node which is stored in the
+	// meta of published events and merged from received events.
+	NodeID string
+
+	// RequiredFields are the names of event fields that must be non-empty
+	// for an event to be published. See WithRequiredEventFields.
+	RequiredFields []string
+
+	// EncryptionKey is used to decrypt received data encrypted with
+	// EncryptedJSON. See WithEncryptionKey.
+	EncryptionKey []byte
+
+	// JetStream uses NATS JetStream as the backend rather than NATS
+	// Streaming. See WithJetStream.
+	JetStream bool
 }
 
 func (o *ConnectOptions) Apply(opts ...ConnectOption) {
@@ -282,10 +500,99 @@ func WithLogger(l Logger) ConnectOption {
 	}
 }
 
+// WithSlogLogger sets a structured logger for internal logging.
+func WithSlogLogger(l *slog.Logger) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.SlogLogger = l
+	}
+}
+
+// WithStructuredLogger sets a structured logger for internal logging.
+// Error entries are written with Error and all others with Info.
+func WithStructuredLogger(l StructuredLogger) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.StructuredLogger = l
+	}
+}
+
+// WithMonitorAddr sets the base URL of the server's monitoring endpoint.
+func WithMonitorAddr(addr string) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.MonitorAddr = strings.TrimSuffix(addr, "/")
+	}
+}
+
+// WithEnvelopeCodec sets the format used to encode the event envelope.
+// Events are decoded based on their own format, so streams may contain
+// a mix of formats.
+func WithEnvelopeCodec(name string) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.EnvelopeCodec = name
+	}
+}
+
+// WithLamportClock sets the clock used to stamp published events with a
+// Lamport time. Events received on subscriptions of the connection advance
+// the clock.
+func WithLamportClock(clock *LamportClock) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.LamportClock = clock
+	}
+}
+
+// WithClock sets the function used for the time of published events
+// that do not have a time set. See FrozenClock and AdvancingClock for
+// deterministic times in tests.
+func WithClock(fn func() time.Time) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.Clock = fn
+	}
+}
+
+// WithVectorClock enables a vector clock for the node. The clock is
+// incremented and stored in the meta of published events as JSON and is
+// merged with the clocks of events received on subscriptions of the
+// connection. Use VectorClockFromEvent to read the clock of an event.
+func WithVectorClock(nodeID string) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.NodeID = nodeID
+	}
+}
+
+// WithRequiredEventFields requires the named event fields to be set on
+// publish, otherwise an ErrMissingField error is returned. Supported fields
+// are "type", "aggregate", "cause", and "correlation_id", which is read from
+// the CorrelationMetaKey meta key.
+func WithRequiredEventFields(fields ...string) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.RequiredFields = append(o.RequiredFields, fields...)
+	}
+}
+
+// WithEncryptionKey sets the AES-256 key used to decrypt received data
+// encrypted with EncryptedJSON, so handlers can decode it as usual.
+func WithEncryptionKey(key []byte) ConnectOption {
+	return func(o *ConnectOptions) {
+		o.EncryptionKey = key
+	}
+}
+
+// WithJetStream uses NATS JetStream as the backend rather than NATS
+// Streaming. The cluster ID passed to Connect is ignored. Streams and
+// durable consumers are created as needed, with the stream name used
+// as the subject.
+func WithJetStream() ConnectOption {
+	return func(o *ConnectOptions) {
+		o.JetStream = true
+	}
+}
+
 // Connect establishes a connection to the streaming backend.
 func Connect(addr, cluster, client string, opts ...ConnectOption) (Conn, error) {
 	o := &ConnectOptions{
-		Logger: log.New(os.Stderr, "[eda] ", log.LstdFlags),
+		Logger:        log.New(os.Stderr, "[eda] ", log.LstdFlags),
+		EnvelopeCodec: "proto",
+		Clock:         time.Now,
 	}
 
 	o.Apply(opts...)
@@ -295,6 +602,51 @@ func Connect(addr, cluster, client string, opts ...ConnectOption) (Conn, error)
 		o.Logger = log.New(ioutil.Discard, "", 0)
 	}
 
+	if o.Clock == nil {
+		o.Clock = time.Now
+	}
+
+	logger := o.SlogLogger
+	if logger == nil {
+		if o.StructuredLogger != nil {
+			logger = slog.New(&structuredHandler{logger: o.StructuredLogger})
+		} else {
+			logger = slog.New(&loggerHandler{logger: o.Logger})
+		}
+	}
+
+	for _, f := range o.RequiredFields {
+		if _, ok := eventFields[f]; !ok {
+			return nil, fmt.Errorf("unknown required event field: %s", f)
+		}
+	}
+
+	envelope, ok := envelopeCodecs[o.EnvelopeCodec]
+	if !ok {
+		return nil, fmt.Errorf("unknown envelope codec: %s", o.EnvelopeCodec)
+	}
+
+	base := baseConn{
+		client:   client,
+		logger:   logger.With(slog.String("client", client)),
+		required: o.RequiredFields,
+		envelope: envelope,
+		now:      o.Clock,
+		clock:    o.LamportClock,
+		key:      o.EncryptionKey,
+	}
+
+	if o.NodeID != "" {
+		base.vclock = &nodeClock{
+			node:  o.NodeID,
+			clock: VectorClock{},
+		}
+	}
+
+	if o.JetStream {
+		return connectJetStream(addr, base)
+	}
+
 	nc, err := nats.Connect(
 		addr,
 		// Try reconnecting indefinitely.
@@ -311,12 +663,15 @@ func Connect(addr, cluster, client string, opts ...ConnectOption) (Conn, error)
 	}
 
 	conn := stanConn{
-		client:  client,
-		cluster: cluster,
-		logger:  o.Logger,
-		stan:    snc,
-		nats:    nc,
+		baseConn: base,
+		cluster:  cluster,
+		monitor:  o.MonitorAddr,
+		stan:     snc,
+		nats:     nc,
 	}
 
+	conn.publish = conn.Publish
+	conn.respond = nc.Publish
+
 	return &conn, nil
 }
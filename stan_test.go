@@ -2,8 +2,13 @@ package eda
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/nats-io/nuid"
 )
 
 var (
@@ -11,6 +16,7 @@ var (
 	cluster string
 	client  string
 	stream  string
+	monitor string
 )
 
 func init() {
@@ -18,6 +24,7 @@ func init() {
 	flag.StringVar(&cluster, "cluster", "test-cluster", "NATS cluster name.")
 	flag.StringVar(&client, "client", "test-client", "Client connection ID.")
 	flag.StringVar(&stream, "stream", "test-stream", "Stream name.")
+	flag.StringVar(&monitor, "monitor", "http://localhost:8222", "NATS monitoring address.")
 }
 
 func TestSubscribe(t *testing.T) {
@@ -39,3 +46,619 @@ func TestSubscribe(t *testing.T) {
 	}
 	defer sub.Close()
 }
+
+func TestSubscribeLastValueCache(t *testing.T) {
+	conn, err := Connect(addr, cluster, client)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+
+	handle := func(ctx context.Context, evt *Event) error {
+		if evt.Type == "done" {
+			close(done)
+		}
+		return nil
+	}
+
+	sub, err := conn.Subscribe(stream, handle, &SubscriptionOptions{
+		LastValueCache: true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer sub.Close()
+
+	for _, typ := range []string{"first", "second", "done"} {
+		if _, err := conn.Publish(stream, &Event{
+			Type:      typ,
+			Aggregate: "foo",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for events")
+	}
+
+	evt, ok := sub.GetLatest("foo")
+	if !ok {
+		t.Fatal("expected cached event")
+	}
+
+	if evt.Type != "done" {
+		t.Fatalf("expected latest type done, got %s", evt.Type)
+	}
+}
+
+func TestPublishRequiredFields(t *testing.T) {
+	conn, err := Connect(addr, cluster, client, WithRequiredEventFields("type"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	_, err = conn.Publish(stream, &Event{})
+	if e, ok := err.(*ErrMissingField); !ok || e.Field != "type" {
+		t.Fatalf("expected missing type field error, got %v", err)
+	}
+
+	if _, err := conn.Publish(stream, &Event{Type: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPublishRequiredCorrelationID(t *testing.T) {
+	conn, err := Connect(addr, cluster, client, WithRequiredEventFields("correlation_id"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	_, err = conn.Publish(stream, &Event{Type: "foo"})
+	if e, ok := err.(*ErrMissingField); !ok || e.Field != "correlation_id" {
+		t.Fatalf("expected missing correlation_id field error, got %v", err)
+	}
+
+	if _, err := conn.Publish(stream, &Event{
+		Type: "foo",
+		Meta: map[string]string{CorrelationMetaKey: "1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamStats(t *testing.T) {
+	conn, err := Connect(addr, cluster, client, WithMonitorAddr(monitor))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Publish(stream, &Event{Type: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stats, err := conn.StreamStats(ctx, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.MsgCount == 0 || stats.LastSeq < stats.FirstSeq {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	if stats.LastTime.Before(stats.FirstTime) {
+		t.Fatalf("last time before first time: %+v", stats)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	durations := []time.Duration{
+		time.Second,
+		2 * time.Second,
+	}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{5, 2 * time.Second},
+	}
+
+	for _, test := range tests {
+		d := backoff(durations, test.attempt)
+
+		if d < test.max/2 || d > test.max {
+			t.Errorf("attempt %d: expected backoff between %s and %s, got %s", test.attempt, test.max/2, test.max, d)
+		}
+	}
+}
+
+func TestSubscribeManualACK(t *testing.T) {
+	conn, err := Connect(addr, cluster, client)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	acked := make(chan error, 1)
+
+	handle := func(ctx context.Context, evt *Event) error {
+		acker, ok := AckerFromContext(ctx)
+		if !ok {
+			acked <- errors.New("no acker in context")
+			return nil
+		}
+
+		acked <- acker.Ack()
+		return nil
+	}
+
+	sub, err := conn.Subscribe(stream, handle, &SubscriptionOptions{
+		ManualACK: true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer sub.Close()
+
+	if _, err := conn.Publish(stream, &Event{Type: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-acked:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+}
+
+func TestEphemeral(t *testing.T) {
+	conn, err := Connect(addr, cluster, client)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	received := make(chan *Event, 2)
+
+	handle := func(ctx context.Context, evt *Event) error {
+		received <- evt
+		return nil
+	}
+
+	sub, err := conn.Subscribe(stream, handle, &SubscriptionOptions{
+		Ephemeral: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	if _, err := conn.Publish(stream, &Event{Type: "persisted"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := conn.Publish(stream, &Event{Type: "ephemeral", Ephemeral: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case evt := <-received:
+		if evt.Type != "ephemeral" || !evt.Ephemeral {
+			t.Fatalf("expected ephemeral event, got %s", evt.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+}
+
+func TestDeadLetterStream(t *testing.T) {
+	conn, err := Connect(addr, cluster, client)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	dlq := stream + "-dlq"
+	dead := make(chan *Event, 1)
+
+	dsub, err := conn.Subscribe(dlq, func(ctx context.Context, evt *Event) error {
+		dead <- evt
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dsub.Close()
+
+	sub, err := conn.Subscribe(stream, func(ctx context.Context, evt *Event) error {
+		return errors.New("permanent failure")
+	}, &SubscriptionOptions{
+		DeadLetterStream: dlq,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	id, err := conn.Publish(stream, &Event{Type: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case evt := <-dead:
+		if evt.Type != "foo" {
+			t.Errorf("expected foo type, got %s", evt.Type)
+		}
+
+		expected := map[string]string{
+			"dlq.original_stream": stream,
+			"dlq.original_id":     id,
+			"dlq.error":           "permanent failure",
+			"dlq.attempt_count":   "1",
+		}
+
+		for k, v := range expected {
+			if evt.Meta[k] != v {
+				t.Errorf("expected %s=%s, got %q", k, v, evt.Meta[k])
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not dead-lettered")
+	}
+}
+
+func TestEncryptionKey(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	conn, err := Connect(addr, cluster, client, WithEncryptionKey(key))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	decoded := make(chan string, 1)
+
+	sub, err := conn.Subscribe(stream, func(ctx context.Context, evt *Event) error {
+		if evt.Type != "encrypted" {
+			return nil
+		}
+
+		var v string
+		if err := evt.Data.Decode(&v); err != nil {
+			return err
+		}
+
+		decoded <- v
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	if _, err := conn.Publish(stream, &Event{
+		Type: "encrypted",
+		Data: EncryptedJSON("secret", key),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case v := <-decoded:
+		if v != "secret" {
+			t.Errorf("expected secret, got %q", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not decoded")
+	}
+}
+
+func TestSubscribeStartPosition(t *testing.T) {
+	conn, err := Connect(addr, cluster, client)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	stream := stream + "-start-" + nuid.Next()
+
+	for _, typ := range []string{"a", "b", "c"} {
+		if _, err := conn.Publish(stream, &Event{Type: typ}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+
+	_, err = conn.Subscribe(stream, nil, &SubscriptionOptions{
+		Backfill:  true,
+		StartTime: &now,
+	})
+	if err != ErrStartPositionConflict {
+		t.Fatalf("expected start position conflict, got %v", err)
+	}
+
+	received := make(chan *Event, 3)
+
+	handle := func(ctx context.Context, evt *Event) error {
+		received <- evt
+		return nil
+	}
+
+	// Read the last sequence to start from the second to last event.
+	var last *Event
+	sub, err := conn.Subscribe(stream, handle, &SubscriptionOptions{Backfill: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case last = <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("event not received")
+		}
+	}
+	sub.Close()
+
+	sub, err = conn.Subscribe(stream, handle, &SubscriptionOptions{StartSeq: last.Seq - 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	for _, exp := range []string{"b", "c"} {
+		select {
+		case evt := <-received:
+			if evt.Type != exp {
+				t.Errorf("expected event %s, got %s", exp, evt.Type)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("event not received")
+		}
+	}
+}
+
+func TestSubscribeMaxConcurrency(t *testing.T) {
+	conn, err := Connect(addr, cluster, client)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	stream := stream + "-concurrency"
+
+	var (
+		mux      sync.Mutex
+		running  int
+		max      int
+		handled  int
+		received = make(chan struct{}, 6)
+	)
+
+	handle := func(ctx context.Context, evt *Event) error {
+		mux.Lock()
+		running++
+		if running > max {
+			max = running
+		}
+		mux.Unlock()
+
+		received <- struct{}{}
+		time.Sleep(50 * time.Millisecond)
+
+		mux.Lock()
+		running--
+		handled++
+		mux.Unlock()
+
+		return nil
+	}
+
+	sub, err := conn.Subscribe(stream, handle, &SubscriptionOptions{
+		MaxConcurrency: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 6; i++ {
+		if _, err := conn.Publish(stream, &Event{Type: "foo"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 6; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("event not received")
+		}
+	}
+
+	// Close waits for the running handlers.
+	sub.Close()
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	if handled != 6 {
+		t.Errorf("expected 6 events handled on close, got %d", handled)
+	}
+
+	if max < 2 || max > 3 {
+		t.Errorf("expected 2 to 3 concurrent handlers, got %d", max)
+	}
+}
+
+func TestSubscribeTypeFilter(t *testing.T) {
+	conn, err := Connect(addr, cluster, client)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	stream := stream + "-filter-" + nuid.Next()
+
+	received := make(chan *Event, 3)
+
+	handle := func(ctx context.Context, evt *Event) error {
+		received <- evt
+		return nil
+	}
+
+	opts := &SubscriptionOptions{
+		Name:        "filtered",
+		Durable:     true,
+		TypeFilter:  []string{"a", "b"},
+		TypeExclude: []string{"a"},
+	}
+
+	sub, err := conn.Subscribe(stream, handle, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, typ := range []string{"a", "c", "b"} {
+		if _, err := conn.Publish(stream, &Event{Type: typ}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case evt := <-received:
+		if evt.Type != "b" {
+			t.Fatalf("expected event b, got %s", evt.Type)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not received")
+	}
+
+	sub.Close()
+
+	// Filtered events were acked, so they are not redelivered when the
+	// durable subscription resumes without the filters.
+	sub, err = conn.Subscribe(stream, handle, &SubscriptionOptions{
+		Name:    "filtered",
+		Durable: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	if _, err := conn.Publish(stream, &Event{Type: "d"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case evt := <-received:
+		if evt.Type != "d" {
+			t.Fatalf("expected event d, got %s", evt.Type)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not received")
+	}
+}
+
+func TestSubscribePause(t *testing.T) {
+	conn, err := Connect(addr, cluster, client)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	stream := stream + "-pause-" + nuid.Next()
+
+	received := make(chan *Event, 4)
+
+	sub, err := conn.Subscribe(stream, func(ctx context.Context, evt *Event) error {
+		received <- evt
+		return nil
+	}, &SubscriptionOptions{
+		PauseBuffer: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sub.Pause(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !sub.IsPaused() {
+		t.Error("expected subscription to be paused")
+	}
+
+	// The third event blocks delivery once the buffer is full.
+	for _, typ := range []string{"a", "b", "c"} {
+		if _, err := conn.Publish(stream, &Event{Type: typ}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case evt := <-received:
+		t.Fatalf("event %s received while paused", evt.Type)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := sub.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sub.IsPaused() {
+		t.Error("expected subscription to be resumed")
+	}
+
+	if _, err := conn.Publish(stream, &Event{Type: "d"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, typ := range []string{"a", "b", "c", "d"} {
+		select {
+		case evt := <-received:
+			if evt.Type != typ {
+				t.Fatalf("expected event %s, got %s", typ, evt.Type)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("event %s not received", typ)
+		}
+	}
+
+	sub.Unsubscribe()
+
+	if err := sub.Pause(); err != ErrSubscriptionClosed {
+		t.Errorf("expected ErrSubscriptionClosed, got %v", err)
+	}
+}
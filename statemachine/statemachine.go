@@ -0,0 +1,270 @@
+/*
+Package statemachine implements an event-driven state machine. State is
+tracked per correlation ID and transitions occur when events of a given
+type are received in a given state.
+
+	sm := statemachine.New("pending", statemachine.NewMemStateStore())
+
+	sm.State("pending").On("order-paid").TransitionTo("paid").Do(notify)
+	sm.State("paid").On("order-shipped").TransitionTo("shipped")
+
+	err := sm.Drive(ctx, conn, "orders")
+*/
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/chop-dbhi/eda"
+)
+
+// ErrNotFound is returned by a StateStore if there is no state for the
+// correlation ID.
+var ErrNotFound = errors.New("state not found")
+
+// Action is performed when a transition occurs. If it returns an error,
+// the transition does not occur.
+type Action func(ctx context.Context, evt *eda.Event) error
+
+// StateStore persists the current state per correlation ID.
+type StateStore interface {
+	// Get returns the state for the correlation ID or ErrNotFound.
+	Get(ctx context.Context, correlationID string) (string, error)
+
+	// Set sets the state for the correlation ID.
+	Set(ctx context.Context, correlationID, state string) error
+}
+
+// Transition is a transition from a state triggered by an event type.
+type Transition struct {
+	event  string
+	to     string
+	action Action
+}
+
+// TransitionTo sets the state to transition to.
+func (t *Transition) TransitionTo(state string) *Transition {
+	t.to = state
+	return t
+}
+
+// Do sets the action performed when the transition occurs.
+func (t *Transition) Do(action Action) *Transition {
+	t.action = action
+	return t
+}
+
+// StateBuilder defines the transitions from a state.
+type StateBuilder struct {
+	name        string
+	transitions []*Transition
+}
+
+// On returns the transition for the event type, creating it if needed.
+func (s *StateBuilder) On(eventType string) *Transition {
+	for _, t := range s.transitions {
+		if t.event == eventType {
+			return t
+		}
+	}
+
+	t := &Transition{
+		event: eventType,
+	}
+
+	s.transitions = append(s.transitions, t)
+
+	return t
+}
+
+func (s *StateBuilder) transition(eventType string) *Transition {
+	for _, t := range s.transitions {
+		if t.event == eventType && t.to != "" {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// SM is a state machine.
+type SM struct {
+	// CorrelationFn returns the correlation ID of an event. This defaults
+	// to the event aggregate. Events without a correlation ID are ignored.
+	CorrelationFn func(*eda.Event) string
+
+	initial string
+	store   StateStore
+
+	mux    sync.Mutex
+	states map[string]*StateBuilder
+	order  []string
+}
+
+// State returns the builder for the state, creating it if needed.
+func (sm *SM) State(name string) *StateBuilder {
+	sm.mux.Lock()
+	defer sm.mux.Unlock()
+
+	return sm.state(name)
+}
+
+func (sm *SM) state(name string) *StateBuilder {
+	s, ok := sm.states[name]
+	if !ok {
+		s = &StateBuilder{name: name}
+		sm.states[name] = s
+		sm.order = append(sm.order, name)
+	}
+
+	return s
+}
+
+// Current returns the current state for the correlation ID. This is the
+// initial state if no transitions have occurred.
+func (sm *SM) Current(correlationID string) (string, error) {
+	return sm.current(context.Background(), correlationID)
+}
+
+func (sm *SM) current(ctx context.Context, correlationID string) (string, error) {
+	state, err := sm.store.Get(ctx, correlationID)
+	if err == ErrNotFound {
+		return sm.initial, nil
+	}
+
+	return state, err
+}
+
+// Handle transitions the state for the event's correlation ID if there is
+// a transition for the event type in the current state. Events without
+// a transition are ignored.
+func (sm *SM) Handle(ctx context.Context, evt *eda.Event) error {
+	id := sm.CorrelationFn(evt)
+	if id == "" {
+		return nil
+	}
+
+	sm.mux.Lock()
+	defer sm.mux.Unlock()
+
+	cur, err := sm.current(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	s, ok := sm.states[cur]
+	if !ok {
+		return nil
+	}
+
+	t := s.transition(evt.Type)
+	if t == nil {
+		return nil
+	}
+
+	if t.action != nil {
+		if err := t.action(ctx, evt); err != nil {
+			return err
+		}
+	}
+
+	return sm.store.Set(ctx, id, t.to)
+}
+
+// Drive subscribes to the stream and transitions states as events are
+// received. Events are handled serially. This blocks until the context
+// is done.
+func (sm *SM) Drive(ctx context.Context, conn eda.Conn, stream string) error {
+	sub, err := conn.Subscribe(stream, sm.Handle, &eda.SubscriptionOptions{
+		Serial: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	<-ctx.Done()
+
+	return nil
+}
+
+// DOT writes a Graphviz diagram of the state machine.
+func (sm *SM) DOT(w io.Writer) error {
+	sm.mux.Lock()
+	defer sm.mux.Unlock()
+
+	if _, err := fmt.Fprintln(w, "digraph {"); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "\t%q [shape=doublecircle];\n", sm.initial); err != nil {
+		return err
+	}
+
+	for _, name := range sm.order {
+		for _, t := range sm.states[name].transitions {
+			if t.to == "" {
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "\t%q -> %q [label=%q];\n", name, t.to, t.event); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// New returns a state machine which starts in the initial state.
+func New(initial string, store StateStore) *SM {
+	sm := &SM{
+		CorrelationFn: func(evt *eda.Event) string {
+			return evt.Aggregate
+		},
+		initial: initial,
+		store:   store,
+		states:  make(map[string]*StateBuilder),
+	}
+
+	sm.state(initial)
+
+	return sm
+}
+
+type memStateStore struct {
+	mux    sync.RWMutex
+	states map[string]string
+}
+
+func (s *memStateStore) Get(ctx context.Context, correlationID string) (string, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	state, ok := s.states[correlationID]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return state, nil
+}
+
+func (s *memStateStore) Set(ctx context.Context, correlationID, state string) error {
+	s.mux.Lock()
+	s.states[correlationID] = state
+	s.mux.Unlock()
+
+	return nil
+}
+
+// NewMemStateStore returns a StateStore that keeps state in memory.
+func NewMemStateStore() StateStore {
+	return &memStateStore{
+		states: make(map[string]string),
+	}
+}
@@ -0,0 +1,102 @@
+package statemachine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/chop-dbhi/eda"
+)
+
+func newOrderSM(notified *int) *SM {
+	sm := New("pending", NewMemStateStore())
+
+	sm.State("pending").On("order-paid").TransitionTo("paid").Do(func(ctx context.Context, evt *eda.Event) error {
+		*notified++
+		return nil
+	})
+
+	sm.State("paid").On("order-shipped").TransitionTo("shipped")
+
+	return sm
+}
+
+func TestStateMachine(t *testing.T) {
+	var notified int
+	sm := newOrderSM(&notified)
+
+	ctx := context.Background()
+
+	events := []*eda.Event{
+		{Type: "order-shipped", Aggregate: "1"},
+		{Type: "order-paid", Aggregate: "1"},
+		{Type: "order-paid", Aggregate: "1"},
+		{Type: "order-shipped", Aggregate: "1"},
+		{Type: "order-paid", Aggregate: "2"},
+	}
+
+	for _, evt := range events {
+		if err := sm.Handle(ctx, evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]string{
+		"1": "shipped",
+		"2": "paid",
+		"3": "pending",
+	}
+
+	for id, exp := range tests {
+		state, err := sm.Current(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if state != exp {
+			t.Errorf("%s: expected %s, got %s", id, exp, state)
+		}
+	}
+
+	if notified != 2 {
+		t.Errorf("expected 2 actions, got %d", notified)
+	}
+}
+
+func TestStateMachineActionError(t *testing.T) {
+	sm := New("pending", NewMemStateStore())
+
+	sm.State("pending").On("order-paid").TransitionTo("paid").Do(func(ctx context.Context, evt *eda.Event) error {
+		return errors.New("failed")
+	})
+
+	if err := sm.Handle(context.Background(), &eda.Event{Type: "order-paid", Aggregate: "1"}); err == nil {
+		t.Fatal("expected action error")
+	}
+
+	if state, _ := sm.Current("1"); state != "pending" {
+		t.Fatalf("expected pending, got %s", state)
+	}
+}
+
+func TestDOT(t *testing.T) {
+	var notified int
+	sm := newOrderSM(&notified)
+
+	var buf bytes.Buffer
+	if err := sm.DOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `digraph {
+	"pending" [shape=doublecircle];
+	"pending" -> "paid" [label="order-paid"];
+	"paid" -> "shipped" [label="order-shipped"];
+}
+`
+
+	if buf.String() != exp {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, buf.String())
+	}
+}
@@ -0,0 +1,195 @@
+package eda
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ChangeType is the kind of change made to a field.
+type ChangeType int
+
+const (
+	FieldAdded ChangeType = iota
+	FieldRemoved
+	FieldTypeChanged
+)
+
+func (c ChangeType) String() string {
+	switch c {
+	case FieldAdded:
+		return "added"
+	case FieldRemoved:
+		return "removed"
+	case FieldTypeChanged:
+		return "type changed"
+	}
+
+	return "unknown"
+}
+
+// FieldChange describes a change to a field between two versions of an
+// event type. OldType is nil for added fields and NewType is nil for
+// removed fields.
+type FieldChange struct {
+	// Name is the encoded name of the field. Nested fields are
+	// dot-separated.
+	Name string
+
+	Change  ChangeType
+	OldType reflect.Type
+	NewType reflect.Type
+}
+
+// Breaking returns true if the change is not backward compatible.
+func (c FieldChange) Breaking() bool {
+	return c.Change == FieldRemoved || c.Change == FieldTypeChanged
+}
+
+func (c FieldChange) String() string {
+	switch c.Change {
+	case FieldAdded:
+		return fmt.Sprintf("%s: added (%s)", c.Name, c.NewType)
+	case FieldRemoved:
+		return fmt.Sprintf("%s: removed (%s)", c.Name, c.OldType)
+	}
+
+	return fmt.Sprintf("%s: type changed (%s -> %s)", c.Name, c.OldType, c.NewType)
+}
+
+// DiffEventTypes compares two versions of an event data struct type and
+// returns the fields that were added, removed, or changed type. Fields are
+// compared by their JSON name and types are compared by their structure
+// so equivalent types declared in different packages are not reported.
+func DiffEventTypes(v1, v2 reflect.Type) ([]FieldChange, error) {
+	v1 = indirectType(v1)
+	v2 = indirectType(v2)
+
+	if v1.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("struct type required: %s", v1)
+	}
+
+	if v2.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("struct type required: %s", v2)
+	}
+
+	var changes []FieldChange
+	diffStructs("", v1, v2, &changes)
+
+	return changes, nil
+}
+
+func diffStructs(prefix string, v1, v2 reflect.Type, changes *[]FieldChange) {
+	f1 := structFields(v1)
+	f2 := structFields(v2)
+
+	for _, f := range f1 {
+		name := prefix + f.name
+
+		g, ok := fieldByName(f2, f.name)
+		if !ok {
+			*changes = append(*changes, FieldChange{
+				Name:    name,
+				Change:  FieldRemoved,
+				OldType: f.typ,
+			})
+			continue
+		}
+
+		t1 := indirectType(f.typ)
+		t2 := indirectType(g.typ)
+
+		if t1.Kind() == reflect.Struct && t2.Kind() == reflect.Struct {
+			diffStructs(name+".", t1, t2, changes)
+			continue
+		}
+
+		if typeShape(t1) != typeShape(t2) {
+			*changes = append(*changes, FieldChange{
+				Name:    name,
+				Change:  FieldTypeChanged,
+				OldType: f.typ,
+				NewType: g.typ,
+			})
+		}
+	}
+
+	for _, g := range f2 {
+		if _, ok := fieldByName(f1, g.name); !ok {
+			*changes = append(*changes, FieldChange{
+				Name:    prefix + g.name,
+				Change:  FieldAdded,
+				NewType: g.typ,
+			})
+		}
+	}
+}
+
+type structField struct {
+	name string
+	typ  reflect.Type
+}
+
+// structFields returns the exported, encoded fields of the struct.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := f.Name
+
+		if tag := f.Tag.Get("json"); tag != "" {
+			tag = strings.Split(tag, ",")[0]
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+
+		fields = append(fields, structField{
+			name: name,
+			typ:  f.Type,
+		})
+	}
+
+	return fields
+}
+
+func fieldByName(fields []structField, name string) (structField, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+
+	return structField{}, false
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t
+}
+
+// typeShape describes the structure of a type ignoring type names.
+func typeShape(t reflect.Type) string {
+	t = indirectType(t)
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return "[]" + typeShape(t.Elem())
+	case reflect.Map:
+		return "map[" + typeShape(t.Key()) + "]" + typeShape(t.Elem())
+	}
+
+	return t.Kind().String()
+}
@@ -0,0 +1,67 @@
+package eda
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffEventTypes(t *testing.T) {
+	type address struct {
+		City string `json:"city"`
+	}
+
+	type v1 struct {
+		ID      string   `json:"id"`
+		Name    string   `json:"name"`
+		Age     int      `json:"age"`
+		Address *address `json:"address"`
+		Tags    []string `json:"tags"`
+	}
+
+	type address2 struct {
+		City string `json:"city"`
+		Zip  string `json:"zip"`
+	}
+
+	type v2 struct {
+		Key     string   `json:"id"`
+		Age     string   `json:"age"`
+		Address address2 `json:"address"`
+		Tags    []string `json:"tags"`
+		Email   string   `json:"email"`
+		Ignored string   `json:"-"`
+		private string
+	}
+
+	changes, err := DiffEventTypes(reflect.TypeOf(v1{}), reflect.TypeOf(&v2{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]ChangeType{
+		"name":        FieldRemoved,
+		"age":         FieldTypeChanged,
+		"address.zip": FieldAdded,
+		"email":       FieldAdded,
+	}
+
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %v", len(expected), changes)
+	}
+
+	for _, c := range changes {
+		if ct, ok := expected[c.Name]; !ok || ct != c.Change {
+			t.Errorf("unexpected change: %s", c)
+		}
+
+		if c.Breaking() != (c.Change != FieldAdded) {
+			t.Errorf("unexpected breaking flag: %s", c)
+		}
+	}
+}
+
+func TestDiffEventTypesNonStruct(t *testing.T) {
+	if _, err := DiffEventTypes(reflect.TypeOf(""), reflect.TypeOf(Event{})); err == nil {
+		t.Fatal("expected error for non-struct type")
+	}
+}
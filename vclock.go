@@ -0,0 +1,138 @@
+package eda
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// VectorClockMetaKey is the event meta key the vector clock is stored in.
+const VectorClockMetaKey = "vclock"
+
+// VectorClock is a logical clock with a counter per node. Unlike a Lamport
+// clock, it can be used to determine if two events are causally related or
+// were produced concurrently.
+type VectorClock map[string]uint64
+
+// Copy returns a copy of the clock.
+func (v VectorClock) Copy() VectorClock {
+	c := make(VectorClock, len(v))
+	for n, t := range v {
+		c[n] = t
+	}
+	return c
+}
+
+// Merge advances each node counter to the other clock's counter if it
+// is higher.
+func (v VectorClock) Merge(other VectorClock) {
+	for n, t := range other {
+		if t > v[n] {
+			v[n] = t
+		}
+	}
+}
+
+// HappensBefore returns true if the clock is causally before the other
+// clock. That is, no counter is greater than the other's and at least one
+// is less.
+func (v VectorClock) HappensBefore(other VectorClock) bool {
+	less := false
+
+	for n, t := range v {
+		if t > other[n] {
+			return false
+		}
+		if t < other[n] {
+			less = true
+		}
+	}
+
+	for n, t := range other {
+		if _, ok := v[n]; !ok && t > 0 {
+			less = true
+		}
+	}
+
+	return less
+}
+
+// Concurrent returns true if neither clock happens before the other and
+// they are not equal.
+func (v VectorClock) Concurrent(other VectorClock) bool {
+	return !v.HappensBefore(other) && !other.HappensBefore(v) && !v.Equal(other)
+}
+
+// Equal returns true if the clocks have the same counters.
+func (v VectorClock) Equal(other VectorClock) bool {
+	for n, t := range v {
+		if other[n] != t {
+			return false
+		}
+	}
+
+	for n, t := range other {
+		if v[n] != t {
+			return false
+		}
+	}
+
+	return true
+}
+
+// VectorClockFromEvent returns the vector clock stored in the event meta.
+// An empty clock is returned if the event does not have one.
+func VectorClockFromEvent(evt *Event) (VectorClock, error) {
+	v := VectorClock{}
+
+	s, ok := evt.Meta[VectorClockMetaKey]
+	if !ok {
+		return v, nil
+	}
+
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// nodeClock is the vector clock of the local node.
+type nodeClock struct {
+	mux   sync.Mutex
+	node  string
+	clock VectorClock
+}
+
+// stamp increments the node counter and stores the clock in the event meta.
+func (c *nodeClock) stamp(evt *Event) error {
+	c.mux.Lock()
+	c.clock[c.node]++
+	b, err := json.Marshal(c.clock)
+	c.mux.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if evt.Meta == nil {
+		evt.Meta = make(map[string]string)
+	}
+
+	evt.Meta[VectorClockMetaKey] = string(b)
+
+	return nil
+}
+
+// witness merges the clock of a received event.
+func (c *nodeClock) witness(evt *Event) error {
+	v, err := VectorClockFromEvent(evt)
+	if err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	c.clock.Merge(v)
+	c.mux.Unlock()
+
+	return nil
+}
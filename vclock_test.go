@@ -0,0 +1,47 @@
+package eda
+
+import "testing"
+
+func TestVectorClockOrdering(t *testing.T) {
+	a := VectorClock{"a": 1}
+	b := VectorClock{"a": 1, "b": 1}
+	c := VectorClock{"a": 2}
+
+	if !a.HappensBefore(b) || b.HappensBefore(a) {
+		t.Error("expected a before b")
+	}
+
+	if !b.Concurrent(c) || !c.Concurrent(b) {
+		t.Error("expected b and c to be concurrent")
+	}
+
+	if a.Concurrent(a.Copy()) || a.HappensBefore(a.Copy()) {
+		t.Error("expected equal clocks to be neither concurrent nor ordered")
+	}
+}
+
+func TestNodeClock(t *testing.T) {
+	a := &nodeClock{node: "a", clock: VectorClock{}}
+	b := &nodeClock{node: "b", clock: VectorClock{}}
+
+	e1 := &Event{}
+	if err := a.stamp(e1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.witness(e1); err != nil {
+		t.Fatal(err)
+	}
+
+	e2 := &Event{}
+	if err := b.stamp(e2); err != nil {
+		t.Fatal(err)
+	}
+
+	v1, _ := VectorClockFromEvent(e1)
+	v2, _ := VectorClockFromEvent(e2)
+
+	if !v1.HappensBefore(v2) {
+		t.Fatalf("expected %v before %v", v1, v2)
+	}
+}